@@ -2,13 +2,16 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 
 	"github.com/pingvincible/kvdatabase/internal/kvio"
 	"github.com/pingvincible/kvdatabase/internal/logger"
+	"github.com/pingvincible/kvdatabase/internal/migration"
 	"github.com/pingvincible/kvdatabase/internal/tcp"
 )
 
@@ -16,11 +19,25 @@ func main() {
 	kvLogger := logger.Configure("debug")
 	kvLogger.Info("KV CLI started")
 
-	var addr string
+	var addr, rebalance string
 
 	flag.StringVar(&addr, "hostname", "localhost:3223", "address to connect to")
+	flag.StringVar(&rebalance, "rebalance", "", "comma separated nodes to assign hash slots evenly to, then exit")
 	flag.Parse()
 
+	if rebalance != "" {
+		err := migration.Rebalance(context.Background(), strings.Split(rebalance, ","), kvLogger)
+		if err != nil {
+			kvLogger.Error(
+				"failed to rebalance slots",
+				slog.String("error", err.Error()),
+			)
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	client, err := tcp.NewClient(addr)
 	if err != nil {
 		kvLogger.Error(
@@ -32,7 +49,7 @@ func main() {
 
 	consoleReadWriter := kvio.NewReadWriter(bufio.NewReader(os.Stdin), bufio.NewWriter(os.Stdout))
 
-	err = Run(consoleReadWriter, client.ReadWriter)
+	err = Run(consoleReadWriter, client)
 	if err != nil {
 		kvLogger.Error(
 			"failed to run",
@@ -49,7 +66,9 @@ func main() {
 	}
 }
 
-func Run(consoleReadWriter *kvio.ReadWriter, clientReadWriter *kvio.ReadWriter) error {
+// Run sends commands read from the console with client, which routes them to the nodes
+// serving their keys.
+func Run(consoleReadWriter *kvio.ReadWriter, client *tcp.Client) error {
 	for {
 		err := consoleReadWriter.Write(">>")
 		if err != nil {
@@ -69,14 +88,9 @@ func Run(consoleReadWriter *kvio.ReadWriter, clientReadWriter *kvio.ReadWriter)
 			return nil
 		}
 
-		err = clientReadWriter.WriteLine(request)
-		if err != nil {
-			return fmt.Errorf("failed to write to server: %w", err)
-		}
-
-		response, err := clientReadWriter.ReadLine()
+		response, err := client.Do(request)
 		if err != nil {
-			return fmt.Errorf("failed to read from server: %w", err)
+			return fmt.Errorf("failed to send to server: %w", err)
 		}
 
 		err = consoleReadWriter.WriteLine("->: " + response)
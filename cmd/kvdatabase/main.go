@@ -1,15 +1,21 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"log/slog"
 	"os"
+	"strings"
 
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/pingvincible/kvdatabase/internal/compute"
 	"github.com/pingvincible/kvdatabase/internal/config"
 	"github.com/pingvincible/kvdatabase/internal/logger"
+	"github.com/pingvincible/kvdatabase/internal/migration"
+	"github.com/pingvincible/kvdatabase/internal/raft"
+	"github.com/pingvincible/kvdatabase/internal/replication"
+	"github.com/pingvincible/kvdatabase/internal/slot"
 	"github.com/pingvincible/kvdatabase/internal/storage/engine"
 	"github.com/pingvincible/kvdatabase/internal/tcp"
 )
@@ -24,12 +30,21 @@ func main() {
 	cfg.UpdateWithFlags(flags)
 	log.Printf("%+v", cfg)
 
+	err = cfg.Validate()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	kvLogger := logger.Configure(cfg.Logging.Level)
 
 	kvLogger.Info("KV database started")
 
 	kvEngine := engine.New()
+	kvEngine.RetainHistory(cfg.Engine.HistoryRevisions)
+	go kvEngine.RunRetention(context.Background(), cfg.Engine.RetentionInterval)
+
 	computer := compute.NewComputer(kvEngine)
+	computer.AllowAdmin(cfg.Network.AdminCommands)
 
 	server, err := tcp.NewServer(cfg.Network, computer, kvLogger)
 	if err != nil {
@@ -50,21 +65,150 @@ func main() {
 
 	kvLogger.Info("tcp server started", slog.String("address", addr))
 
+	startReplication(cfg, computer, server, addr, kvLogger)
+
+	startSlots(cfg, computer, server, addr, kvLogger)
+
 	server.Run()
 }
 
+// startSlots makes the database serve the configured hash slots and migrate them
+// to other nodes on request. Only a database serving slots imports them from other nodes,
+// and it rejects imports unless admin commands are allowed.
+func startSlots(
+	cfg *config.Config, computer *compute.Computer, server *tcp.Server, addr string, kvLogger *slog.Logger,
+) {
+	var slots *slot.Map
+
+	if len(cfg.Cluster.Slots) > 0 {
+		var err error
+
+		slots, err = slot.ParseMap(cfg.Cluster.Slots)
+		if err != nil {
+			kvLogger.Error(
+				"failed to parse cluster slots",
+				slog.String("error", err.Error()),
+			)
+			os.Exit(1)
+		}
+	}
+
+	address := clusterAddress(cfg, addr)
+
+	computer.SetSlots(address, slots)
+	computer.SetMigrator(migration.NewMigrator(computer, address, cfg.Cluster.MigrationBatch, kvLogger))
+
+	if slots != nil {
+		server.Handle(migration.NewImporter(computer, kvLogger))
+	}
+}
+
+// clusterAddress returns the address other nodes and clients reach the database at.
+func clusterAddress(cfg *config.Config, addr string) string {
+	if cfg.Cluster.Address != "" {
+		return cfg.Cluster.Address
+	}
+
+	return addr
+}
+
+// startReplication makes the database a primary, a replica or a cluster member
+// depending on the configured replication role.
+func startReplication(
+	cfg *config.Config, computer *compute.Computer, server *tcp.Server, addr string, kvLogger *slog.Logger,
+) {
+	switch cfg.Replication.Role {
+	case config.RoleCluster:
+		node, err := raft.NewNode(raft.Config{
+			ID:                clusterAddress(cfg, addr),
+			Members:           cfg.Cluster.Members,
+			ElectionTimeout:   cfg.Cluster.ElectionTimeout,
+			HeartbeatInterval: cfg.Cluster.HeartbeatInterval,
+			SnapshotEntries:   cfg.Cluster.SnapshotEntries,
+			StatePath:         cfg.Cluster.StatePath,
+		}, computer.StateMachine(), raft.NewTCPTransport(), kvLogger)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		computer.SetConsensus(node)
+		server.Handle(node)
+
+		go node.Run(context.Background())
+	default:
+		manager := replication.NewManager(computer, addr, cfg.Replication.SyncInterval, kvLogger)
+		computer.SetReplication(manager, false)
+		computer.SetQuorum(cfg.Replication.MinReplicas, cfg.Replication.AckTimeout)
+		server.Handle(manager)
+
+		if cfg.Replication.Role == config.RoleReplica {
+			err := manager.ReplicaOf(cfg.Replication.PrimaryAddress)
+			if err != nil {
+				kvLogger.Error("failed to start replication", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
 func handleFlags(cfg *config.Config) config.Flags {
 	flagSet := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	flagSet.Usage = cleanenv.FUsage(flagSet.Output(), cfg, nil, flagSet.Usage)
 
 	configFlags := config.Flags{
-		EngineType:     flagSet.String("engineType", cfg.Engine.Type, "database engine type"),
+		EngineType: flagSet.String("engineType", cfg.Engine.Type, "database engine type"),
+		RetentionInterval: flagSet.Duration(
+			"retentionInterval", cfg.Engine.RetentionInterval, "interval of time series retention enforcement",
+		),
+		HistoryRevisions: flagSet.Int64(
+			"historyRevisions", cfg.Engine.HistoryRevisions, "number of latest revisions whose key versions and watch events are retained",
+		),
 		Address:        flagSet.String("address", cfg.Network.Address, "address to listen"),
 		MaxConnections: flagSet.Int("maxConnections", cfg.Network.MaxConnections, "max client connections"),
 		MaxMessageSize: flagSet.String("maxMessageSize", cfg.Network.MaxMessageSize, "max message size"),
 		IdleTimeout:    flagSet.Duration("idleTimeout", cfg.Network.IdleTimeout, "idle timeout"),
+		AdminCommands:  flagSet.Bool("adminCommands", cfg.Network.AdminCommands, "allow admin commands"),
 		LoggingLevel:   flagSet.String("logLevel", cfg.Logging.Level, "log level"),
 		LoggingOutput:  flagSet.String("logOutput", cfg.Logging.Output, "log output filename"),
+		ReplicationRole: flagSet.String(
+			"replicationRole", cfg.Replication.Role, "replication role, primary or replica",
+		),
+		PrimaryAddress: flagSet.String(
+			"primaryAddress", cfg.Replication.PrimaryAddress, "address of the primary a replica replicates",
+		),
+		SyncInterval: flagSet.Duration(
+			"syncInterval", cfg.Replication.SyncInterval, "interval of pings to replicas and reconnects",
+		),
+		MinReplicas: flagSet.Int(
+			"minReplicas", cfg.Replication.MinReplicas, "replicas acknowledging every write before its response",
+		),
+		AckTimeout: flagSet.Duration(
+			"ackTimeout", cfg.Replication.AckTimeout, "wait for replicas acknowledging a write, 0 waits forever",
+		),
+		ClusterAddress: flagSet.String(
+			"clusterAddress", cfg.Cluster.Address, "address members and clients reach the node at",
+		),
+		ClusterMembers: flagSet.String(
+			"clusterMembers", strings.Join(cfg.Cluster.Members, ","), "comma separated initial cluster members",
+		),
+		ElectionTimeout: flagSet.Duration(
+			"electionTimeout", cfg.Cluster.ElectionTimeout, "time without a leader before an election",
+		),
+		HeartbeatInterval: flagSet.Duration(
+			"heartbeatInterval", cfg.Cluster.HeartbeatInterval, "interval of leader heartbeats",
+		),
+		SnapshotEntries: flagSet.Int64(
+			"snapshotEntries", cfg.Cluster.SnapshotEntries, "applied log entries replaced with a snapshot",
+		),
+		StatePath: flagSet.String(
+			"statePath", cfg.Cluster.StatePath, "file the raft term, vote and log are persisted to",
+		),
+		ClusterSlots: flagSet.String(
+			"clusterSlots", strings.Join(cfg.Cluster.Slots, ","),
+			"comma separated hash slots assigned to nodes as from-to=address",
+		),
+		MigrationBatch: flagSet.Int(
+			"migrationBatch", cfg.Cluster.MigrationBatch, "keys moved at a time when migrating slots",
+		),
 	}
 
 	_ = flagSet.Parse(os.Args[1:])
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/logger"
+	"github.com/pingvincible/kvdatabase/internal/proxy"
+)
+
+func main() {
+	var (
+		address, backends, logLevel string
+		cfg                         proxy.Config
+	)
+
+	flag.StringVar(&address, "address", "127.0.0.1:3300", "address to listen")
+	flag.StringVar(&backends, "backends", "", "comma separated addresses of the databases keys are spread over")
+	flag.IntVar(&cfg.PoolSize, "poolSize", 8, "idle connections kept per backend") //nolint: mnd // default
+	flag.DurationVar(&cfg.Timeout, "timeout", time.Second, "timeout of connecting to backends and of health checks")
+	flag.DurationVar(&cfg.HealthInterval, "healthInterval", time.Second, "interval of backend health checks")
+	flag.StringVar(&logLevel, "logLevel", "info", "log level")
+	flag.Usage = usage
+	flag.Parse()
+
+	kvLogger := logger.Configure(logLevel)
+	kvLogger.Info("KV proxy started")
+
+	if backends != "" {
+		cfg.Backends = strings.Split(backends, ",")
+	}
+
+	kvProxy, err := proxy.New(cfg, kvLogger)
+	if err != nil {
+		kvLogger.Error(
+			"failed to create proxy",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		kvLogger.Error(
+			"failed to start listening",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	kvLogger.Info("proxy listening", slog.String("address", listener.Addr().String()))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go kvProxy.RunHealthChecks(ctx)
+
+	err = kvProxy.Serve(ctx, listener)
+	if err != nil {
+		kvLogger.Error(
+			"proxy stopped",
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// usage prints the flags and which commands spanning several backends the proxy fans out.
+func usage() {
+	output := flag.CommandLine.Output()
+
+	_, _ = fmt.Fprintf(output, `Usage of %s:
+kvproxy spreads keys over the backends by consistent hashing, keys sharing a hash tag
+such as {user1000}.following are kept on one backend. DBSIZE and PUBLISH are sent to all
+backends, and SINTER and SUNION of keys on several backends are merged. Other commands
+with keys on several backends fail with CROSSBACKEND, i.e. PFCOUNT, PFMERGE, BITOP,
+BLPOP, XREAD and XREADGROUP.
+`, os.Args[0])
+	flag.PrintDefaults()
+}
@@ -0,0 +1,59 @@
+package compute
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+var ErrInvalidNumber = errors.New("value is not a valid number")
+
+func parseInt(text string) (int, error) {
+	number, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidNumber, text)
+	}
+
+	return number, nil
+}
+
+func parseInt64(text string) (int64, error) {
+	number, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidNumber, text)
+	}
+
+	return number, nil
+}
+
+func parseSeconds(text string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(text, 64)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidNumber, text)
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func parseFloat(text string) (float64, error) {
+	number, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsNaN(number) {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidNumber, text)
+	}
+
+	return number, nil
+}
+
+func formatFloat(number float64) string {
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
+
+func formatBool(value bool) string {
+	if value {
+		return "1"
+	}
+
+	return "0"
+}
@@ -0,0 +1,77 @@
+package compute
+
+import (
+	"strconv"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+	"github.com/pingvincible/kvdatabase/internal/storage/engine"
+)
+
+type BitmapStorage interface {
+	SetBit(key string, offset int, bit int) (int, error)
+	GetBit(key string, offset int) (int, error)
+	BitCount(key string, start, end int) (int, error)
+	BitOp(op engine.BitOp, destination string, keys []string) (int, error)
+	BitPos(key string, bit int, start, end int, hasEnd bool) (int, error)
+}
+
+func (c *Computer) computeBitmap(storage StorageInterface, command parser.Command) (string, error) {
+	numbers := make([]int, 0, len(command.Args))
+
+	if command.Type != parser.CommandBitOp {
+		for _, arg := range command.Args {
+			number, err := parseInt(arg)
+			if err != nil {
+				return "", err
+			}
+
+			numbers = append(numbers, number)
+		}
+	}
+
+	var (
+		result int
+		err    error
+	)
+
+	switch command.Type {
+	case parser.CommandSetBit:
+		result, err = storage.SetBit(command.Key, numbers[0], numbers[1])
+	case parser.CommandGetBit:
+		result, err = storage.GetBit(command.Key, numbers[0])
+	case parser.CommandBitCount:
+		result, err = c.computeBitCount(storage, command.Key, numbers)
+	case parser.CommandBitOp:
+		result, err = storage.BitOp(engine.BitOp(command.Key), command.Args[0], command.Args[1:])
+	case parser.CommandBitPos:
+		result, err = c.computeBitPos(storage, command.Key, numbers)
+	}
+
+	return strconv.Itoa(result), err
+}
+
+// computeBitCount handles BITCOUNT key [start end].
+func (c *Computer) computeBitCount(storage StorageInterface, key string, numbers []int) (int, error) {
+	switch len(numbers) {
+	case 0:
+		return storage.BitCount(key, 0, -1)
+	case 2: //nolint: mnd // start and end
+		return storage.BitCount(key, numbers[0], numbers[1])
+	default:
+		return 0, ErrSyntax
+	}
+}
+
+// computeBitPos handles BITPOS key bit [start [end]].
+func (c *Computer) computeBitPos(storage StorageInterface, key string, numbers []int) (int, error) {
+	switch len(numbers) {
+	case 1:
+		return storage.BitPos(key, numbers[0], 0, -1, false)
+	case 2: //nolint: mnd // bit and start
+		return storage.BitPos(key, numbers[0], numbers[1], -1, false)
+	case 3: //nolint: mnd // bit, start and end
+		return storage.BitPos(key, numbers[0], numbers[1], numbers[2], true)
+	default:
+		return 0, ErrSyntax
+	}
+}
@@ -0,0 +1,170 @@
+package compute
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+	"github.com/pingvincible/kvdatabase/internal/replication"
+)
+
+var (
+	ErrNotClustered       = errors.New("the database is not running in cluster mode")
+	ErrClusterUnsupported = errors.New("command is not supported in cluster mode")
+	ErrInvalidProposal    = errors.New("invalid proposal")
+)
+
+// Consensus orders writes of a cluster in a replicated log and applies them
+// to the StateMachine of every member.
+type Consensus interface {
+	ReplicationStatus
+	Propose(ctx context.Context, command []byte) (string, error)
+	AddMember(ctx context.Context, id string) error
+	RemoveMember(ctx context.Context, id string) error
+}
+
+// SetConsensus makes the database a cluster member, writes are proposed to consensus
+// and executed once it applies them to the StateMachine.
+func (c *Computer) SetConsensus(consensus Consensus) {
+	c.replicationMutex.Lock()
+	defer c.replicationMutex.Unlock()
+
+	c.consensus = consensus
+	c.replication = consensus
+}
+
+func (c *Computer) clusterConsensus() Consensus {
+	c.replicationMutex.RLock()
+	defer c.replicationMutex.RUnlock()
+
+	return c.consensus
+}
+
+// propose proposes a write command to the cluster and returns its result once applied.
+func (c *Computer) propose(
+	ctx context.Context, consensus Consensus, session *Session, command parser.Command, text string,
+) (string, error) {
+	if !clusterSupports(command) {
+		return "", ErrClusterUnsupported
+	}
+
+	// the proposal carries the time of the proposing node, so that every member executes
+	// time dependent commands the same way
+	proposal := strings.Join([]string{
+		session.namespace,
+		strconv.FormatInt(time.Now().UnixNano(), 10),
+		strings.TrimSpace(text),
+	}, " ")
+
+	result, err := consensus.Propose(ctx, []byte(proposal))
+	if err != nil {
+		return "", fmt.Errorf("failed to replicate command: %w", err)
+	}
+
+	return result, nil
+}
+
+// clusterSupports reports whether command can be proposed. Commands are executed by every member,
+// so commands waiting for other clients or expiring keys on local timers are not supported.
+func clusterSupports(command parser.Command) bool {
+	if blocks(command) {
+		return false
+	}
+
+	switch command.Type { //nolint: exhaustive // other writes are executed the same way by every member
+	case parser.CommandLease:
+		return false
+	case parser.CommandSet:
+		// SET key value LEASE id attaches the key to a lease
+		return len(command.Args) == 0
+	default:
+		return true
+	}
+}
+
+// computeCluster handles CLUSTER.ADD address and CLUSTER.REMOVE address, which change
+// the members of the cluster one at a time.
+func (c *Computer) computeCluster(ctx context.Context, command parser.Command) (string, error) {
+	consensus := c.clusterConsensus()
+	if consensus == nil {
+		return "", ErrNotClustered
+	}
+
+	if command.Type == parser.CommandClusterAdd {
+		return "", consensus.AddMember(ctx, command.Key)
+	}
+
+	return "", consensus.RemoveMember(ctx, command.Key)
+}
+
+// StateMachine executes the writes of a cluster in the order of its replicated log.
+type StateMachine struct {
+	computer *Computer
+	session  *Session
+}
+
+// StateMachine returns the state machine the consensus applies writes to.
+func (c *Computer) StateMachine() *StateMachine {
+	return &StateMachine{computer: c, session: NewSession()}
+}
+
+// Apply executes a proposed write command: namespace unix-nanoseconds command.
+func (s *StateMachine) Apply(proposal []byte) (string, error) {
+	namespace, rest, _ := strings.Cut(string(proposal), " ")
+	nanoseconds, text, _ := strings.Cut(rest, " ")
+
+	clock, err := strconv.ParseInt(nanoseconds, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidProposal, proposal)
+	}
+
+	command, err := parser.Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse command: %w", err)
+	}
+
+	s.session.namespace = namespace
+	s.session.clock = time.Unix(0, clock)
+
+	return s.computer.execute(context.Background(), s.session, command)
+}
+
+type clusterSnapshot struct {
+	Revision int64
+	Entries  []replication.Entry
+}
+
+// Snapshot returns all keys as of the latest applied write.
+func (s *StateMachine) Snapshot() ([]byte, error) {
+	revision, entries, err := s.computer.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+
+	err = gob.NewEncoder(&buffer).Encode(clusterSnapshot{Revision: revision, Entries: entries})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// Restore replaces all keys with a snapshot taken by Snapshot.
+func (s *StateMachine) Restore(data []byte) error {
+	var snapshot clusterSnapshot
+
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	return s.computer.Load(snapshot.Revision, snapshot.Entries)
+}
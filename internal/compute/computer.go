@@ -1,45 +1,239 @@
 package compute
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+	"github.com/pingvincible/kvdatabase/internal/lease"
+	"github.com/pingvincible/kvdatabase/internal/lock"
+	"github.com/pingvincible/kvdatabase/internal/pubsub"
+	"github.com/pingvincible/kvdatabase/internal/replication"
+	"github.com/pingvincible/kvdatabase/internal/slot"
+	"github.com/pingvincible/kvdatabase/internal/storage/engine"
+	"github.com/pingvincible/kvdatabase/internal/watch"
+)
+
+var (
+	ErrWrongArgumentsCount = errors.New("wrong number of arguments")
+	ErrSyntax              = errors.New("syntax error")
 )
 
 type StorageInterface interface {
 	Set(key, value string)
-	Get(key string) string
-	Delete(key string)
+	Get(key string) (string, error)
+	Delete(key string) bool
+	Exists(key string) bool
+	Flush() int
+	KeyCount() int
+	WaitFor(keys []string) (<-chan struct{}, func())
+
+	HashStorage
+	ListStorage
+	SetStorage
+	ZSetStorage
+	JSONStorage
+	StreamStorage
+	ProbabilisticStorage
+	TimeSeriesStorage
+	BitmapStorage
+	HistoryStorage
+	QueueStorage
+	RateLimitStorage
+	DumpStorage
 }
 
+// the engine implements the storage of a namespace
+var _ StorageInterface = (*engine.Engine)(nil)
+
 type Computer struct {
-	storage StorageInterface
+	storage  *engine.Engine // the default namespace, other namespaces are reached through it
+	broker   *pubsub.Broker
+	watchers *watch.Hub
+	leases   *lease.Lessor
+	locks    *lock.Locker
+
+	writeMutex sync.Mutex
+	revision   int64         // guarded by writeMutex
+	changed    []watch.Event // changes of the running mutation besides its key, guarded by writeMutex
+	log        *replication.Log
+
+	replicationMutex sync.RWMutex
+	replication      ReplicationStatus
+	readOnly         bool
+	admin            bool // clients may run admin commands
+	consensus        Consensus
+	quorum           int           // replicas acknowledging every write before its response
+	quorumTimeout    time.Duration // wait for the quorum, zero waits forever
+
+	slotsMutex sync.RWMutex
+	address    string         // the address clients reach the database at
+	slots      *slot.Map      // nil serves all keys
+	migrating  map[int]string // target addresses of slots moving to other nodes
+	importing  map[int]string // source addresses of slots moving to the database
+	migrator   Migrator
+
+	// migrationMutex is held for writing while keys are moved to other nodes and for reading
+	// by commands of keys of migrating slots
+	migrationMutex sync.RWMutex
 }
 
-func NewComputer(storage StorageInterface) *Computer {
-	return &Computer{storage: storage}
+func NewComputer(storage *engine.Engine) *Computer {
+	computer := &Computer{
+		storage:  storage,
+		broker:   pubsub.NewBroker(),
+		watchers: watch.NewHub(storage),
+		locks:    lock.NewLocker(),
+		log:      replication.NewLog(replicationBacklog),
+	}
+	computer.leases = lease.NewLessor(computer.expireLease)
+
+	return computer
 }
 
-func (c *Computer) compute(command parser.Command) string {
+func (c *Computer) compute(ctx context.Context, session *Session, command parser.Command) (string, error) {
 	result := ""
+	storage := c.namespace(session.namespace, createsNamespace(command))
+
+	if session.Subscribed() && c.broker.Subscriptions(session.subscriber) > 0 && !isPubSubCommand(command.Type) {
+		return "", ErrSubscribedMode
+	}
+
+	if adminCommands()[command.Type] && !c.isAdminAllowed() {
+		return "", ErrAdminDisabled
+	}
 
 	switch command.Type {
 	case parser.CommandSet:
-		c.storage.Set(command.Key, command.Value)
-	case parser.CommandGet:
-		result = c.storage.Get(command.Key)
+		return c.computeSetValue(storage, session, command)
+	case parser.CommandInfo:
+		return c.computeInfo(), nil
+	case parser.CommandSlots, parser.CommandSlotsAssign, parser.CommandSlotsMigrate, parser.CommandAsking:
+		return c.computeSlots(ctx, command)
+	case parser.CommandWait:
+		return c.computeWait(ctx, session, command)
+	case parser.CommandReplicaOf, parser.CommandPromote:
+		return c.computeFailover(ctx, command)
+	case parser.CommandClusterAdd, parser.CommandClusterRemove:
+		return c.computeCluster(ctx, command)
+	case parser.CommandLease:
+		return c.computeLease(command)
+	case parser.CommandQCreate, parser.CommandQPush, parser.CommandQPop, parser.CommandQAck, parser.CommandQNack:
+		return c.computeQueue(storage, session, command)
+	case parser.CommandRateLimit, parser.CommandRateLimitWindow:
+		return c.computeRateLimit(storage, session, command)
+	case parser.CommandLock, parser.CommandUnlock, parser.CommandLockRenew:
+		return c.computeLock(ctx, session, command)
+	case parser.CommandGet, parser.CommandHistory, parser.CommandCompact:
+		return c.computeHistory(storage, command)
 	case parser.CommandDel:
-		c.storage.Delete(command.Key)
+		if storage.Delete(command.Key) {
+			c.changed = append(c.changed, watch.Event{Namespace: session.namespace, Key: command.Key, Op: opDel})
+		}
+
+		c.leases.Detach(lease.Key{Namespace: session.namespace, Name: command.Key})
+	case parser.CommandSelect, parser.CommandFlush, parser.CommandDBSize, parser.CommandNamespaces:
+		return c.computeNamespace(session, command)
+	case parser.CommandSubscribe, parser.CommandPSubscribe, parser.CommandUnsubscribe, parser.CommandPUnsubscribe,
+		parser.CommandPublish:
+		return c.computePubSub(session, command)
+	case parser.CommandWatch, parser.CommandUnwatch:
+		return c.computeWatch(session, command)
+	case parser.CommandHSet, parser.CommandHGet, parser.CommandHDel, parser.CommandHGetAll:
+		return c.computeHash(storage, command)
+	case parser.CommandLPush, parser.CommandRPush, parser.CommandLPop, parser.CommandRPop,
+		parser.CommandLRange, parser.CommandLLen, parser.CommandBLPop:
+		return c.computeList(ctx, storage, command)
+	case parser.CommandSAdd, parser.CommandSRem, parser.CommandSMembers, parser.CommandSIsMember,
+		parser.CommandSInter, parser.CommandSUnion:
+		return c.computeSet(storage, command)
+	case parser.CommandZAdd, parser.CommandZRange, parser.CommandZRangeByScore, parser.CommandZRank,
+		parser.CommandZRem:
+		return c.computeZSet(storage, command)
+	case parser.CommandJSONSet, parser.CommandJSONGet, parser.CommandJSONDel, parser.CommandJSONNumIncrBy:
+		return c.computeJSON(storage, command)
+	case parser.CommandXAdd, parser.CommandXRange, parser.CommandXRead, parser.CommandXGroup,
+		parser.CommandXReadGroup, parser.CommandXAck, parser.CommandXPending:
+		return c.computeStream(ctx, storage, session, command)
+	case parser.CommandPFAdd, parser.CommandPFCount, parser.CommandPFMerge,
+		parser.CommandBFReserve, parser.CommandBFAdd, parser.CommandBFExists:
+		return c.computeProbabilistic(storage, command)
+	case parser.CommandTSCreate, parser.CommandTSAdd, parser.CommandTSRange:
+		return c.computeTimeSeries(storage, session, command)
+	case parser.CommandSetBit, parser.CommandGetBit, parser.CommandBitCount, parser.CommandBitOp,
+		parser.CommandBitPos:
+		return c.computeBitmap(storage, command)
 	}
 
-	return result
+	return result, nil
 }
 
-func (c *Computer) Process(text string) (string, error) {
+// namespace returns the storage of the namespace called name. Only with create a namespace
+// that does not exist yet is created, otherwise it is an empty namespace that must not be changed.
+func (c *Computer) namespace(name string, create bool) StorageInterface {
+	if create {
+		return c.storage.Namespace(name)
+	}
+
+	return c.storage.LookupNamespace(name)
+}
+
+// createsNamespace reports whether command needs the namespace of the session to exist:
+// writes of keys store them there and blocking reads wait there for writes of other clients.
+func createsNamespace(command parser.Command) bool {
+	if command.Type == parser.CommandXRead {
+		return slices.ContainsFunc(command.Args, func(arg string) bool { return strings.EqualFold(arg, optionBlock) })
+	}
+
+	return (writeCommands()[command.Type] || localWriteCommands()[command.Type]) && len(command.Keys()) > 0
+}
+
+// Process executes a single command of session. Blocking commands are aborted when ctx is done.
+func (c *Computer) Process(ctx context.Context, session *Session, text string) (string, error) {
 	command, err := parser.Parse(text)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse command: %w", err)
 	}
 
-	return c.compute(command), nil
+	release, err := c.checkSlots(session, command)
+	if err != nil {
+		return "", err
+	}
+
+	defer release()
+
+	if consensus := c.clusterConsensus(); consensus != nil && writeCommands()[command.Type] {
+		return c.propose(ctx, consensus, session, command, text)
+	}
+
+	written := session.revision
+
+	result, err := c.execute(ctx, session, command)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute command: %w", err)
+	}
+
+	if session.revision != written {
+		err = c.waitQuorum(ctx, session.revision)
+		if err != nil {
+			return "", fmt.Errorf("failed to replicate command: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// CloseSession releases resources held by session once its connection is closed.
+func (c *Computer) CloseSession(session *Session) {
+	if session.Subscribed() {
+		c.broker.Remove(session.subscriber)
+		c.watchers.Remove(session.subscriber)
+	}
+
+	c.locks.Release(session)
 }
@@ -0,0 +1,457 @@
+package compute_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/compute"
+	"github.com/pingvincible/kvdatabase/internal/lease"
+	"github.com/pingvincible/kvdatabase/internal/lock"
+	"github.com/pingvincible/kvdatabase/internal/logger"
+	"github.com/pingvincible/kvdatabase/internal/raft"
+	"github.com/pingvincible/kvdatabase/internal/storage/engine"
+	"github.com/pingvincible/kvdatabase/internal/watch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func process(t *testing.T, computer *compute.Computer, session *compute.Session, text string) string {
+	t.Helper()
+
+	result, err := computer.Process(context.Background(), session, text)
+	require.NoError(t, err)
+
+	return result
+}
+
+func TestComputerProcess(t *testing.T) {
+	t.Parallel()
+
+	computer := compute.NewComputer(engine.New())
+	session := compute.NewSession()
+
+	assert.Equal(t, "rev 1", process(t, computer, session, "SET key value"))
+	assert.Equal(t, "value", process(t, computer, session, "GET key"))
+	assert.Equal(t, "rev 2 2", process(t, computer, session, "HSET user/1 name bob email bob_mail"))
+	assert.Equal(t, "email bob_mail name bob", process(t, computer, session, "HGETALL user/1"))
+	assert.Equal(t, "rev 3 2", process(t, computer, session, "RPUSH queue a b"))
+	assert.Equal(t, "rev 4 queue a", process(t, computer, session, "BLPOP queue 0"))
+	assert.Equal(t, "rev 5 2", process(t, computer, session, "ZADD board 3 a 2 b"))
+	assert.Equal(t, "b 2 a 3", process(t, computer, session, "ZRANGE board 0 -1 WITHSCORES"))
+	assert.Equal(t, "rev 6", process(t, computer, session, `JSON.SET doc $ {"visits": 1}`))
+	assert.Equal(t, "rev 7 3", process(t, computer, session, "JSON.NUMINCRBY doc $.visits 2"))
+
+	_, err := computer.Process(context.Background(), session, "GET user/1")
+	require.ErrorIs(t, err, engine.ErrWrongType)
+
+	_, err = computer.Process(context.Background(), session, "HSET user/1 name bob email")
+	require.ErrorIs(t, err, compute.ErrWrongArgumentsCount)
+
+	// values json can not represent are rejected before they are written
+	_, err = computer.Process(context.Background(), session, "JSON.NUMINCRBY doc $.visits inf")
+	require.ErrorIs(t, err, engine.ErrJSONNotFinite)
+	assert.Equal(t, int64(7), computer.Revision())
+}
+
+func TestComputerBlocking(t *testing.T) {
+	t.Parallel()
+
+	computer := compute.NewComputer(engine.New())
+	blocked, writer := compute.NewSession(), compute.NewSession()
+
+	popped := make(chan string)
+
+	go func() {
+		result, _ := computer.Process(context.Background(), blocked, "BLPOP jobs 0")
+		popped <- result
+	}()
+
+	// the blocked pop does not hold the write lock and is stamped right after the push
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, "rev 1 1", process(t, computer, writer, "RPUSH jobs a"))
+	assert.Equal(t, "rev 2 jobs a", <-popped)
+
+	assert.Equal(t, "rev 2", process(t, computer, writer, "BLPOP jobs 0.01"))
+	assert.Equal(t, "rev 3", process(t, computer, writer, "XGROUP CREATE events workers $ MKSTREAM"))
+	assert.Equal(t, "3 stream -", process(t, computer, writer, "HISTORY events"))
+
+	go func() {
+		result, _ := computer.Process(context.Background(), blocked, "XREADGROUP GROUP workers w1 BLOCK 0 STREAMS events >")
+		popped <- result
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	id, ok := strings.CutPrefix(process(t, computer, writer, "XADD events login"), "rev 4 ")
+	require.True(t, ok)
+	assert.Equal(t, "rev 5 "+id+" login", <-popped)
+	assert.Equal(t, int64(5), computer.Revision())
+}
+
+func TestComputerNamespaces(t *testing.T) {
+	t.Parallel()
+
+	computer := compute.NewComputer(engine.New())
+	first, second := compute.NewSession(), compute.NewSession()
+
+	assert.Equal(t, "rev 1", process(t, computer, first, "SET key default"))
+	assert.Empty(t, process(t, computer, second, "SELECT billing"))
+	assert.Equal(t, "billing", second.Namespace())
+	assert.Empty(t, process(t, computer, second, "GET key"))
+	assert.Equal(t, "rev 2", process(t, computer, second, "SET key billing"))
+	assert.Equal(t, "rev 3", process(t, computer, second, "SET other billing"))
+
+	assert.Equal(t, "default", process(t, computer, first, "GET key"))
+	assert.Equal(t, "billing", process(t, computer, second, "GET key"))
+	assert.Equal(t, "1", process(t, computer, first, "DBSIZE"))
+	assert.Equal(t, "2", process(t, computer, first, "DBSIZE billing"))
+	assert.Equal(t, "0 1 billing 2", process(t, computer, first, "NAMESPACES"))
+	assert.Equal(t, "rev 4 2", process(t, computer, second, "FLUSH"))
+	assert.Equal(t, "0", process(t, computer, second, "DBSIZE"))
+	assert.Equal(t, "1", process(t, computer, second, "DBSIZE 0"))
+
+	// namespaces are created by the first write only
+	third := compute.NewSession()
+	assert.Empty(t, process(t, computer, third, "SELECT unknown"))
+	assert.Empty(t, process(t, computer, third, "GET key"))
+	assert.Equal(t, "0", process(t, computer, third, "DBSIZE"))
+	assert.Equal(t, "0 1 billing 0", process(t, computer, third, "NAMESPACES"))
+	assert.Equal(t, "rev 5", process(t, computer, third, "SET key unknown"))
+	assert.Equal(t, "0 1 billing 0 unknown 1", process(t, computer, third, "NAMESPACES"))
+}
+
+func TestComputerWatch(t *testing.T) {
+	t.Parallel()
+
+	computer := compute.NewComputer(engine.New())
+	watcher, writer := compute.NewSession(), compute.NewSession()
+
+	// sessions receive pushed messages only once they watch
+	assert.Equal(t, "0", process(t, computer, watcher, "UNWATCH"))
+	assert.False(t, watcher.Subscribed())
+	assert.Nil(t, watcher.Pushes())
+
+	assert.Equal(t, "1", process(t, computer, watcher, "WATCH user/"))
+	assert.True(t, watcher.Subscribed())
+	assert.False(t, writer.Subscribed())
+	assert.Equal(t, "rev 1", process(t, computer, writer, "SET user/1 bob"))
+	assert.Equal(t, "rev 2", process(t, computer, writer, "SET order/1 book"))
+	assert.Equal(t, "rev 3 1", process(t, computer, writer, "HSET user/2 name alice"))
+	assert.Equal(t, "rev 4", process(t, computer, writer, "DEL user/1"))
+
+	// writes that change nothing keep the revision and notify no watcher
+	assert.Equal(t, "rev 4", process(t, computer, writer, "LPOP user/3"))
+	assert.Equal(t, "rev 4", process(t, computer, writer, "DEL user/1"))
+	assert.Equal(t, "rev 4 0", process(t, computer, writer, "HDEL user/2 email"))
+	assert.Equal(t, "rev 4 0", process(t, computer, writer, "SREM user/4 admin"))
+	assert.Equal(t, "rev 4 0", process(t, computer, writer, "FLUSH unknown"))
+
+	assert.Equal(t, "rev 5 2", process(t, computer, writer, "FLUSH"))
+
+	assert.Equal(t, "watch 1 set user/1", <-watcher.Pushes())
+	assert.Equal(t, "watch 3 hset user/2", <-watcher.Pushes())
+	assert.Equal(t, "watch 4 del user/1", <-watcher.Pushes())
+	assert.Equal(t, "watch 5 flush", <-watcher.Pushes())
+
+	assert.Equal(t, "0", process(t, computer, watcher, "UNWATCH"))
+	assert.Equal(t, "rev 6", process(t, computer, writer, "SET user/1 bob"))
+	assert.Empty(t, watcher.Pushes())
+	assert.Equal(t, int64(6), computer.Revision())
+
+	assert.Equal(t, "1", process(t, computer, watcher, "WATCH * FROM 4"))
+	assert.Equal(t, "watch 4 del user/1", <-watcher.Pushes())
+	assert.Equal(t, "watch 5 flush", <-watcher.Pushes())
+	assert.Equal(t, "watch 6 set user/1", <-watcher.Pushes())
+
+	_, err := computer.Process(context.Background(), watcher, "WATCH * SINCE 4")
+	require.ErrorIs(t, err, compute.ErrSyntax)
+}
+
+func TestComputerHistory(t *testing.T) {
+	t.Parallel()
+
+	computer := compute.NewComputer(engine.New())
+	session := compute.NewSession()
+
+	assert.Equal(t, "rev 1", process(t, computer, session, "SET key a"))
+	assert.Equal(t, "rev 2", process(t, computer, session, "SET key b"))
+	assert.Equal(t, "rev 3 1", process(t, computer, session, "FLUSH"))
+	assert.Equal(t, "rev 4 1", process(t, computer, session, "RPUSH key c"))
+
+	assert.Equal(t, "a", process(t, computer, session, "GET key AT 1"))
+	assert.Equal(t, "b", process(t, computer, session, "GET key AT 2"))
+	assert.Empty(t, process(t, computer, session, "GET key AT 3"))
+	assert.Equal(t, "1 string a 2 string b 3 none - 4 list -", process(t, computer, session, "HISTORY key"))
+
+	assert.Empty(t, process(t, computer, session, "COMPACT 3"))
+	assert.Equal(t, "4 list -", process(t, computer, session, "HISTORY key"))
+
+	_, err := computer.Process(context.Background(), session, "GET key AT 2")
+	require.ErrorIs(t, err, engine.ErrCompacted)
+
+	// watchers resume from the revisions the history still retains only
+	_, err = computer.Process(context.Background(), compute.NewSession(), "WATCH * FROM 2")
+	require.ErrorIs(t, err, watch.ErrCompacted)
+	assert.Equal(t, "1", process(t, computer, compute.NewSession(), "WATCH * FROM 3"))
+
+	_, err = computer.Process(context.Background(), session, "GET key SINCE 2")
+	require.ErrorIs(t, err, compute.ErrSyntax)
+	_, err = computer.Process(context.Background(), session, "GET key MAXLAG soon")
+	require.ErrorIs(t, err, compute.ErrInvalidDuration)
+
+	_, err = computer.Process(context.Background(), session, "GET key MINREV")
+	require.ErrorIs(t, err, compute.ErrWrongArgumentsCount)
+}
+
+func TestComputerLoad(t *testing.T) {
+	t.Parallel()
+
+	source := compute.NewComputer(engine.New())
+	session := compute.NewSession()
+
+	assert.Equal(t, "rev 1", process(t, source, session, "SET key a"))
+	assert.Equal(t, "rev 2 1", process(t, source, session, "RPUSH list b"))
+
+	revision, entries, err := source.Snapshot()
+	require.NoError(t, err)
+
+	computer := compute.NewComputer(engine.New())
+
+	assert.Equal(t, "rev 1", process(t, computer, session, "SET key x"))
+	assert.Equal(t, "rev 2", process(t, computer, session, "SET stale y"))
+	assert.Equal(t, "rev 3", process(t, computer, session, "SET key z"))
+
+	require.NoError(t, computer.Load(revision, entries))
+
+	assert.Equal(t, int64(2), computer.Revision())
+	assert.Equal(t, "a", process(t, computer, session, "GET key"))
+	assert.Empty(t, process(t, computer, session, "GET stale"))
+	assert.Equal(t, "b", process(t, computer, session, "LRANGE list 0 -1"))
+
+	// the history of the database starts over at the loaded revision
+	assert.Equal(t, "2 string a", process(t, computer, session, "HISTORY key"))
+
+	_, err = computer.Process(context.Background(), compute.NewSession(), "WATCH * FROM 1")
+	require.ErrorIs(t, err, watch.ErrCompacted)
+
+	watcher := compute.NewSession()
+	assert.Equal(t, "1", process(t, computer, watcher, "WATCH * FROM 2"))
+	assert.Empty(t, watcher.Pushes())
+
+	assert.Equal(t, "rev 3", process(t, computer, session, "SET key c"))
+	assert.Equal(t, "watch 3 set key", <-watcher.Pushes())
+}
+
+func TestComputerLeases(t *testing.T) {
+	t.Parallel()
+
+	computer := compute.NewComputer(engine.New())
+	session := compute.NewSession()
+
+	assert.Equal(t, "1", process(t, computer, session, "WATCH service/"))
+	assert.Equal(t, "rev 0 1", process(t, computer, session, "LEASE GRANT 0.05"))
+	assert.Equal(t, "rev 0 2", process(t, computer, session, "LEASE GRANT 60"))
+	assert.Equal(t, "rev 1", process(t, computer, session, "SET service/1 addr1 LEASE 1"))
+	assert.Equal(t, "rev 2", process(t, computer, session, "SET service/2 addr2 LEASE 1"))
+	assert.Equal(t, "rev 3", process(t, computer, session, "SET service/3 addr3 LEASE 2"))
+	assert.Equal(t, "rev 4", process(t, computer, session, "SET service/2 addr2"))
+
+	_, err := computer.Process(context.Background(), session, "SET service/4 addr4 LEASE 3")
+	require.ErrorIs(t, err, lease.ErrLeaseNotFound)
+
+	for range 4 {
+		<-session.Pushes()
+	}
+
+	assert.Equal(t, "watch 5 expire service/1", <-session.Pushes())
+	assert.Empty(t, process(t, computer, session, "GET service/1"))
+	assert.Equal(t, "addr2", process(t, computer, session, "GET service/2"))
+
+	assert.Equal(t, "rev 5 60", process(t, computer, session, "LEASE KEEPALIVE 2"))
+	assert.Equal(t, "rev 6 1", process(t, computer, session, "LEASE REVOKE 2"))
+	assert.Equal(t, "watch 6 del service/3", <-session.Pushes())
+	assert.Empty(t, process(t, computer, session, "GET service/3"))
+
+	_, err = computer.Process(context.Background(), session, "LEASE KEEPALIVE 2")
+	require.ErrorIs(t, err, lease.ErrLeaseNotFound)
+}
+
+func TestComputerLocks(t *testing.T) {
+	t.Parallel()
+
+	computer := compute.NewComputer(engine.New())
+	first, second := compute.NewSession(), compute.NewSession()
+
+	assert.Equal(t, "1", process(t, computer, first, "LOCK job 60"))
+	assert.Empty(t, process(t, computer, second, "LOCK job 60"))
+	assert.Empty(t, process(t, computer, first, "LOCK.RENEW job 1 60"))
+
+	_, err := computer.Process(context.Background(), second, "UNLOCK job 2")
+	require.ErrorIs(t, err, lock.ErrNotHeld)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		computer.CloseSession(first)
+	}()
+
+	assert.Equal(t, "2", process(t, computer, second, "LOCK job 60 WAIT 5"))
+	assert.Empty(t, process(t, computer, second, "UNLOCK job 2"))
+}
+
+func TestComputerQueue(t *testing.T) {
+	t.Parallel()
+
+	computer := compute.NewComputer(engine.New())
+	session := compute.NewSession()
+
+	assert.Equal(t, "rev 1", process(t, computer, session, "Q.CREATE jobs 1"))
+	assert.Equal(t, "rev 2 jobs/1", process(t, computer, session, "Q.PUSH jobs send email to bob"))
+	assert.Equal(t, "rev 3 jobs/1 send email to bob", process(t, computer, session, "Q.POP jobs 0.01"))
+	// popping an empty queue changes nothing
+	assert.Equal(t, "rev 3", process(t, computer, session, "Q.POP jobs 30"))
+
+	time.Sleep(20 * time.Millisecond)
+
+	assert.Equal(t, "rev 3 0", process(t, computer, session, "Q.ACK jobs/1"))
+	assert.Equal(t, "1", process(t, computer, session, "WATCH jobs.dead"))
+	assert.Equal(t, "rev 4", process(t, computer, session, "Q.POP jobs 30"))
+	assert.Equal(t, "watch 4 q.deadletter jobs.dead", <-session.Pushes())
+	assert.Equal(t, "rev 5 jobs.dead/1 send email to bob", process(t, computer, session, "Q.POP jobs.dead 30"))
+	assert.Equal(t, "rev 6 1", process(t, computer, session, "Q.ACK jobs.dead/1"))
+}
+
+func TestComputerRateLimit(t *testing.T) {
+	t.Parallel()
+
+	computer := compute.NewComputer(engine.New())
+	session := compute.NewSession()
+
+	assert.Equal(t, "1 0 0", process(t, computer, session, "RATELIMIT api 1 0 1"))
+	assert.Equal(t, "0 0 -1", process(t, computer, session, "RATELIMIT api 1 0 1"))
+	assert.Equal(t, "1 1 0", process(t, computer, session, "RATELIMIT.WINDOW search 2 60 1"))
+
+	// checks are not recorded as mutations
+	assert.Equal(t, int64(0), computer.Revision())
+
+	_, err := computer.Process(context.Background(), session, "RATELIMIT.WINDOW search 2 0 1")
+	require.ErrorIs(t, err, compute.ErrInvalidNumber)
+
+	_, err = computer.Process(context.Background(), session, "RATELIMIT search 2 1 1")
+	require.ErrorIs(t, err, engine.ErrWrongType)
+}
+
+func TestComputerQuorum(t *testing.T) {
+	t.Parallel()
+
+	computer := compute.NewComputer(engine.New())
+	session := compute.NewSession()
+
+	assert.Equal(t, "rev 1", process(t, computer, session, "SET key value"))
+	assert.Equal(t, "0", process(t, computer, session, "WAIT 0 0"))
+
+	_, err := computer.Process(context.Background(), session, "WAIT 1 0.01")
+	require.ErrorIs(t, err, compute.ErrNoQuorum)
+
+	computer.SetQuorum(1, 10*time.Millisecond)
+
+	_, err = computer.Process(context.Background(), session, "SET key other")
+	require.ErrorIs(t, err, compute.ErrNoQuorum)
+	assert.ErrorContains(t, err, "applied at revision 2")
+
+	// the write is applied even though it was not replicated in time
+	assert.Equal(t, "other", process(t, computer, session, "GET key"))
+
+	// writes changing nothing do not wait for replicas
+	assert.Equal(t, "rev 2", process(t, computer, session, "DEL missing"))
+	assert.Equal(t, "rev 2", process(t, computer, session, "BLPOP list 0.01"))
+}
+
+func TestComputerCluster(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ids := []string{"a", "b", "c"}
+	network := raft.NewNetwork(time.Millisecond)
+	computers := make(map[string]*compute.Computer)
+	nodes := make(map[string]*raft.Node)
+
+	for _, id := range ids {
+		computer := compute.NewComputer(engine.New())
+		node, err := raft.NewNode(raft.Config{
+			ID:                id,
+			Members:           ids,
+			ElectionTimeout:   100 * time.Millisecond,
+			HeartbeatInterval: 20 * time.Millisecond,
+			SnapshotEntries:   3,
+		}, computer.StateMachine(), network.Transport(id), logger.NewDiscardLogger())
+		require.NoError(t, err)
+
+		computer.SetConsensus(node)
+		network.Add(node)
+
+		computers[id], nodes[id] = computer, node
+
+		go node.Run(ctx)
+	}
+
+	var leader string
+
+	require.Eventually(t, func() bool {
+		leader = nodes["a"].Leader()
+
+		return leader != "" && nodes["b"].Leader() == leader && nodes["c"].Leader() == leader
+	}, 5*time.Second, 5*time.Millisecond)
+
+	session := compute.NewSession()
+
+	assert.Equal(t, "rev 1", process(t, computers[leader], session, "SET name bob"))
+	assert.Equal(t, "rev 2 2", process(t, computers[leader], session, "RPUSH jobs a b"))
+	assert.Equal(t, "bob", process(t, computers[leader], session, "GET name"))
+
+	_, err := computers[leader].Process(context.Background(), session, "HSET name field value")
+	require.ErrorIs(t, err, engine.ErrWrongType)
+
+	_, err = computers[leader].Process(context.Background(), session, "BLPOP jobs 0")
+	require.ErrorIs(t, err, compute.ErrClusterUnsupported)
+
+	_, err = computers[leader].Process(context.Background(), session, "XREADGROUP GROUP g c BLOCK 0 STREAMS events >")
+	require.ErrorIs(t, err, compute.ErrClusterUnsupported)
+
+	// reading without waiting is executed by every member
+	assert.Equal(t, "rev 3", process(t, computers[leader], session, "XGROUP CREATE events g $ MKSTREAM"))
+	assert.Equal(t, "rev 3", process(t, computers[leader], session, "XREADGROUP GROUP g c STREAMS events >"))
+
+	assert.Contains(t, process(t, computers[leader], session, "TS.ADD temp * 20"), "rev 4 ")
+
+	for _, id := range ids {
+		assert.Eventually(t, func() bool {
+			result, err := computers[id].Process(context.Background(), compute.NewSession(), "LRANGE jobs 0 -1")
+
+			return err == nil && result == "a b"
+		}, 5*time.Second, 5*time.Millisecond)
+
+		// time dependent commands are executed at the time of the leader on every member
+		assert.Equal(t,
+			process(t, computers[leader], session, "TS.RANGE temp - +"),
+			process(t, computers[id], compute.NewSession(), "TS.RANGE temp - +"),
+		)
+
+		if id != leader {
+			_, err = computers[id].Process(context.Background(), session, "SET name alice")
+			require.ErrorIs(t, err, raft.ErrNotLeader)
+			assert.Contains(t, err.Error(), leader)
+		}
+	}
+
+	_, err = computers[leader].Process(context.Background(), session, "CLUSTER.ADD d")
+	require.ErrorIs(t, err, compute.ErrAdminDisabled)
+
+	computers[leader].AllowAdmin(true)
+
+	_, err = computers[leader].Process(context.Background(), session, "CLUSTER.ADD d")
+	require.NoError(t, err)
+	assert.Contains(t, process(t, computers[leader], session, "INFO"), "members a,b,c,d")
+}
@@ -0,0 +1,77 @@
+package compute
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+)
+
+var (
+	ErrFailoverUnsupported = errors.New("failover is not supported by the replication role")
+	ErrAdminDisabled       = errors.New("admin commands are disabled")
+)
+
+// adminCommands change the replication role, the cluster members or the slots of the
+// database, clients may run them only if admin commands are allowed.
+func adminCommands() map[parser.CommandType]bool {
+	return map[parser.CommandType]bool{
+		parser.CommandReplicaOf:     true,
+		parser.CommandPromote:       true,
+		parser.CommandClusterAdd:    true,
+		parser.CommandClusterRemove: true,
+		parser.CommandSlotsAssign:   true,
+		parser.CommandSlotsMigrate:  true,
+	}
+}
+
+// Failover switches the replication role of the database.
+type Failover interface {
+	Promote(ctx context.Context, replicas []string) error
+	ReplicaOf(address string) error
+}
+
+// AllowAdmin lets clients run admin commands, they are rejected by default.
+func (c *Computer) AllowAdmin(allow bool) {
+	c.replicationMutex.Lock()
+	defer c.replicationMutex.Unlock()
+
+	c.admin = allow
+}
+
+func (c *Computer) isAdminAllowed() bool {
+	c.replicationMutex.RLock()
+	defer c.replicationMutex.RUnlock()
+
+	return c.admin
+}
+
+// computeFailover handles REPLICAOF address, which makes the database a replica of the
+// primary at address, and REPLICAOF NO ONE or PROMOTE [replica...], which make it a primary
+// and re-point the given replicas to it.
+func (c *Computer) computeFailover(ctx context.Context, command parser.Command) (string, error) {
+	c.replicationMutex.RLock()
+	failover, ok := c.replication.(Failover)
+	c.replicationMutex.RUnlock()
+
+	if !ok {
+		return "", ErrFailoverUnsupported
+	}
+
+	if command.Type == parser.CommandReplicaOf {
+		if strings.EqualFold(command.Key, "NO") && len(command.Args) == 1 && strings.EqualFold(command.Args[0], "ONE") {
+			return "", failover.Promote(ctx, nil)
+		}
+
+		return "", failover.ReplicaOf(command.Key)
+	}
+
+	var replicas []string
+
+	if command.Key != "" {
+		replicas = append([]string{command.Key}, command.Args...)
+	}
+
+	return "", failover.Promote(ctx, replicas)
+}
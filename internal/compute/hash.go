@@ -0,0 +1,56 @@
+package compute
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+)
+
+type HashStorage interface {
+	HSet(key string, fields map[string]string) (int, error)
+	HGet(key, field string) (string, error)
+	HDel(key string, fields []string) (int, error)
+	HGetAll(key string) (map[string]string, error)
+}
+
+func (c *Computer) computeHash(storage StorageInterface, command parser.Command) (string, error) {
+	switch command.Type {
+	case parser.CommandHSet:
+		if len(command.Args)%2 != 0 {
+			return "", ErrWrongArgumentsCount
+		}
+
+		fields := make(map[string]string, len(command.Args)/2)
+		for i := 0; i < len(command.Args); i += 2 {
+			fields[command.Args[i]] = command.Args[i+1]
+		}
+
+		added, err := storage.HSet(command.Key, fields)
+
+		return strconv.Itoa(added), err
+	case parser.CommandHGet:
+		return storage.HGet(command.Key, command.Args[0])
+	case parser.CommandHDel:
+		removed, err := storage.HDel(command.Key, command.Args)
+
+		return strconv.Itoa(removed), err
+	case parser.CommandHGetAll:
+		fields, err := storage.HGetAll(command.Key)
+		if err != nil {
+			return "", err
+		}
+
+		names := slices.Sorted(maps.Keys(fields))
+		pairs := make([]string, 0, 2*len(names)) //nolint: mnd // field and value
+		for _, name := range names {
+			pairs = append(pairs, name, fields[name])
+		}
+
+		return strings.Join(pairs, " "), nil
+	}
+
+	return "", nil
+}
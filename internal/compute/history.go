@@ -0,0 +1,71 @@
+package compute
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+	"github.com/pingvincible/kvdatabase/internal/storage/engine"
+)
+
+const (
+	historyAt = "AT"
+
+	// historyNoValue stands for the value of versions that do not hold a string.
+	historyNoValue = "-"
+)
+
+type HistoryStorage interface {
+	Commit(revision int64, keys []engine.Key)
+	GetAt(key string, revision int64) (string, error)
+	History(key string) []engine.Version
+	Compact(revision int64) error
+}
+
+// computeHistory handles GET key [AT revision] [MAXLAG duration] [MINREV revision],
+// HISTORY key and COMPACT revision.
+// HISTORY replies with a revision, type and value triple per version, the value
+// of versions not holding a string is -.
+func (c *Computer) computeHistory(storage StorageInterface, command parser.Command) (string, error) {
+	switch command.Type {
+	case parser.CommandGet:
+		options, err := parseGetOptions(command.Args)
+		if err != nil {
+			return "", err
+		}
+
+		err = c.checkStaleness(options)
+		if err != nil {
+			return "", err
+		}
+
+		if !options.hasAt {
+			return storage.Get(command.Key)
+		}
+
+		return storage.GetAt(command.Key, options.at)
+	case parser.CommandHistory:
+		versions := storage.History(command.Key)
+		fields := make([]string, 0, 3*len(versions)) //nolint: mnd // revision, type and value
+
+		for _, version := range versions {
+			value := version.Value
+			if version.Type != engine.TypeString {
+				value = historyNoValue
+			}
+
+			fields = append(fields, strconv.FormatInt(version.Revision, 10), string(version.Type), value)
+		}
+
+		return strings.Join(fields, " "), nil
+	case parser.CommandCompact:
+		revision, err := parseInt64(command.Key)
+		if err != nil {
+			return "", err
+		}
+
+		return "", storage.Compact(revision)
+	}
+
+	return "", nil
+}
@@ -0,0 +1,49 @@
+package compute
+
+import (
+	"strconv"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+)
+
+const jsonRootPath = "$"
+
+type JSONStorage interface {
+	JSONSet(key, path, raw string) error
+	JSONGet(key, path string) (string, error)
+	JSONDel(key, path string) (int, error)
+	JSONNumIncrBy(key, path string, delta float64) (float64, error)
+}
+
+func (c *Computer) computeJSON(storage StorageInterface, command parser.Command) (string, error) {
+	switch command.Type {
+	case parser.CommandJSONSet:
+		return "", storage.JSONSet(command.Key, command.Args[0], command.Args[1])
+	case parser.CommandJSONGet:
+		return storage.JSONGet(command.Key, jsonPath(command.Args))
+	case parser.CommandJSONDel:
+		removed, err := storage.JSONDel(command.Key, jsonPath(command.Args))
+
+		return strconv.Itoa(removed), err
+	case parser.CommandJSONNumIncrBy:
+		delta, err := parseFloat(command.Args[1])
+		if err != nil {
+			return "", err
+		}
+
+		result, err := storage.JSONNumIncrBy(command.Key, command.Args[0], delta)
+
+		return formatFloat(result), err
+	}
+
+	return "", nil
+}
+
+// jsonPath returns the optional path argument, defaulting to the document root.
+func jsonPath(args []string) string {
+	if len(args) == 0 {
+		return jsonRootPath
+	}
+
+	return args[0]
+}
@@ -0,0 +1,132 @@
+package compute
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+	"github.com/pingvincible/kvdatabase/internal/lease"
+	"github.com/pingvincible/kvdatabase/internal/storage/engine"
+	"github.com/pingvincible/kvdatabase/internal/watch"
+)
+
+const (
+	leaseOption = "LEASE"
+
+	leaseGrant     = "GRANT"
+	leaseKeepAlive = "KEEPALIVE"
+	leaseRevoke    = "REVOKE"
+	leaseTTL       = "TTL"
+
+	opExpire = "expire"
+)
+
+// computeSetValue handles SET key value [LEASE id], a key set without a lease is detached from its lease.
+func (c *Computer) computeSetValue(storage StorageInterface, session *Session, command parser.Command) (string, error) {
+	key := lease.Key{Namespace: session.namespace, Name: command.Key}
+
+	switch len(command.Args) {
+	case 0:
+		c.leases.Detach(key)
+	case 2: //nolint: mnd // LEASE id
+		if !strings.EqualFold(command.Args[0], leaseOption) {
+			return "", ErrSyntax
+		}
+
+		id, err := parseInt64(command.Args[1])
+		if err != nil {
+			return "", err
+		}
+
+		err = c.leases.Attach(id, key)
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", ErrWrongArgumentsCount
+	}
+
+	storage.Set(command.Key, command.Value)
+
+	return "", nil
+}
+
+// computeLease handles LEASE GRANT ttl, LEASE KEEPALIVE id, LEASE TTL id and LEASE REVOKE id.
+// GRANT replies with the lease ID, KEEPALIVE and TTL with seconds to live
+// and REVOKE with the number of deleted keys.
+func (c *Computer) computeLease(command parser.Command) (string, error) {
+	if len(command.Args) != 1 {
+		return "", ErrWrongArgumentsCount
+	}
+
+	if strings.EqualFold(command.Key, leaseGrant) {
+		ttl, err := parseSeconds(command.Args[0])
+		if err != nil || ttl <= 0 {
+			return "", ErrInvalidNumber
+		}
+
+		return strconv.FormatInt(c.leases.Grant(ttl), 10), nil
+	}
+
+	id, err := parseInt64(command.Args[0])
+	if err != nil {
+		return "", err
+	}
+
+	switch strings.ToUpper(command.Key) {
+	case leaseKeepAlive:
+		ttl, err := c.leases.KeepAlive(id)
+		if err != nil {
+			return "", err
+		}
+
+		return formatFloat(ttl.Seconds()), nil
+	case leaseTTL:
+		ttl, err := c.leases.TimeToLive(id)
+		if err != nil {
+			return "", err
+		}
+
+		return formatFloat(ttl.Seconds()), nil
+	case leaseRevoke:
+		keys, err := c.leases.Revoke(id)
+		if err != nil {
+			return "", err
+		}
+
+		c.deleteLeaseKeys(keys, opDel)
+
+		return strconv.Itoa(len(keys)), nil
+	default:
+		return "", ErrSyntax
+	}
+}
+
+// expireLease deletes keys of the lease id if it has expired, all of them in a single revision.
+func (c *Computer) expireLease(id int64) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	keys, ok := c.leases.Expire(id)
+	if !ok {
+		return
+	}
+
+	c.deleteLeaseKeys(keys, opExpire)
+
+	// deleted keys have no values to dump, stamping them does not fail
+	_ = c.stamp(nil)
+}
+
+// deleteLeaseKeys deletes all keys of a removed lease at once and adds the changes to the running
+// mutation. It must be called with the write lock held.
+func (c *Computer) deleteLeaseKeys(keys []lease.Key, op string) {
+	names := make([]engine.Key, 0, len(keys))
+	for _, key := range keys {
+		names = append(names, engine.Key{Namespace: key.Namespace, Name: key.Name})
+	}
+
+	for _, key := range c.storage.DeleteKeys(names) {
+		c.changed = append(c.changed, watch.Event{Namespace: key.Namespace, Key: key.Name, Op: op})
+	}
+}
@@ -0,0 +1,95 @@
+package compute
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+)
+
+type ListStorage interface {
+	LPush(key string, values []string) (int, error)
+	RPush(key string, values []string) (int, error)
+	LPop(key string) (string, bool, error)
+	RPop(key string) (string, bool, error)
+	LLen(key string) (int, error)
+	LRange(key string, start, stop int) ([]string, error)
+}
+
+func (c *Computer) computeList(ctx context.Context, storage StorageInterface, command parser.Command) (string, error) {
+	switch command.Type {
+	case parser.CommandLPush:
+		length, err := storage.LPush(command.Key, command.Args)
+
+		return strconv.Itoa(length), err
+	case parser.CommandRPush:
+		length, err := storage.RPush(command.Key, command.Args)
+
+		return strconv.Itoa(length), err
+	case parser.CommandLPop:
+		value, _, err := storage.LPop(command.Key)
+
+		return value, err
+	case parser.CommandRPop:
+		value, _, err := storage.RPop(command.Key)
+
+		return value, err
+	case parser.CommandLLen:
+		length, err := storage.LLen(command.Key)
+
+		return strconv.Itoa(length), err
+	case parser.CommandLRange:
+		return c.computeLRange(storage, command)
+	case parser.CommandBLPop:
+		return c.computeBLPop(ctx, storage, command)
+	}
+
+	return "", nil
+}
+
+func (c *Computer) computeLRange(storage StorageInterface, command parser.Command) (string, error) {
+	start, err := parseInt(command.Args[0])
+	if err != nil {
+		return "", err
+	}
+
+	stop, err := parseInt(command.Args[1])
+	if err != nil {
+		return "", err
+	}
+
+	values, err := storage.LRange(command.Key, start, stop)
+
+	return strings.Join(values, " "), err
+}
+
+// computeBLPop handles BLPOP key [key ...] timeout, the timeout is in seconds.
+func (c *Computer) computeBLPop(ctx context.Context, storage StorageInterface, command parser.Command) (string, error) {
+	keys := append([]string{command.Key}, command.Args[:len(command.Args)-1]...)
+
+	timeout, err := parseSeconds(command.Args[len(command.Args)-1])
+	if err != nil {
+		return "", err
+	}
+
+	var result string
+
+	err = c.block(ctx, storage, keys, timeout, func() (bool, error) {
+		for _, key := range keys {
+			value, ok, err := storage.LPop(key)
+			if err != nil || ok {
+				result = key + " " + value
+
+				return true, err
+			}
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,77 @@
+package compute
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+)
+
+const lockWait = "WAIT"
+
+// noLockWait makes LOCK give up at once if the lock is held.
+const noLockWait = -1
+
+// computeLock handles LOCK name ttl [WAIT timeout], UNLOCK name token and LOCK.RENEW name token ttl.
+// LOCK replies with the fencing token or an empty result if the lock was not acquired in time,
+// a zero timeout waits forever. Locks are released when the session is closed.
+func (c *Computer) computeLock(ctx context.Context, session *Session, command parser.Command) (string, error) {
+	switch command.Type {
+	case parser.CommandLock:
+		return c.computeLockAcquire(ctx, session, command)
+	case parser.CommandUnlock:
+		token, err := parseInt64(command.Args[0])
+		if err != nil {
+			return "", err
+		}
+
+		return "", c.locks.Unlock(command.Key, token)
+	case parser.CommandLockRenew:
+		token, err := parseInt64(command.Args[0])
+		if err != nil {
+			return "", err
+		}
+
+		ttl, err := parseSeconds(command.Args[1])
+		if err != nil || ttl <= 0 {
+			return "", ErrInvalidNumber
+		}
+
+		return "", c.locks.Renew(command.Key, token, ttl)
+	}
+
+	return "", nil
+}
+
+func (c *Computer) computeLockAcquire(ctx context.Context, session *Session, command parser.Command) (string, error) {
+	ttl, err := parseSeconds(command.Args[0])
+	if err != nil || ttl <= 0 {
+		return "", ErrInvalidNumber
+	}
+
+	timeout := time.Duration(noLockWait)
+
+	switch len(command.Args) {
+	case 1:
+	case 3: //nolint: mnd // ttl WAIT timeout
+		if !strings.EqualFold(command.Args[1], lockWait) {
+			return "", ErrSyntax
+		}
+
+		timeout, err = parseSeconds(command.Args[2])
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", ErrWrongArgumentsCount
+	}
+
+	token, ok, err := c.locks.Lock(ctx, command.Key, session, ttl, timeout)
+	if err != nil || !ok {
+		return "", err
+	}
+
+	return strconv.FormatInt(token, 10), nil
+}
@@ -0,0 +1,231 @@
+package compute
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/replication"
+	"github.com/pingvincible/kvdatabase/internal/slot"
+	"github.com/pingvincible/kvdatabase/internal/watch"
+)
+
+var ErrSlotNotServed = errors.New("slot is not served by the node")
+
+// BeginMigration marks keySlot as migrating to the node at target: commands of its keys
+// that were already moved fail with ErrAsk naming target.
+func (c *Computer) BeginMigration(keySlot int, target string) error {
+	c.slotsMutex.Lock()
+	defer c.slotsMutex.Unlock()
+
+	if c.slots == nil || c.slots.Owner(keySlot) != c.address {
+		return fmt.Errorf("%w: %d", ErrSlotNotServed, keySlot)
+	}
+
+	if c.migrating == nil {
+		c.migrating = make(map[int]string)
+	}
+
+	c.migrating[keySlot] = target
+
+	return nil
+}
+
+// MigrateKeys moves up to count keys of keySlot: send receives entries restoring them
+// on the target, they are deleted here once send succeeds. It returns the number of moved keys.
+func (c *Computer) MigrateKeys(keySlot, count int, send func([]replication.Entry) error) (int, error) {
+	c.migrationMutex.Lock()
+	defer c.migrationMutex.Unlock()
+
+	return c.moveKeys(keySlot, count, send)
+}
+
+// Handover moves the keys of keySlot left and assigns it to target once handover succeeds.
+// Commands of its keys wait for the handover.
+func (c *Computer) Handover(
+	keySlot int, target string, send func([]replication.Entry) error, handover func() error,
+) error {
+	c.migrationMutex.Lock()
+	defer c.migrationMutex.Unlock()
+
+	for {
+		moved, err := c.moveKeys(keySlot, slot.Count, send)
+		if err != nil {
+			return err
+		}
+
+		if moved == 0 {
+			break
+		}
+	}
+
+	err := handover()
+	if err != nil {
+		return err
+	}
+
+	c.slotsMutex.Lock()
+	defer c.slotsMutex.Unlock()
+
+	c.slots.Assign(slot.Range{From: keySlot, To: keySlot, Address: target})
+	delete(c.migrating, keySlot)
+
+	return nil
+}
+
+// moveKeys moves up to count keys of keySlot. It must be called with migrationMutex held,
+// so that commands do not change keys of the slot meanwhile.
+func (c *Computer) moveKeys(keySlot, count int, send func([]replication.Entry) error) (int, error) {
+	entries, err := c.slotEntries(keySlot, count)
+	if err != nil || len(entries) == 0 {
+		return 0, err
+	}
+
+	err = send(entries)
+	if err != nil {
+		return 0, err
+	}
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	for _, entry := range entries {
+		c.namespace(entry.Namespace, false).Delete(entry.Key)
+		c.changed = append(c.changed, watch.Event{Namespace: entry.Namespace, Key: entry.Key, Op: opDel})
+	}
+
+	err = c.stamp(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(entries), nil
+}
+
+// slotEntries returns entries restoring up to count keys of keySlot. Commands do not change
+// keys of the slot meanwhile, see moveKeys, so the write lock is not held: keys deleted by
+// commands without keys, e.g. FLUSH, are skipped.
+func (c *Computer) slotEntries(keySlot, count int) ([]replication.Entry, error) {
+	var entries []replication.Entry
+
+	revision, now := c.Revision(), time.Now()
+
+	for _, namespace := range c.storage.NamespaceNames() {
+		storage := c.namespace(namespace, false)
+
+		for {
+			keys, dumped := storage.SlotKeys(keySlot, count-len(entries)), len(entries)
+
+			for _, key := range keys {
+				value, ok, err := storage.Dump(key)
+				if err != nil {
+					return nil, fmt.Errorf("failed to dump %s: %w", key, err)
+				}
+
+				if ok {
+					entries = append(entries, replication.Entry{
+						Revision:  revision,
+						Time:      now,
+						Namespace: namespace,
+						Op:        opSet,
+						Key:       key,
+						Value:     value,
+					})
+				}
+			}
+
+			// no entries are returned only if the slot has no keys left
+			if len(keys) == 0 || len(entries) > dumped {
+				break
+			}
+		}
+
+		if len(entries) == count {
+			break
+		}
+	}
+
+	return entries, nil
+}
+
+// BeginImport lets commands preceded by ASKING access keys of keySlot, which is migrating
+// from the node at source. Only nodes serving slots, accepting writes and admin commands import.
+func (c *Computer) BeginImport(keySlot int, source string) error {
+	err := c.checkImport()
+	if err != nil {
+		return err
+	}
+
+	c.slotsMutex.Lock()
+	defer c.slotsMutex.Unlock()
+
+	if c.slots == nil {
+		return ErrNoSlots
+	}
+
+	if c.importing == nil {
+		c.importing = make(map[int]string)
+	}
+
+	c.importing[keySlot] = source
+
+	return nil
+}
+
+// Import restores keys moved from another node.
+func (c *Computer) Import(entries []replication.Entry) error {
+	err := c.checkImport()
+	if err != nil {
+		return err
+	}
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	for _, entry := range entries {
+		err := c.namespace(entry.Namespace, true).Restore(entry.Key, entry.Value)
+		if err != nil {
+			c.changed = nil
+
+			return fmt.Errorf("failed to restore %s: %w", entry.Key, err)
+		}
+
+		c.changed = append(c.changed, watch.Event{Namespace: entry.Namespace, Key: entry.Key, Op: opSet})
+	}
+
+	return c.stamp(nil)
+}
+
+// EndImport takes over keySlot once all its keys are imported.
+func (c *Computer) EndImport(keySlot int) error {
+	err := c.checkImport()
+	if err != nil {
+		return err
+	}
+
+	c.slotsMutex.Lock()
+	defer c.slotsMutex.Unlock()
+
+	if c.slots == nil {
+		return ErrNoSlots
+	}
+
+	c.slots.Assign(slot.Range{From: keySlot, To: keySlot, Address: c.address})
+	delete(c.importing, keySlot)
+
+	return nil
+}
+
+// checkImport rejects imports like admin commands and writes: a migration changes both
+// the keys and the slots served by the database.
+func (c *Computer) checkImport() error {
+	if !c.isAdminAllowed() {
+		return ErrAdminDisabled
+	}
+
+	if c.isReadOnly() {
+		return ErrReadOnly
+	}
+
+	return nil
+}
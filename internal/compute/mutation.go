@@ -0,0 +1,395 @@
+package compute
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+	"github.com/pingvincible/kvdatabase/internal/replication"
+	"github.com/pingvincible/kvdatabase/internal/storage/engine"
+	"github.com/pingvincible/kvdatabase/internal/watch"
+)
+
+const (
+	opSet = "set"
+	opDel = "del"
+
+	watchAllKeys = "*"
+	watchFrom    = "FROM"
+)
+
+func writeCommands() map[parser.CommandType]bool {
+	return map[parser.CommandType]bool{
+		parser.CommandSet:           true,
+		parser.CommandDel:           true,
+		parser.CommandFlush:         true,
+		parser.CommandHSet:          true,
+		parser.CommandHDel:          true,
+		parser.CommandLPush:         true,
+		parser.CommandRPush:         true,
+		parser.CommandLPop:          true,
+		parser.CommandRPop:          true,
+		parser.CommandBLPop:         true,
+		parser.CommandSAdd:          true,
+		parser.CommandSRem:          true,
+		parser.CommandZAdd:          true,
+		parser.CommandZRem:          true,
+		parser.CommandJSONSet:       true,
+		parser.CommandJSONDel:       true,
+		parser.CommandJSONNumIncrBy: true,
+		parser.CommandXAdd:          true,
+		parser.CommandXGroup:        true,
+		parser.CommandXReadGroup:    true,
+		parser.CommandXAck:          true,
+		parser.CommandPFAdd:         true,
+		parser.CommandPFMerge:       true,
+		parser.CommandBFReserve:     true,
+		parser.CommandBFAdd:         true,
+		parser.CommandTSCreate:      true,
+		parser.CommandTSAdd:         true,
+		parser.CommandSetBit:        true,
+		parser.CommandBitOp:         true,
+		parser.CommandLease:         true,
+		parser.CommandQCreate:       true,
+		parser.CommandQPush:         true,
+		parser.CommandQPop:          true,
+		parser.CommandQAck:          true,
+		parser.CommandQNack:         true,
+	}
+}
+
+// localWriteCommands change the store of this node only: they are rejected by replicas
+// but get no revision, are not watched and are not replicated. Rate limit checks are
+// too frequent to be recorded as mutations.
+func localWriteCommands() map[parser.CommandType]bool {
+	return map[parser.CommandType]bool{
+		parser.CommandRateLimit:       true,
+		parser.CommandRateLimitWindow: true,
+	}
+}
+
+// blocks reports whether command may wait for other clients: BLPOP and XREADGROUP reading
+// new entries with BLOCK. They release the write lock while waiting.
+func blocks(command parser.Command) bool {
+	switch command.Type { //nolint: exhaustive // other commands do not wait
+	case parser.CommandBLPop:
+		return true
+	case parser.CommandXReadGroup:
+		if len(command.Args) < 2 { //nolint: mnd // group consumer
+			return false
+		}
+
+		opts, rest, err := parseStreamReadOptions(command.Args[2:])
+
+		return err == nil && opts.Block && len(rest) == 3 && rest[2] == streamNewEntries
+	default:
+		return false
+	}
+}
+
+// execute runs command, serializing writes so that every mutation gets a revision
+// in the order it was applied.
+func (c *Computer) execute(ctx context.Context, session *Session, command parser.Command) (string, error) {
+	if localWriteCommands()[command.Type] {
+		if c.isReadOnly() {
+			return "", ErrReadOnly
+		}
+
+		return c.compute(ctx, session, command)
+	}
+
+	if !writeCommands()[command.Type] {
+		return c.compute(ctx, session, command)
+	}
+
+	if c.isReadOnly() {
+		return "", ErrReadOnly
+	}
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	result, err := c.compute(ctx, session, command)
+	if err != nil {
+		// drop changes collected before the command failed
+		c.changed = nil
+
+		return "", err
+	}
+
+	revision := c.revision
+
+	err = c.record(session, command, result)
+	if err != nil {
+		return "", err
+	}
+
+	// the session waits for its latest change only, not for writes that changed nothing
+	if c.revision != revision {
+		session.revision = c.revision
+	}
+
+	return withRevision(c.revision, result), nil
+}
+
+// block calls try until it reports done, waiting for changes of keys between attempts.
+// It must be called with the write lock held and releases it only while waiting, so the
+// mutation made by try is stamped in the order it was applied. A zero timeout waits
+// forever, an expired timeout is not an error. Cancelling ctx aborts the wait with its error,
+// migrating a slot of keys to another node aborts it with ErrTryAgain.
+func (c *Computer) block(
+	ctx context.Context, storage StorageInterface, keys []string, timeout time.Duration, try func() (bool, error),
+) error {
+	var expired <-chan time.Time
+
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		expired = timer.C
+	}
+
+	for {
+		wait, stop := storage.WaitFor(keys)
+
+		done, err := c.attempt(keys, try)
+		if err != nil || done {
+			stop()
+
+			return err
+		}
+
+		c.writeMutex.Unlock()
+
+		woken := false
+
+		select {
+		case <-wait:
+			woken = true
+		case <-expired:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+
+		stop()
+		c.writeMutex.Lock()
+
+		if !woken {
+			return err
+		}
+	}
+}
+
+// attempt calls try of a blocking command unless a slot of keys is migrating. Blocking commands
+// do not hold the migration lock while waiting, so that they do not hold up migrations, and take
+// it for each attempt instead: keys are moved either before or after try changes them.
+// It must be called with the write lock held.
+func (c *Computer) attempt(keys []string, try func() (bool, error)) (bool, error) {
+	for !c.migrationMutex.TryRLock() {
+		// migration takes the write lock while holding the migration lock
+		c.writeMutex.Unlock()
+		c.migrationMutex.RLock()
+		c.migrationMutex.RUnlock()
+		c.writeMutex.Lock()
+	}
+
+	defer c.migrationMutex.RUnlock()
+
+	if c.isMigrating(keys) {
+		return false, ErrTryAgain
+	}
+
+	return try()
+}
+
+// withRevision prefixes the result of a write command with the revision of the store
+// after the command: rev <revision> [result].
+func withRevision(revision int64, result string) string {
+	header := "rev " + strconv.FormatInt(revision, 10)
+	if result == "" {
+		return header
+	}
+
+	return header + " " + result
+}
+
+// Revision returns the revision of the latest mutation.
+func (c *Computer) Revision() int64 {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	return c.revision
+}
+
+// record stamps the changes made by a successful write command with the next revision.
+// It must be called with the write lock held.
+func (c *Computer) record(session *Session, command parser.Command, result string) error {
+	return c.stamp(changes(session, command, result))
+}
+
+// stamp applies the next revision to events and changes collected while executing
+// the mutation. It must be called with the write lock held.
+func (c *Computer) stamp(events []watch.Event) error {
+	events = append(events, c.changed...)
+	c.changed = nil
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	c.revision++
+
+	for i := range events {
+		events[i].Revision = c.revision
+	}
+
+	return c.commit(events)
+}
+
+// commit records the new versions of the changed keys, notifies watchers and appends
+// the changes to the replication log. It must be called with the write lock held.
+func (c *Computer) commit(events []watch.Event) error {
+	revision := events[0].Revision
+
+	keys := make([]engine.Key, 0, len(events))
+	for _, event := range events {
+		keys = append(keys, engine.Key{Namespace: event.Namespace, Name: event.Key})
+	}
+
+	c.storage.Commit(revision, keys)
+
+	for _, event := range events {
+		c.watchers.Publish(event)
+	}
+
+	if !c.log.Streamed() {
+		// there is nobody to dump the values for, replicas connecting later get a snapshot
+		c.log.Reset(revision)
+
+		return nil
+	}
+
+	entries := make([]replication.Entry, 0, len(events))
+	now := time.Now()
+
+	for _, event := range events {
+		entry := replication.Entry{
+			Revision:  event.Revision,
+			Time:      now,
+			Namespace: event.Namespace,
+			Op:        event.Op,
+			Key:       event.Key,
+		}
+
+		if event.Op != watch.OpFlush {
+			value, _, err := c.namespace(event.Namespace, false).Dump(event.Key)
+			if err != nil {
+				// replicas must not miss the change, they resync from a snapshot
+				c.log.Reset(revision)
+
+				return fmt.Errorf("failed to replicate %s: %w", event.Key, err)
+			}
+
+			entry.Value = value
+		}
+
+		entries = append(entries, entry)
+	}
+
+	c.log.Append(entries...)
+
+	return nil
+}
+
+// changes returns the key changes made by a successful write command, none if the
+// command left the store as it was, e.g. removed no member or popped an empty list.
+func changes(session *Session, command parser.Command, result string) []watch.Event {
+	event := watch.Event{Namespace: session.namespace, Key: command.Key, Op: strings.ToLower(string(command.Type))}
+
+	switch command.Type { //nolint: exhaustive // other commands change their key
+	case parser.CommandSet:
+		event.Op = opSet
+	case parser.CommandDel, parser.CommandLease:
+		// deleted keys and keys of revoked leases are collected while executing
+		return nil
+	case parser.CommandFlush:
+		if result == "0" {
+			return nil
+		}
+
+		if command.Key != "" {
+			event.Namespace = command.Key
+		}
+
+		event.Op, event.Key = watch.OpFlush, ""
+	case parser.CommandHDel, parser.CommandSAdd, parser.CommandSRem, parser.CommandZRem, parser.CommandJSONDel,
+		parser.CommandXAck, parser.CommandPFAdd, parser.CommandBFAdd:
+		// the result counts the changed members
+		if result == "0" {
+			return nil
+		}
+	case parser.CommandLPop, parser.CommandRPop, parser.CommandXReadGroup, parser.CommandQPop:
+		if result == "" {
+			return nil
+		}
+	case parser.CommandQAck, parser.CommandQNack:
+		if result != formatBool(true) {
+			return nil
+		}
+
+		event.Key, _, _ = engine.ParseMessageID(command.Key)
+	case parser.CommandBLPop:
+		if result == "" {
+			return nil
+		}
+
+		event.Key, _, _ = strings.Cut(result, " ")
+	case parser.CommandBitOp, parser.CommandXGroup:
+		event.Key = command.Args[0]
+	}
+
+	return []watch.Event{event}
+}
+
+// computeWatch handles WATCH prefix [FROM revision] and UNWATCH [prefix] for keys of the session
+// namespace, the * prefix stands for all keys. Both reply with the number of active watches.
+func (c *Computer) computeWatch(session *Session, command parser.Command) (string, error) {
+	prefix := command.Key
+	if prefix == watchAllKeys {
+		prefix = ""
+	}
+
+	if command.Type == parser.CommandUnwatch {
+		if !session.Subscribed() {
+			return "0", nil
+		}
+
+		return strconv.Itoa(c.watchers.Unwatch(session.subscriber, session.namespace, prefix)), nil
+	}
+
+	switch len(command.Args) {
+	case 0:
+		return strconv.Itoa(c.watchers.Watch(session.subscribe(), session.namespace, prefix)), nil
+	case 2: //nolint: mnd // FROM revision
+		if !strings.EqualFold(command.Args[0], watchFrom) {
+			return "", ErrSyntax
+		}
+	default:
+		return "", ErrWrongArgumentsCount
+	}
+
+	revision, err := parseInt64(command.Args[1])
+	if err != nil {
+		return "", err
+	}
+
+	watches, err := c.watchers.WatchFrom(session.subscribe(), session.namespace, prefix, revision)
+	if err != nil {
+		return "", fmt.Errorf("failed to watch from revision: %w", err)
+	}
+
+	return strconv.Itoa(watches), nil
+}
@@ -9,18 +9,212 @@ const (
 	CommandGet CommandType = "GET"
 	CommandDel CommandType = "DEL"
 
+	CommandHSet    CommandType = "HSET"
+	CommandHGet    CommandType = "HGET"
+	CommandHDel    CommandType = "HDEL"
+	CommandHGetAll CommandType = "HGETALL"
+
+	CommandLPush  CommandType = "LPUSH"
+	CommandRPush  CommandType = "RPUSH"
+	CommandLPop   CommandType = "LPOP"
+	CommandRPop   CommandType = "RPOP"
+	CommandLRange CommandType = "LRANGE"
+	CommandLLen   CommandType = "LLEN"
+	CommandBLPop  CommandType = "BLPOP"
+
+	CommandSAdd      CommandType = "SADD"
+	CommandSRem      CommandType = "SREM"
+	CommandSMembers  CommandType = "SMEMBERS"
+	CommandSIsMember CommandType = "SISMEMBER"
+	CommandSInter    CommandType = "SINTER"
+	CommandSUnion    CommandType = "SUNION"
+
+	CommandZAdd          CommandType = "ZADD"
+	CommandZRange        CommandType = "ZRANGE"
+	CommandZRangeByScore CommandType = "ZRANGEBYSCORE"
+	CommandZRank         CommandType = "ZRANK"
+	CommandZRem          CommandType = "ZREM"
+
+	CommandJSONSet       CommandType = "JSON.SET"
+	CommandJSONGet       CommandType = "JSON.GET"
+	CommandJSONDel       CommandType = "JSON.DEL"
+	CommandJSONNumIncrBy CommandType = "JSON.NUMINCRBY"
+
+	CommandXAdd       CommandType = "XADD"
+	CommandXRange     CommandType = "XRANGE"
+	CommandXRead      CommandType = "XREAD"
+	CommandXGroup     CommandType = "XGROUP"
+	CommandXReadGroup CommandType = "XREADGROUP"
+	CommandXAck       CommandType = "XACK"
+	CommandXPending   CommandType = "XPENDING"
+
+	CommandPFAdd     CommandType = "PFADD"
+	CommandPFCount   CommandType = "PFCOUNT"
+	CommandPFMerge   CommandType = "PFMERGE"
+	CommandBFReserve CommandType = "BF.RESERVE"
+	CommandBFAdd     CommandType = "BF.ADD"
+	CommandBFExists  CommandType = "BF.EXISTS"
+
+	CommandTSCreate CommandType = "TS.CREATE"
+	CommandTSAdd    CommandType = "TS.ADD"
+	CommandTSRange  CommandType = "TS.RANGE"
+
+	CommandSetBit   CommandType = "SETBIT"
+	CommandGetBit   CommandType = "GETBIT"
+	CommandBitCount CommandType = "BITCOUNT"
+	CommandBitOp    CommandType = "BITOP"
+	CommandBitPos   CommandType = "BITPOS"
+
+	CommandSelect     CommandType = "SELECT"
+	CommandFlush      CommandType = "FLUSH"
+	CommandDBSize     CommandType = "DBSIZE"
+	CommandNamespaces CommandType = "NAMESPACES"
+
+	CommandSubscribe    CommandType = "SUBSCRIBE"
+	CommandPSubscribe   CommandType = "PSUBSCRIBE"
+	CommandUnsubscribe  CommandType = "UNSUBSCRIBE"
+	CommandPUnsubscribe CommandType = "PUNSUBSCRIBE"
+	CommandPublish      CommandType = "PUBLISH"
+
+	CommandWatch   CommandType = "WATCH"
+	CommandUnwatch CommandType = "UNWATCH"
+
+	CommandHistory CommandType = "HISTORY"
+	CommandCompact CommandType = "COMPACT"
+
+	CommandLease CommandType = "LEASE"
+
+	CommandLock      CommandType = "LOCK"
+	CommandUnlock    CommandType = "UNLOCK"
+	CommandLockRenew CommandType = "LOCK.RENEW"
+
+	CommandQCreate CommandType = "Q.CREATE"
+	CommandQPush   CommandType = "Q.PUSH"
+	CommandQPop    CommandType = "Q.POP"
+	CommandQAck    CommandType = "Q.ACK"
+	CommandQNack   CommandType = "Q.NACK"
+
+	CommandRateLimit       CommandType = "RATELIMIT"
+	CommandRateLimitWindow CommandType = "RATELIMIT.WINDOW"
+
+	CommandInfo CommandType = "INFO"
+	CommandWait CommandType = "WAIT"
+
+	CommandReplicaOf CommandType = "REPLICAOF"
+	CommandPromote   CommandType = "PROMOTE"
+
+	CommandClusterAdd    CommandType = "CLUSTER.ADD"
+	CommandClusterRemove CommandType = "CLUSTER.REMOVE"
+
+	CommandSlots        CommandType = "SLOTS"
+	CommandSlotsAssign  CommandType = "SLOTS.ASSIGN"
+	CommandSlotsMigrate CommandType = "SLOTS.MIGRATE"
+	CommandAsking       CommandType = "ASKING"
+
 	CommandGetDelArgsCount = 1
 	CommandSetArgsCount    = 2
+
+	CommandHSetArgsCount    = 3
+	CommandHGetDelArgsCount = 2
+	CommandHGetAllArgsCount = 1
+
+	CommandPushArgsCount   = 2
+	CommandPopArgsCount    = 1
+	CommandLRangeArgsCount = 3
+	CommandLLenArgsCount   = 1
+	CommandBLPopArgsCount  = 2
+
+	CommandSAddRemArgsCount   = 2
+	CommandSMembersArgsCount  = 1
+	CommandSIsMemberArgsCount = 2
+	CommandSetsOpArgsCount    = 1
+
+	CommandZAddArgsCount   = 3
+	CommandZRangeArgsCount = 3
+	CommandZRankArgsCount  = 2
+	CommandZRemArgsCount   = 2
+
+	CommandJSONSetArgsCount       = 3
+	CommandJSONGetDelArgsCount    = 1
+	CommandJSONNumIncrByArgsCount = 3
+
+	CommandXAddArgsCount       = 2
+	CommandXRangeArgsCount     = 3
+	CommandXReadArgsCount      = 3
+	CommandXGroupArgsCount     = 4
+	CommandXReadGroupArgsCount = 6
+	CommandXAckArgsCount       = 3
+	CommandXPendingArgsCount   = 2
+
+	CommandPFAddArgsCount       = 1
+	CommandPFCountArgsCount     = 1
+	CommandPFMergeArgsCount     = 2
+	CommandBFReserveArgsCount   = 3
+	CommandBFAddExistsArgsCount = 2
+
+	CommandTSCreateArgsCount = 1
+	CommandTSAddArgsCount    = 3
+	CommandTSRangeArgsCount  = 3
+
+	CommandSetBitArgsCount   = 3
+	CommandGetBitArgsCount   = 2
+	CommandBitCountArgsCount = 1
+	CommandBitOpArgsCount    = 3
+	CommandBitPosArgsCount   = 2
+
+	CommandSelectArgsCount     = 1
+	CommandFlushArgsCount      = 0
+	CommandDBSizeArgsCount     = 0
+	CommandNamespacesArgsCount = 0
+
+	CommandSubscribeArgsCount   = 1
+	CommandUnsubscribeArgsCount = 0
+	CommandPublishArgsCount     = 2
+
+	CommandWatchArgsCount   = 1
+	CommandUnwatchArgsCount = 0
+
+	CommandHistoryArgsCount = 1
+	CommandCompactArgsCount = 1
+
+	CommandLeaseArgsCount = 2
+
+	CommandLockArgsCount      = 2
+	CommandUnlockArgsCount    = 2
+	CommandLockRenewArgsCount = 3
+
+	CommandQCreateArgsCount = 2
+	CommandQPushArgsCount   = 2
+	CommandQPopArgsCount    = 2
+	CommandQAckArgsCount    = 1
+	CommandQNackArgsCount   = 1
+
+	CommandRateLimitArgsCount       = 4
+	CommandRateLimitWindowArgsCount = 4
+
+	CommandInfoArgsCount = 0
+	CommandWaitArgsCount = 2
+
+	CommandReplicaOfArgsCount = 1
+	CommandPromoteArgsCount   = 0
+
+	CommandClusterMemberArgsCount = 1
+
+	CommandSlotsArgsCount        = 0
+	CommandSlotsAssignArgsCount  = 1
+	CommandSlotsMigrateArgsCount = 2
+	CommandAskingArgsCount       = 0
 )
 
 type Command struct {
 	Type  CommandType
 	Key   string
 	Value string
+	Args  []string
 }
 
 func (c *Command) String() string {
-	return fmt.Sprintf("Type: %s, %s=%s", c.Type, c.Key, c.Value)
+	return fmt.Sprintf("Type: %s, %s=%s, Args: %v", c.Type, c.Key, c.Value, c.Args)
 }
 
 func ArgsInCommand() map[CommandType]int {
@@ -28,5 +222,116 @@ func ArgsInCommand() map[CommandType]int {
 		CommandSet: CommandSetArgsCount,
 		CommandGet: CommandGetDelArgsCount,
 		CommandDel: CommandGetDelArgsCount,
+
+		CommandHSet:    CommandHSetArgsCount,
+		CommandHGet:    CommandHGetDelArgsCount,
+		CommandHDel:    CommandHGetDelArgsCount,
+		CommandHGetAll: CommandHGetAllArgsCount,
+
+		CommandLPush:  CommandPushArgsCount,
+		CommandRPush:  CommandPushArgsCount,
+		CommandLPop:   CommandPopArgsCount,
+		CommandRPop:   CommandPopArgsCount,
+		CommandLRange: CommandLRangeArgsCount,
+		CommandLLen:   CommandLLenArgsCount,
+		CommandBLPop:  CommandBLPopArgsCount,
+
+		CommandSAdd:      CommandSAddRemArgsCount,
+		CommandSRem:      CommandSAddRemArgsCount,
+		CommandSMembers:  CommandSMembersArgsCount,
+		CommandSIsMember: CommandSIsMemberArgsCount,
+		CommandSInter:    CommandSetsOpArgsCount,
+		CommandSUnion:    CommandSetsOpArgsCount,
+
+		CommandZAdd:          CommandZAddArgsCount,
+		CommandZRange:        CommandZRangeArgsCount,
+		CommandZRangeByScore: CommandZRangeArgsCount,
+		CommandZRank:         CommandZRankArgsCount,
+		CommandZRem:          CommandZRemArgsCount,
+
+		CommandJSONSet:       CommandJSONSetArgsCount,
+		CommandJSONGet:       CommandJSONGetDelArgsCount,
+		CommandJSONDel:       CommandJSONGetDelArgsCount,
+		CommandJSONNumIncrBy: CommandJSONNumIncrByArgsCount,
+
+		CommandXAdd:       CommandXAddArgsCount,
+		CommandXRange:     CommandXRangeArgsCount,
+		CommandXRead:      CommandXReadArgsCount,
+		CommandXGroup:     CommandXGroupArgsCount,
+		CommandXReadGroup: CommandXReadGroupArgsCount,
+		CommandXAck:       CommandXAckArgsCount,
+		CommandXPending:   CommandXPendingArgsCount,
+
+		CommandPFAdd:     CommandPFAddArgsCount,
+		CommandPFCount:   CommandPFCountArgsCount,
+		CommandPFMerge:   CommandPFMergeArgsCount,
+		CommandBFReserve: CommandBFReserveArgsCount,
+		CommandBFAdd:     CommandBFAddExistsArgsCount,
+		CommandBFExists:  CommandBFAddExistsArgsCount,
+
+		CommandTSCreate: CommandTSCreateArgsCount,
+		CommandTSAdd:    CommandTSAddArgsCount,
+		CommandTSRange:  CommandTSRangeArgsCount,
+
+		CommandSetBit:   CommandSetBitArgsCount,
+		CommandGetBit:   CommandGetBitArgsCount,
+		CommandBitCount: CommandBitCountArgsCount,
+		CommandBitOp:    CommandBitOpArgsCount,
+		CommandBitPos:   CommandBitPosArgsCount,
+
+		CommandSelect:     CommandSelectArgsCount,
+		CommandFlush:      CommandFlushArgsCount,
+		CommandDBSize:     CommandDBSizeArgsCount,
+		CommandNamespaces: CommandNamespacesArgsCount,
+
+		CommandSubscribe:    CommandSubscribeArgsCount,
+		CommandPSubscribe:   CommandSubscribeArgsCount,
+		CommandUnsubscribe:  CommandUnsubscribeArgsCount,
+		CommandPUnsubscribe: CommandUnsubscribeArgsCount,
+		CommandPublish:      CommandPublishArgsCount,
+
+		CommandWatch:   CommandWatchArgsCount,
+		CommandUnwatch: CommandUnwatchArgsCount,
+
+		CommandHistory: CommandHistoryArgsCount,
+		CommandCompact: CommandCompactArgsCount,
+
+		CommandLease: CommandLeaseArgsCount,
+
+		CommandLock:      CommandLockArgsCount,
+		CommandUnlock:    CommandUnlockArgsCount,
+		CommandLockRenew: CommandLockRenewArgsCount,
+
+		CommandQCreate: CommandQCreateArgsCount,
+		CommandQPush:   CommandQPushArgsCount,
+		CommandQPop:    CommandQPopArgsCount,
+		CommandQAck:    CommandQAckArgsCount,
+		CommandQNack:   CommandQNackArgsCount,
+
+		CommandRateLimit:       CommandRateLimitArgsCount,
+		CommandRateLimitWindow: CommandRateLimitWindowArgsCount,
+
+		CommandInfo: CommandInfoArgsCount,
+		CommandWait: CommandWaitArgsCount,
+
+		CommandReplicaOf: CommandReplicaOfArgsCount,
+		CommandPromote:   CommandPromoteArgsCount,
+
+		CommandClusterAdd:    CommandClusterMemberArgsCount,
+		CommandClusterRemove: CommandClusterMemberArgsCount,
+
+		CommandSlots:        CommandSlotsArgsCount,
+		CommandSlotsAssign:  CommandSlotsAssignArgsCount,
+		CommandSlotsMigrate: CommandSlotsMigrateArgsCount,
+		CommandAsking:       CommandAskingArgsCount,
+	}
+}
+
+// RawArgInCommand returns commands whose last argument takes the rest of the line as is.
+func RawArgInCommand() map[CommandType]bool {
+	return map[CommandType]bool{
+		CommandJSONSet: true,
+		CommandPublish: true,
+		CommandQPush:   true,
 	}
 }
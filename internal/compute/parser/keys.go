@@ -0,0 +1,65 @@
+package parser
+
+import "strings"
+
+// keyStreams precedes the keys of XREAD and XREADGROUP.
+const keyStreams = "STREAMS"
+
+// keylessCommands returns commands not reading or writing keys, e.g. of the server, namespaces,
+// channels, locks or leases.
+func keylessCommands() map[CommandType]bool {
+	return map[CommandType]bool{
+		CommandSelect: true, CommandFlush: true, CommandDBSize: true, CommandNamespaces: true,
+		CommandSubscribe: true, CommandPSubscribe: true, CommandUnsubscribe: true, CommandPUnsubscribe: true,
+		CommandPublish: true, CommandWatch: true, CommandUnwatch: true, CommandCompact: true,
+		CommandLease: true, CommandLock: true, CommandUnlock: true, CommandLockRenew: true,
+		CommandInfo: true, CommandWait: true, CommandReplicaOf: true, CommandPromote: true,
+		CommandClusterAdd: true, CommandClusterRemove: true, CommandSlots: true,
+		CommandSlotsAssign: true, CommandSlotsMigrate: true, CommandAsking: true,
+	}
+}
+
+// Keys returns the keys the command reads or writes.
+func (c *Command) Keys() []string {
+	if keylessCommands()[c.Type] || c.Key == "" {
+		return nil
+	}
+
+	switch c.Type {
+	case CommandSInter, CommandSUnion, CommandPFCount, CommandPFMerge:
+		return append([]string{c.Key}, c.Args...)
+	case CommandBitOp:
+		// BITOP operation destkey key [key ...]
+		return c.Args
+	case CommandXGroup:
+		// XGROUP CREATE key group id [MKSTREAM]
+		return c.Args[:1]
+	case CommandBLPop:
+		// BLPOP key [key ...] timeout
+		return append([]string{c.Key}, c.Args[:len(c.Args)-1]...)
+	case CommandXRead, CommandXReadGroup:
+		args := append([]string{c.Key}, c.Args...)
+
+		for i, arg := range args {
+			if strings.EqualFold(arg, keyStreams) {
+				streams := args[i+1:]
+
+				return streams[:len(streams)/2]
+			}
+		}
+
+		return nil
+	case CommandQAck, CommandQNack:
+		// the message id is queue/sequence
+		if separator := strings.LastIndex(c.Key, "/"); separator > 0 {
+			return []string{c.Key[:separator]}
+		}
+	case CommandQCreate:
+		// Q.CREATE queue maxDeliveries [deadLetterQueue]
+		if len(c.Args) > 1 {
+			return []string{c.Key, c.Args[1]}
+		}
+	}
+
+	return []string{c.Key}
+}
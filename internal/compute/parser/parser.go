@@ -4,6 +4,7 @@ import (
 	"errors"
 	"regexp"
 	"strings"
+	"unicode"
 )
 
 var (
@@ -18,9 +19,29 @@ func Parse(commandText string) (Command, error) {
 		return Command{}, err
 	}
 
-	command := Command{Type: commandType, Key: validatedArgs[0]}
-	if commandType == CommandSet {
+	command := Command{Type: commandType}
+	if len(validatedArgs) == 0 {
+		return command, nil
+	}
+
+	command.Key = validatedArgs[0]
+
+	switch commandType {
+	case CommandSet:
+		// SET key value [LEASE id]
 		command.Value = validatedArgs[1]
+		if len(validatedArgs) > 2 { //nolint: mnd // key and value
+			command.Args = validatedArgs[2:]
+		}
+	case CommandGet:
+		// GET key [AT revision] [MAXLAG duration] [MINREV revision]
+		if len(validatedArgs) > 1 {
+			command.Args = validatedArgs[1:]
+		}
+	case CommandDel:
+		// fixed arity, extra arguments are ignored
+	default:
+		command.Args = validatedArgs[1:]
 	}
 
 	return command, nil
@@ -37,6 +58,10 @@ func validate(commandText string) (CommandType, []string, error) {
 		return "", nil, err
 	}
 
+	if RawArgInCommand()[commandType] {
+		return validateRawArg(commandType, commandText, argsCount)
+	}
+
 	err = validateArgs(args[1:], argsCount)
 	if err != nil {
 		return "", nil, err
@@ -45,6 +70,46 @@ func validate(commandText string) (CommandType, []string, error) {
 	return commandType, args[1:], nil
 }
 
+// validateRawArg validates a command whose last argument is the rest of the line,
+// the raw argument is passed as is and validated by the command itself.
+func validateRawArg(commandType CommandType, commandText string, argsCount int) (CommandType, []string, error) {
+	args := fieldsN(commandText, argsCount+1)
+	if len(args) <= argsCount {
+		return "", nil, ErrNotEnoughArguments
+	}
+
+	err := validateArgs(args[1:argsCount], argsCount-1)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return commandType, args[1:], nil
+}
+
+// fieldsN splits text around spaces like strings.Fields but into at most n fields,
+// the last field keeps the rest of the text including its inner spaces.
+func fieldsN(text string, n int) []string {
+	var fields []string
+
+	text = strings.TrimSpace(text)
+
+	for len(fields) < n-1 && text != "" {
+		end := strings.IndexFunc(text, unicode.IsSpace)
+		if end < 0 {
+			break
+		}
+
+		fields = append(fields, text[:end])
+		text = strings.TrimLeftFunc(text[end:], unicode.IsSpace)
+	}
+
+	if text != "" {
+		fields = append(fields, text)
+	}
+
+	return fields
+}
+
 func validateCommand(commandType CommandType) (CommandType, int, error) {
 	argsCount, ok := ArgsInCommand()[commandType]
 	if !ok {
@@ -70,7 +135,7 @@ func validateArgs(args []string, argsCount int) error {
 }
 
 func validateArg(arg string) error {
-	r := regexp.MustCompile("^[a-zA-Z0-9_/*]+$")
+	r := regexp.MustCompile(`^[a-zA-Z0-9_/*.:$\[\]{}=+>-]+$`)
 
 	matched := r.MatchString(arg)
 	if !matched {
@@ -5,6 +5,7 @@ import (
 
 	"github.com/pingvincible/kvdatabase/internal/compute/parser"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParse(t *testing.T) { //nolint: funlen // test code
@@ -44,11 +45,12 @@ func TestParse(t *testing.T) { //nolint: funlen // test code
 		},
 		{
 			name: "SET command with more than 2 arguments",
-			text: "SET key value extraValue",
+			text: "SET key value LEASE 1",
 			wantCommand: parser.Command{
 				Type:  parser.CommandSet,
 				Key:   "key",
 				Value: "value",
+				Args:  []string{"LEASE", "1"},
 			},
 			wantError: nil,
 		},
@@ -92,6 +94,16 @@ func TestParse(t *testing.T) { //nolint: funlen // test code
 			},
 			wantError: nil,
 		},
+		{
+			name: "GET command at revision",
+			text: "GET key AT 5",
+			wantCommand: parser.Command{
+				Type: parser.CommandGet,
+				Key:  "key",
+				Args: []string{"AT", "5"},
+			},
+			wantError: nil,
+		},
 		{
 			name:        "GET command without arguments",
 			text:        "GET",
@@ -138,6 +150,144 @@ func TestParse(t *testing.T) { //nolint: funlen // test code
 			wantCommand: parser.Command{},
 			wantError:   parser.ErrInvalidArgument,
 		},
+		{
+			name: "HSET correct command",
+			text: "HSET user/1 name bob email bob_mail",
+			wantCommand: parser.Command{
+				Type: parser.CommandHSet,
+				Key:  "user/1",
+				Args: []string{"name", "bob", "email", "bob_mail"},
+			},
+			wantError: nil,
+		},
+		{
+			name:        "HSET command without value",
+			text:        "HSET user/1 name",
+			wantCommand: parser.Command{},
+			wantError:   parser.ErrNotEnoughArguments,
+		},
+		{
+			name: "HGET correct command",
+			text: "HGET user/1 name",
+			wantCommand: parser.Command{
+				Type: parser.CommandHGet,
+				Key:  "user/1",
+				Args: []string{"name"},
+			},
+			wantError: nil,
+		},
+		{
+			name: "HDEL command with several fields",
+			text: "HDEL user/1 name email",
+			wantCommand: parser.Command{
+				Type: parser.CommandHDel,
+				Key:  "user/1",
+				Args: []string{"name", "email"},
+			},
+			wantError: nil,
+		},
+		{
+			name: "HGETALL correct command",
+			text: "HGETALL user/1",
+			wantCommand: parser.Command{
+				Type: parser.CommandHGetAll,
+				Key:  "user/1",
+				Args: []string{},
+			},
+			wantError: nil,
+		},
+		{
+			name:        "HGETALL command without arguments",
+			text:        "HGETALL",
+			wantCommand: parser.Command{},
+			wantError:   parser.ErrNotEnoughArguments,
+		},
+		{
+			name: "LRANGE command with negative index",
+			text: "LRANGE queue 0 -1",
+			wantCommand: parser.Command{
+				Type: parser.CommandLRange,
+				Key:  "queue",
+				Args: []string{"0", "-1"},
+			},
+			wantError: nil,
+		},
+		{
+			name: "BLPOP command with several keys",
+			text: "BLPOP queue other 0.5",
+			wantCommand: parser.Command{
+				Type: parser.CommandBLPop,
+				Key:  "queue",
+				Args: []string{"other", "0.5"},
+			},
+			wantError: nil,
+		},
+		{
+			name:        "BLPOP command without timeout",
+			text:        "BLPOP queue",
+			wantCommand: parser.Command{},
+			wantError:   parser.ErrNotEnoughArguments,
+		},
+		{
+			name: "JSON.SET command keeps raw value",
+			text: `JSON.SET doc $.user {"name": "bob", "tags": [1, 2]}` + "\n",
+			wantCommand: parser.Command{
+				Type: parser.CommandJSONSet,
+				Key:  "doc",
+				Args: []string{"$.user", `{"name": "bob", "tags": [1, 2]}`},
+			},
+			wantError: nil,
+		},
+		{
+			name:        "JSON.SET command without value",
+			text:        "JSON.SET doc $.user ",
+			wantCommand: parser.Command{},
+			wantError:   parser.ErrNotEnoughArguments,
+		},
+		{
+			name: "FLUSH command without arguments",
+			text: "FLUSH\n",
+			wantCommand: parser.Command{
+				Type: parser.CommandFlush,
+			},
+			wantError: nil,
+		},
+		{
+			name: "SELECT correct command",
+			text: "SELECT billing",
+			wantCommand: parser.Command{
+				Type: parser.CommandSelect,
+				Key:  "billing",
+				Args: []string{},
+			},
+			wantError: nil,
+		},
+		{
+			name: "CLUSTER.ADD command with address",
+			text: "CLUSTER.ADD 127.0.0.1:3224",
+			wantCommand: parser.Command{
+				Type: parser.CommandClusterAdd,
+				Key:  "127.0.0.1:3224",
+				Args: []string{},
+			},
+			wantError: nil,
+		},
+		{
+			name: "REPLICAOF NO ONE command",
+			text: "REPLICAOF NO ONE",
+			wantCommand: parser.Command{
+				Type: parser.CommandReplicaOf,
+				Key:  "NO",
+				Args: []string{"ONE"},
+			},
+			wantError: nil,
+		},
+		{
+			name:        "JSON.SET command with invalid path",
+			text:        "JSON.SET doc $.us#er 1",
+			wantCommand: parser.Command{},
+			wantError:   parser.ErrInvalidArgument,
+		},
 	}
 
 	t.Parallel()
@@ -153,3 +303,28 @@ func TestParse(t *testing.T) { //nolint: funlen // test code
 		})
 	}
 }
+
+func TestCommandKeys(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string][]string{
+		"GET user/1 AT 3":                   {"user/1"},
+		"SUNION a b c":                      {"a", "b", "c"},
+		"BITOP AND dest a b":                {"dest", "a", "b"},
+		"BLPOP a b 0.5":                     {"a", "b"},
+		"XREAD COUNT 2 STREAMS s1 s2 0 0":   {"s1", "s2"},
+		"XREADGROUP GROUP g c STREAMS s1 >": {"s1"},
+		"XGROUP CREATE s1 g 0 MKSTREAM":     {"s1"},
+		"Q.ACK jobs/7":                      {"jobs"},
+		"Q.CREATE jobs 3 dead":              {"jobs", "dead"},
+		"PUBLISH news hello":                nil,
+		"SELECT billing":                    nil,
+		"INFO":                              nil,
+	}
+
+	for text, wantKeys := range cases {
+		command, err := parser.Parse(text)
+		require.NoError(t, err, text)
+		assert.Equal(t, wantKeys, command.Keys(), text)
+	}
+}
@@ -0,0 +1,53 @@
+package compute
+
+import (
+	"strconv"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+)
+
+type ProbabilisticStorage interface {
+	PFAdd(key string, elements []string) (bool, error)
+	PFCount(keys []string) (int, error)
+	PFMerge(destination string, sources []string) error
+	BFReserve(key string, errorRate float64, capacity int) error
+	BFAdd(key, item string) (bool, error)
+	BFExists(key, item string) (bool, error)
+}
+
+func (c *Computer) computeProbabilistic(storage StorageInterface, command parser.Command) (string, error) {
+	switch command.Type {
+	case parser.CommandPFAdd:
+		changed, err := storage.PFAdd(command.Key, command.Args)
+
+		return formatBool(changed), err
+	case parser.CommandPFCount:
+		count, err := storage.PFCount(append([]string{command.Key}, command.Args...))
+
+		return strconv.Itoa(count), err
+	case parser.CommandPFMerge:
+		return "", storage.PFMerge(command.Key, command.Args)
+	case parser.CommandBFReserve:
+		errorRate, err := parseFloat(command.Args[0])
+		if err != nil {
+			return "", err
+		}
+
+		capacity, err := parseInt(command.Args[1])
+		if err != nil {
+			return "", err
+		}
+
+		return "", storage.BFReserve(command.Key, errorRate, capacity)
+	case parser.CommandBFAdd:
+		added, err := storage.BFAdd(command.Key, command.Args[0])
+
+		return formatBool(added), err
+	case parser.CommandBFExists:
+		exists, err := storage.BFExists(command.Key, command.Args[0])
+
+		return formatBool(exists), err
+	}
+
+	return "", nil
+}
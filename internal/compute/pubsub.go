@@ -0,0 +1,49 @@
+package compute
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+)
+
+var ErrSubscribedMode = errors.New("only (P)SUBSCRIBE and (P)UNSUBSCRIBE are allowed while subscribed")
+
+func isPubSubCommand(commandType parser.CommandType) bool {
+	switch commandType { //nolint: exhaustive // other commands are not allowed
+	case parser.CommandSubscribe, parser.CommandPSubscribe, parser.CommandUnsubscribe, parser.CommandPUnsubscribe:
+		return true
+	default:
+		return false
+	}
+}
+
+// computePubSub handles subscriptions of session and publishing. Subscription commands
+// reply with the number of active subscriptions, PUBLISH with the number of receivers.
+func (c *Computer) computePubSub(session *Session, command parser.Command) (string, error) {
+	names := command.Args
+	if command.Key != "" {
+		names = append([]string{command.Key}, command.Args...)
+	}
+
+	var count int
+
+	switch command.Type {
+	case parser.CommandSubscribe:
+		count = c.broker.Subscribe(session.subscribe(), names)
+	case parser.CommandPSubscribe:
+		count = c.broker.PSubscribe(session.subscribe(), names)
+	case parser.CommandUnsubscribe:
+		if session.Subscribed() {
+			count = c.broker.Unsubscribe(session.subscriber, names)
+		}
+	case parser.CommandPUnsubscribe:
+		if session.Subscribed() {
+			count = c.broker.PUnsubscribe(session.subscriber, names)
+		}
+	case parser.CommandPublish:
+		count = c.broker.Publish(command.Key, command.Args[0])
+	}
+
+	return strconv.Itoa(count), nil
+}
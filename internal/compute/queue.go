@@ -0,0 +1,104 @@
+package compute
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+	"github.com/pingvincible/kvdatabase/internal/storage/engine"
+	"github.com/pingvincible/kvdatabase/internal/watch"
+)
+
+const (
+	// deadLetterSuffix names the default dead letter queue of a queue.
+	deadLetterSuffix = ".dead"
+
+	opDeadLetter = "q.deadletter"
+)
+
+type QueueStorage interface {
+	QCreate(key string, maxDeliveries int, deadLetter string) error
+	QPush(key, body string) (string, error)
+	QPop(key string, visibility time.Duration, now time.Time) (engine.QueuePop, error)
+	QAck(id string, now time.Time) (bool, error)
+	QNack(id string, now time.Time) (bool, bool, error)
+	QDeadLetter(key string) (string, bool, error)
+}
+
+// computeQueue handles Q.CREATE queue maxDeliveries [deadLetterQueue], Q.PUSH queue message,
+// Q.POP queue visibility, Q.ACK id and Q.NACK id. Q.POP replies with the message id and body
+// or an empty result if no message is ready, Q.ACK and Q.NACK with 1 if the message was still
+// delivered and 0 otherwise.
+func (c *Computer) computeQueue(storage StorageInterface, session *Session, command parser.Command) (string, error) {
+	switch command.Type {
+	case parser.CommandQCreate:
+		maxDeliveries, err := parseInt(command.Args[0])
+		if err != nil || maxDeliveries < 0 {
+			return "", ErrInvalidNumber
+		}
+
+		deadLetter := command.Key + deadLetterSuffix
+		if len(command.Args) > 1 {
+			deadLetter = command.Args[1]
+		}
+
+		return "", storage.QCreate(command.Key, maxDeliveries, deadLetter)
+	case parser.CommandQPush:
+		return storage.QPush(command.Key, command.Args[0])
+	case parser.CommandQPop:
+		visibility, err := parseSeconds(command.Args[0])
+		if err != nil {
+			return "", err
+		}
+
+		pop, err := storage.QPop(command.Key, visibility, session.now())
+		if err != nil {
+			return "", err
+		}
+
+		if pop.DeadLettered {
+			if !pop.Delivered {
+				// the queue itself changed even though no message is delivered
+				c.changed = append(c.changed, watch.Event{
+					Namespace: session.namespace, Key: command.Key, Op: strings.ToLower(string(command.Type)),
+				})
+			}
+
+			err = c.changeDeadLetter(storage, session, command.Key)
+		}
+
+		if err != nil || !pop.Delivered {
+			return "", err
+		}
+
+		return pop.Message.ID + " " + pop.Message.Body, nil
+	case parser.CommandQAck:
+		acked, err := storage.QAck(command.Key, session.now())
+
+		return formatBool(acked), err
+	case parser.CommandQNack:
+		nacked, deadLettered, err := storage.QNack(command.Key, session.now())
+		if err != nil || !deadLettered {
+			return formatBool(nacked), err
+		}
+
+		queue, _, _ := engine.ParseMessageID(command.Key)
+
+		return formatBool(nacked), c.changeDeadLetter(storage, session, queue)
+	}
+
+	return "", nil
+}
+
+// changeDeadLetter adds the dead letter queue of queue to the changes of the running
+// mutation once expired or rejected messages were moved there.
+func (c *Computer) changeDeadLetter(storage StorageInterface, session *Session, queue string) error {
+	deadLetter, ok, err := storage.QDeadLetter(queue)
+	if err != nil || !ok {
+		return err
+	}
+
+	c.changed = append(c.changed, watch.Event{Namespace: session.namespace, Key: deadLetter, Op: opDeadLetter})
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package compute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+)
+
+var (
+	// ErrNoQuorum does not undo the write: it is applied and replicated later,
+	// so the client may WAIT for its revision or retry it if the write is idempotent.
+	ErrNoQuorum   = errors.New("NOQUORUM not enough replicas acknowledged the write in time")
+	ErrNotPrimary = errors.New("WAIT is not supported by replicas")
+)
+
+// Acknowledger waits for replicas to acknowledge replicated revisions.
+type Acknowledger interface {
+	WaitAcks(ctx context.Context, revision int64, replicas int) int
+}
+
+// SetQuorum makes every write changing the store wait until replicas replicas acknowledge it
+// before replying. A write not acknowledged within timeout stays applied but fails with
+// ErrNoQuorum reporting its revision. Zero replicas disable the wait, a zero timeout waits
+// forever. The quorum applies to primaries only.
+func (c *Computer) SetQuorum(replicas int, timeout time.Duration) {
+	c.replicationMutex.Lock()
+	defer c.replicationMutex.Unlock()
+
+	c.quorum = replicas
+	c.quorumTimeout = timeout
+}
+
+// waitQuorum waits for the configured quorum of replicas to acknowledge revision.
+func (c *Computer) waitQuorum(ctx context.Context, revision int64) error {
+	c.replicationMutex.RLock()
+	replicas, timeout := c.quorum, c.quorumTimeout
+	c.replicationMutex.RUnlock()
+
+	if replicas <= 0 {
+		return nil
+	}
+
+	acked, err := c.waitAcks(ctx, revision, replicas, timeout)
+	if errors.Is(err, ErrNoQuorum) {
+		return fmt.Errorf("%w: the write was applied at revision %d but acknowledged by %d of %d replicas only",
+			ErrNoQuorum, revision, acked, replicas)
+	}
+
+	return err
+}
+
+// computeWait handles WAIT numreplicas timeout, it waits until numreplicas replicas acknowledge
+// the latest write of the session and replies with the number of replicas that acknowledged it.
+// A zero timeout waits forever.
+func (c *Computer) computeWait(ctx context.Context, session *Session, command parser.Command) (string, error) {
+	replicas, err := parseInt(command.Key)
+	if err != nil || replicas < 0 {
+		return "", ErrInvalidNumber
+	}
+
+	timeout, err := parseSeconds(command.Args[0])
+	if err != nil {
+		return "", err
+	}
+
+	acked, err := c.waitAcks(ctx, session.revision, replicas, timeout)
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.Itoa(acked), nil
+}
+
+// waitAcks waits until replicas replicas acknowledge revision and returns the number
+// of replicas that acknowledged it. It fails with ErrNoQuorum if fewer replicas
+// acknowledged revision within timeout.
+func (c *Computer) waitAcks(ctx context.Context, revision int64, replicas int, timeout time.Duration) (int, error) {
+	c.replicationMutex.RLock()
+	acknowledger, ok := c.replication.(Acknowledger)
+	readOnly := c.readOnly
+	c.replicationMutex.RUnlock()
+
+	if readOnly {
+		return 0, ErrNotPrimary
+	}
+
+	acked := 0
+
+	if ok {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		acked = acknowledger.WaitAcks(ctx, revision, replicas)
+	}
+
+	if acked < replicas {
+		return acked, fmt.Errorf("%w: %d of %d replicas acknowledged revision %d", ErrNoQuorum, acked, replicas, revision)
+	}
+
+	return acked, nil
+}
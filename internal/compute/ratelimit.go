@@ -0,0 +1,65 @@
+package compute
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+	"github.com/pingvincible/kvdatabase/internal/storage/engine"
+)
+
+type RateLimitStorage interface {
+	TokenBucket(key string, capacity, perSecond, cost float64, now time.Time) (engine.RateLimitResult, error)
+	SlidingWindow(key string, limit float64, window time.Duration, cost float64, now time.Time) (engine.RateLimitResult, error)
+}
+
+// computeRateLimit handles RATELIMIT key capacity refillPerSec cost, a token bucket, and
+// RATELIMIT.WINDOW key limit windowSeconds cost, a sliding window. Both reply with
+// whether the request is allowed, the capacity left and seconds to retry after,
+// -1 if the request can never be allowed.
+func (c *Computer) computeRateLimit(
+	storage StorageInterface, session *Session, command parser.Command,
+) (string, error) {
+	numbers := make([]float64, 0, len(command.Args))
+
+	for _, arg := range command.Args {
+		number, err := parseFloat(arg)
+		if err != nil {
+			return "", err
+		}
+
+		if number < 0 {
+			return "", ErrInvalidNumber
+		}
+
+		numbers = append(numbers, number)
+	}
+
+	var (
+		result engine.RateLimitResult
+		err    error
+	)
+
+	switch command.Type {
+	case parser.CommandRateLimit:
+		result, err = storage.TokenBucket(command.Key, numbers[0], numbers[1], numbers[2], session.now())
+	case parser.CommandRateLimitWindow:
+		window := time.Duration(numbers[1] * float64(time.Second))
+		if window <= 0 {
+			return "", ErrInvalidNumber
+		}
+
+		result, err = storage.SlidingWindow(command.Key, numbers[0], window, numbers[2], session.now())
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	retryAfter := formatFloat(-1)
+	if result.RetryAfter >= 0 {
+		retryAfter = formatFloat(result.RetryAfter.Seconds())
+	}
+
+	return strings.Join([]string{formatBool(result.Allowed), formatFloat(result.Remaining), retryAfter}, " "), nil
+}
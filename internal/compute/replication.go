@@ -0,0 +1,154 @@
+package compute
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/replication"
+	"github.com/pingvincible/kvdatabase/internal/storage/engine"
+	"github.com/pingvincible/kvdatabase/internal/watch"
+)
+
+var ErrReadOnly = errors.New("READONLY replicas do not accept writes")
+
+// replicationBacklog is the size in bytes of the latest changes retained for streaming to replicas.
+const replicationBacklog = 64 << 20
+
+type DumpStorage interface {
+	Dump(key string) ([]byte, bool, error)
+	Restore(key string, data []byte) error
+	Keys() []string
+	SlotKeys(keySlot, count int) []string
+}
+
+// ReplicationStatus reports the replication state of the database.
+type ReplicationStatus interface {
+	Info() string
+}
+
+// SetReplication sets the replication state reported by INFO, a read only database
+// rejects write commands and only changes by applying replicated entries.
+func (c *Computer) SetReplication(status ReplicationStatus, readOnly bool) {
+	c.replicationMutex.Lock()
+	defer c.replicationMutex.Unlock()
+
+	c.replication = status
+	c.readOnly = readOnly
+}
+
+// SetReadOnly makes the database reject write commands while it replicates a primary.
+func (c *Computer) SetReadOnly(readOnly bool) {
+	c.replicationMutex.Lock()
+	defer c.replicationMutex.Unlock()
+
+	c.readOnly = readOnly
+}
+
+func (c *Computer) isReadOnly() bool {
+	c.replicationMutex.RLock()
+	defer c.replicationMutex.RUnlock()
+
+	return c.readOnly
+}
+
+// computeInfo handles INFO, it replies with the replication state.
+func (c *Computer) computeInfo() string {
+	c.replicationMutex.RLock()
+	defer c.replicationMutex.RUnlock()
+
+	if c.replication == nil {
+		return "role primary revision " + strconv.FormatInt(c.Revision(), 10)
+	}
+
+	return c.replication.Info()
+}
+
+// Log returns the log of changes streamed to replicas.
+func (c *Computer) Log() *replication.Log {
+	return c.log
+}
+
+// Snapshot returns the revision of the latest mutation and entries restoring all keys
+// as of that revision, later changes are in Log.
+func (c *Computer) Snapshot() (int64, []replication.Entry, error) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	var entries []replication.Entry
+
+	now := time.Now()
+
+	for _, namespace := range c.storage.NamespaceNames() {
+		storage := c.namespace(namespace, false)
+
+		for _, key := range storage.Keys() {
+			value, ok, err := storage.Dump(key)
+			if err != nil {
+				return 0, nil, err
+			}
+
+			if ok {
+				entries = append(entries, replication.Entry{
+					Revision:  c.revision,
+					Time:      now,
+					Namespace: namespace,
+					Op:        opSet,
+					Key:       key,
+					Value:     value,
+				})
+			}
+		}
+	}
+
+	return c.revision, entries, nil
+}
+
+// Load replaces all keys with a snapshot of another database taken at revision.
+// The snapshot is restored aside and swapped in at once, the history of this database
+// is discarded.
+func (c *Computer) Load(revision int64, entries []replication.Entry) error {
+	loaded := engine.New()
+
+	for _, entry := range entries {
+		err := loaded.Namespace(entry.Namespace).Restore(entry.Key, entry.Value)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	c.storage.Replace(loaded, revision)
+	c.watchers.Reset()
+
+	c.revision = revision
+	c.log.Reset(revision)
+
+	return nil
+}
+
+// Apply applies a change replicated from another database.
+func (c *Computer) Apply(entry replication.Entry) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	storage := c.namespace(entry.Namespace, entry.Op != watch.OpFlush)
+
+	switch {
+	case entry.Op == watch.OpFlush:
+		storage.Flush()
+	case len(entry.Value) == 0:
+		storage.Delete(entry.Key)
+	default:
+		err := storage.Restore(entry.Key, entry.Value)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.revision = entry.Revision
+
+	return c.commit([]watch.Event{{Revision: entry.Revision, Namespace: entry.Namespace, Key: entry.Key, Op: entry.Op}})
+}
@@ -0,0 +1,107 @@
+package compute
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+	"github.com/pingvincible/kvdatabase/internal/pubsub"
+	"github.com/pingvincible/kvdatabase/internal/storage/engine"
+)
+
+// pushBuffer is the number of pushed messages a session buffers before
+// it is considered too slow and disconnected.
+const pushBuffer = 1024
+
+// Session holds the state of a single client connection.
+type Session struct {
+	namespace  string
+	subscriber *pubsub.Subscriber // created by the first subscription or watch
+	revision   int64              // the revision of the latest write of the session
+	clock      time.Time          // the time replicated commands are executed at, zero for the wall clock
+	asking     bool               // the next command may access keys of slots being imported
+}
+
+func NewSession() *Session {
+	return &Session{namespace: engine.DefaultNamespace}
+}
+
+// Subscribed reports whether the session has subscribed or watched, so that messages
+// may be pushed to its client.
+func (s *Session) Subscribed() bool {
+	return s.subscriber != nil
+}
+
+// Pushes returns messages the server sends to the client without a preceding request,
+// it is nil until the session subscribes or watches.
+func (s *Session) Pushes() <-chan string {
+	if s.subscriber == nil {
+		return nil
+	}
+
+	return s.subscriber.Messages()
+}
+
+// Dropped is closed when the client does not read pushed messages fast enough
+// and has to be disconnected.
+func (s *Session) Dropped() <-chan struct{} {
+	if s.subscriber == nil {
+		return nil
+	}
+
+	return s.subscriber.Dropped()
+}
+
+// subscribe returns the subscriber receiving the messages pushed to the session,
+// creating it on first use.
+func (s *Session) subscribe() *pubsub.Subscriber {
+	if s.subscriber == nil {
+		s.subscriber = pubsub.NewSubscriber(pushBuffer)
+	}
+
+	return s.subscriber
+}
+
+// now returns the time the commands of the session are executed at.
+func (s *Session) now() time.Time {
+	if s.clock.IsZero() {
+		return time.Now()
+	}
+
+	return s.clock
+}
+
+func (s *Session) Namespace() string {
+	return s.namespace
+}
+
+// computeNamespace handles SELECT namespace, FLUSH [namespace], DBSIZE [namespace] and NAMESPACES.
+func (c *Computer) computeNamespace(session *Session, command parser.Command) (string, error) {
+	namespace := session.namespace
+	if command.Key != "" {
+		namespace = command.Key
+	}
+
+	switch command.Type {
+	case parser.CommandSelect:
+		session.namespace = namespace
+	case parser.CommandFlush:
+		c.leases.DetachNamespace(namespace)
+
+		return strconv.Itoa(c.namespace(namespace, false).Flush()), nil
+	case parser.CommandDBSize:
+		return strconv.Itoa(c.namespace(namespace, false).KeyCount()), nil
+	case parser.CommandNamespaces:
+		names := c.storage.NamespaceNames()
+
+		parts := make([]string, 0, 2*len(names)) //nolint: mnd // name and key count
+		for _, name := range names {
+			parts = append(parts, name, strconv.Itoa(c.namespace(name, false).KeyCount()))
+		}
+
+		return strings.Join(parts, " "), nil
+	}
+
+	return "", nil
+}
@@ -0,0 +1,47 @@
+package compute
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+)
+
+type SetStorage interface {
+	SAdd(key string, members []string) (int, error)
+	SRem(key string, members []string) (int, error)
+	SMembers(key string) ([]string, error)
+	SIsMember(key, member string) (bool, error)
+	SInter(keys []string) ([]string, error)
+	SUnion(keys []string) ([]string, error)
+}
+
+func (c *Computer) computeSet(storage StorageInterface, command parser.Command) (string, error) {
+	var (
+		members []string
+		err     error
+	)
+
+	switch command.Type {
+	case parser.CommandSAdd:
+		added, err := storage.SAdd(command.Key, command.Args)
+
+		return strconv.Itoa(added), err
+	case parser.CommandSRem:
+		removed, err := storage.SRem(command.Key, command.Args)
+
+		return strconv.Itoa(removed), err
+	case parser.CommandSIsMember:
+		exists, err := storage.SIsMember(command.Key, command.Args[0])
+
+		return formatBool(exists), err
+	case parser.CommandSMembers:
+		members, err = storage.SMembers(command.Key)
+	case parser.CommandSInter:
+		members, err = storage.SInter(append([]string{command.Key}, command.Args...))
+	case parser.CommandSUnion:
+		members, err = storage.SUnion(append([]string{command.Key}, command.Args...))
+	}
+
+	return strings.Join(members, " "), err
+}
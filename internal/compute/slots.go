@@ -0,0 +1,243 @@
+package compute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+	"github.com/pingvincible/kvdatabase/internal/slot"
+)
+
+var (
+	ErrMoved          = errors.New("MOVED")
+	ErrAsk            = errors.New("ASK")
+	ErrTryAgain       = errors.New("TRYAGAIN keys of the command are being migrated")
+	ErrCrossSlot      = errors.New("CROSSSLOT keys of the command are in different slots")
+	ErrSlotUnassigned = errors.New("CLUSTERDOWN slot is not assigned")
+	ErrNoMigration    = errors.New("slot migration is not configured")
+	ErrNoSlots        = errors.New("slots are not configured")
+)
+
+// Migrator moves the keys of a slot to the node at target and hands the slot over to it.
+type Migrator interface {
+	Migrate(ctx context.Context, slot int, target string) (int, error)
+}
+
+// SetSlots makes the database reachable at address serve only keys of the slots assigned
+// to address by slots. Commands with keys of other slots fail with ErrMoved naming the slot
+// and the address of the node serving it. A nil slots serves all keys.
+func (c *Computer) SetSlots(address string, slots *slot.Map) {
+	c.slotsMutex.Lock()
+	defer c.slotsMutex.Unlock()
+
+	c.address = address
+	c.slots = slots
+}
+
+// SetMigrator sets the migrator moving slots on SLOTS.MIGRATE.
+func (c *Computer) SetMigrator(migrator Migrator) {
+	c.slotsMutex.Lock()
+	defer c.slotsMutex.Unlock()
+
+	c.migrator = migrator
+}
+
+// checkSlots checks that the keys of command are in slots served by the database.
+// Keys of a slot migrating to another node are served while they are still here,
+// commands of missing keys fail with ErrAsk naming the target. The returned release
+// must be called once the command is executed, migration waits for it. Blocking commands
+// fail with ErrTryAgain once their keys are migrating, see attempt.
+func (c *Computer) checkSlots(session *Session, command parser.Command) (func(), error) {
+	asking := session.asking
+	session.asking = command.Type == parser.CommandAsking
+
+	keys := command.Keys()
+	if len(keys) == 0 {
+		return func() {}, nil
+	}
+
+	migrating, err := c.route(session, keys, asking, false)
+	if !migrating || blocks(command) {
+		return func() {}, err
+	}
+
+	// keys of migrating slots are checked again, as migration may have moved them meanwhile
+	c.migrationMutex.RLock()
+
+	_, err = c.route(session, keys, asking, true)
+	if err != nil {
+		c.migrationMutex.RUnlock()
+
+		return nil, err
+	}
+
+	return c.migrationMutex.RUnlock, nil
+}
+
+// route checks that keys are served by the database. It reports whether a slot of keys
+// is migrating, checkMissing also checks that keys of migrating slots are still here.
+func (c *Computer) route(session *Session, keys []string, asking, checkMissing bool) (bool, error) {
+	c.slotsMutex.RLock()
+	defer c.slotsMutex.RUnlock()
+
+	if c.slots == nil {
+		return false, nil
+	}
+
+	// keys of a command must stay together when their slot moves to another node
+	keySlot := slot.Of(keys[0])
+
+	for _, key := range keys[1:] {
+		if slot.Of(key) != keySlot {
+			return false, ErrCrossSlot
+		}
+	}
+
+	owner := c.slots.Owner(keySlot)
+	if owner == "" {
+		return false, fmt.Errorf("%w: %d", ErrSlotUnassigned, keySlot)
+	}
+
+	if owner != c.address {
+		if _, importing := c.importing[keySlot]; asking && importing {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("%w %d %s", ErrMoved, keySlot, owner)
+	}
+
+	return c.checkMigrating(session, keys, checkMissing)
+}
+
+// checkMigrating reports whether a slot of keys is migrating, with checkMissing it fails
+// with ErrAsk if all keys are missing and with ErrTryAgain if some are.
+// It must be called with slotsMutex held.
+func (c *Computer) checkMigrating(session *Session, keys []string, checkMissing bool) (bool, error) {
+	storage := c.namespace(session.namespace, false)
+	migrating, missing, target, missingSlot := false, 0, "", 0
+
+	for _, key := range keys {
+		keySlot := slot.Of(key)
+
+		keyTarget, ok := c.migrating[keySlot]
+		if !ok {
+			continue
+		}
+
+		migrating = true
+
+		if !checkMissing {
+			continue
+		}
+
+		if !storage.Exists(key) {
+			missing++
+
+			target, missingSlot = keyTarget, keySlot
+		}
+	}
+
+	switch {
+	case missing == 0:
+		return migrating, nil
+	case missing == len(keys):
+		return migrating, fmt.Errorf("%w %d %s", ErrAsk, missingSlot, target)
+	default:
+		return migrating, ErrTryAgain
+	}
+}
+
+// isMigrating reports whether a slot of keys is migrating to another node.
+func (c *Computer) isMigrating(keys []string) bool {
+	c.slotsMutex.RLock()
+	defer c.slotsMutex.RUnlock()
+
+	for _, key := range keys {
+		if _, ok := c.migrating[slot.Of(key)]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// computeSlots handles SLOTS, SLOTS.ASSIGN range [range ...], SLOTS.MIGRATE range target
+// and ASKING. SLOTS replies with the assigned slot ranges in the form from-to=address,
+// it is empty if the database serves all keys. SLOTS.MIGRATE replies with the number
+// of moved keys, ASKING lets the next command access keys of slots being imported.
+func (c *Computer) computeSlots(ctx context.Context, command parser.Command) (string, error) {
+	switch command.Type {
+	case parser.CommandSlotsAssign:
+		return "", c.assignSlots(append([]string{command.Key}, command.Args...))
+	case parser.CommandSlotsMigrate:
+		return c.migrateSlots(ctx, command.Key, command.Args[0])
+	case parser.CommandAsking:
+		return "", nil
+	}
+
+	c.slotsMutex.RLock()
+	defer c.slotsMutex.RUnlock()
+
+	if c.slots == nil {
+		return "", nil
+	}
+
+	return c.slots.String(), nil
+}
+
+func (c *Computer) assignSlots(ranges []string) error {
+	assigned := make([]slot.Range, 0, len(ranges))
+
+	for _, text := range ranges {
+		parsed, err := slot.ParseRange(text)
+		if err != nil {
+			return err
+		}
+
+		assigned = append(assigned, parsed)
+	}
+
+	c.slotsMutex.Lock()
+	defer c.slotsMutex.Unlock()
+
+	if c.slots == nil {
+		c.slots = slot.NewMap()
+	}
+
+	for _, parsed := range assigned {
+		c.slots.Assign(parsed)
+	}
+
+	return nil
+}
+
+// migrateSlots moves the slots of a range to the node at target one by one.
+func (c *Computer) migrateSlots(ctx context.Context, text, target string) (string, error) {
+	migrated, err := slot.ParseRange(text + "=" + target)
+	if err != nil {
+		return "", err
+	}
+
+	c.slotsMutex.RLock()
+	migrator := c.migrator
+	c.slotsMutex.RUnlock()
+
+	if migrator == nil {
+		return "", ErrNoMigration
+	}
+
+	moved := 0
+
+	for keySlot := migrated.From; keySlot <= migrated.To; keySlot++ {
+		keys, err := migrator.Migrate(ctx, keySlot, target)
+		moved += keys
+
+		if err != nil {
+			return "", fmt.Errorf("failed to migrate slot %d: %w", keySlot, err)
+		}
+	}
+
+	return strconv.Itoa(moved), nil
+}
@@ -0,0 +1,106 @@
+package compute
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrStale           = errors.New("STALE")
+	ErrInvalidDuration = errors.New("invalid duration")
+)
+
+// Options of GET bounding the staleness of reads served by replicas.
+const (
+	staleMaxLag      = "MAXLAG"
+	staleMinRevision = "MINREV"
+)
+
+// Follower is the replication state of a replica serving reads.
+type Follower interface {
+	Lag() (time.Duration, bool)
+	PrimaryAddress() string
+}
+
+// getOptions are the options of GET key [AT revision] [MAXLAG duration] [MINREV revision].
+type getOptions struct {
+	at             int64
+	hasAt          bool
+	maxLag         time.Duration
+	hasMaxLag      bool
+	minRevision    int64
+	hasMinRevision bool
+}
+
+func parseGetOptions(args []string) (getOptions, error) {
+	var options getOptions
+
+	if len(args)%2 != 0 {
+		return options, ErrWrongArgumentsCount
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		var err error
+
+		switch value := args[i+1]; strings.ToUpper(args[i]) {
+		case historyAt:
+			options.at, err = parseInt64(value)
+			options.hasAt = true
+		case staleMaxLag:
+			options.maxLag, err = time.ParseDuration(value)
+			if err != nil || options.maxLag < 0 {
+				err = fmt.Errorf("%w: %s", ErrInvalidDuration, value)
+			}
+
+			options.hasMaxLag = true
+		case staleMinRevision:
+			options.minRevision, err = parseInt64(value)
+			options.hasMinRevision = true
+		default:
+			return options, ErrSyntax
+		}
+
+		if err != nil {
+			return options, err
+		}
+	}
+
+	return options, nil
+}
+
+// checkStaleness fails with ErrStale naming the primary if a replica lags behind
+// the primary more than maxLag or has not applied minRevision yet.
+// Primaries serve all reads.
+func (c *Computer) checkStaleness(options getOptions) error {
+	if !options.hasMaxLag && !options.hasMinRevision {
+		return nil
+	}
+
+	c.replicationMutex.RLock()
+	follower, ok := c.replication.(Follower)
+	readOnly := c.readOnly
+	c.replicationMutex.RUnlock()
+
+	if !ok || !readOnly {
+		return nil
+	}
+
+	if revision := c.Revision(); revision < options.minRevision {
+		return fmt.Errorf("%w %s revision %d of %d", ErrStale, follower.PrimaryAddress(), revision, options.minRevision)
+	}
+
+	if options.hasMaxLag {
+		lag, connected := follower.Lag()
+		if !connected {
+			return fmt.Errorf("%w %s disconnected", ErrStale, follower.PrimaryAddress())
+		}
+
+		if lag > options.maxLag {
+			return fmt.Errorf("%w %s lag %s", ErrStale, follower.PrimaryAddress(), lag)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,272 @@
+package compute
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+	"github.com/pingvincible/kvdatabase/internal/storage/engine"
+)
+
+const (
+	streamMinID      = "-"
+	streamMaxID      = "+"
+	streamLatestID   = "$"
+	streamNewEntries = ">"
+
+	optionCount    = "COUNT"
+	optionBlock    = "BLOCK"
+	optionStreams  = "STREAMS"
+	optionGroup    = "GROUP"
+	optionMkStream = "MKSTREAM"
+
+	subcommandCreate = "CREATE"
+)
+
+type StreamStorage interface {
+	XAdd(key, value string, now time.Time) (engine.StreamID, error)
+	XRange(key string, start, end engine.StreamID, count int) ([]engine.StreamEntry, error)
+	XLastID(key string) (engine.StreamID, error)
+	XRead(
+		ctx context.Context,
+		keys []string,
+		ids []engine.StreamID,
+		opts engine.StreamReadOptions,
+	) ([]engine.StreamRead, error)
+	XGroupCreate(key, group string, id engine.StreamID, latest, mkStream bool) error
+	XReadGroup(
+		ctx context.Context,
+		key, group, consumer string,
+		id *engine.StreamID,
+		opts engine.StreamReadOptions,
+		now time.Time,
+	) ([]engine.StreamEntry, error)
+	XAck(key, group string, ids []engine.StreamID) (int, error)
+	XPending(key, group string) ([]engine.PendingEntry, error)
+}
+
+func (c *Computer) computeStream(
+	ctx context.Context, storage StorageInterface, session *Session, command parser.Command,
+) (string, error) {
+	switch command.Type {
+	case parser.CommandXAdd:
+		id, err := storage.XAdd(command.Key, command.Args[0], session.now())
+
+		return id.String(), err
+	case parser.CommandXRange:
+		return c.computeXRange(storage, command)
+	case parser.CommandXRead:
+		return c.computeXRead(ctx, storage, command)
+	case parser.CommandXGroup:
+		return c.computeXGroup(storage, command)
+	case parser.CommandXReadGroup:
+		return c.computeXReadGroup(ctx, storage, session, command)
+	case parser.CommandXAck:
+		ids, err := parseStreamIDs(command.Args[1:])
+		if err != nil {
+			return "", err
+		}
+
+		acknowledged, err := storage.XAck(command.Key, command.Args[0], ids)
+
+		return strconv.Itoa(acknowledged), err
+	case parser.CommandXPending:
+		pending, err := storage.XPending(command.Key, command.Args[0])
+
+		parts := make([]string, 0, 3*len(pending)) //nolint: mnd // id, consumer and deliveries
+		for _, entry := range pending {
+			parts = append(parts, entry.ID.String(), entry.Consumer, strconv.Itoa(entry.Deliveries))
+		}
+
+		return strings.Join(parts, " "), err
+	}
+
+	return "", nil
+}
+
+// computeXRange handles XRANGE key start end [COUNT count].
+func (c *Computer) computeXRange(storage StorageInterface, command parser.Command) (string, error) {
+	start, end := engine.MinStreamID, engine.MaxStreamID
+
+	var err error
+
+	if command.Args[0] != streamMinID {
+		if start, err = engine.ParseStreamID(command.Args[0], 0); err != nil {
+			return "", err
+		}
+	}
+
+	if command.Args[1] != streamMaxID {
+		if end, err = engine.ParseStreamID(command.Args[1], math.MaxUint64); err != nil {
+			return "", err
+		}
+	}
+
+	opts, rest, err := parseStreamReadOptions(command.Args[2:])
+	if err != nil || len(rest) > 0 || opts.Block {
+		return "", ErrSyntax
+	}
+
+	entries, err := storage.XRange(command.Key, start, end, opts.Count)
+
+	return formatStreamEntries(entries), err
+}
+
+// computeXRead handles XREAD [COUNT count] [BLOCK ms] STREAMS key [key ...] id [id ...].
+func (c *Computer) computeXRead(ctx context.Context, storage StorageInterface, command parser.Command) (string, error) {
+	opts, rest, err := parseStreamReadOptions(append([]string{command.Key}, command.Args...))
+	if err != nil {
+		return "", err
+	}
+
+	if len(rest) < 3 || rest[0] != optionStreams || len(rest)%2 != 1 { //nolint: mnd // STREAMS key id
+		return "", ErrSyntax
+	}
+
+	keys, idTexts := rest[1:len(rest)/2+1], rest[len(rest)/2+1:]
+	ids := make([]engine.StreamID, len(keys))
+
+	for i, idText := range idTexts {
+		if idText == streamLatestID {
+			ids[i], err = storage.XLastID(keys[i])
+		} else {
+			ids[i], err = engine.ParseStreamID(idText, 0)
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	reads, err := storage.XRead(ctx, keys, ids, opts)
+
+	parts := make([]string, 0, 2*len(reads)) //nolint: mnd // key and entries
+	for _, read := range reads {
+		parts = append(parts, read.Key, formatStreamEntries(read.Entries))
+	}
+
+	return strings.Join(parts, " "), err
+}
+
+// computeXGroup handles XGROUP CREATE key group id|$ [MKSTREAM].
+func (c *Computer) computeXGroup(storage StorageInterface, command parser.Command) (string, error) {
+	if command.Key != subcommandCreate {
+		return "", ErrSyntax
+	}
+
+	key, group, idText := command.Args[0], command.Args[1], command.Args[2]
+	mkStream := len(command.Args) > 3 && command.Args[3] == optionMkStream //nolint: mnd // optional flag
+	latest := idText == streamLatestID
+
+	var id engine.StreamID
+
+	if !latest {
+		var err error
+
+		if id, err = engine.ParseStreamID(idText, 0); err != nil {
+			return "", err
+		}
+	}
+
+	return "", storage.XGroupCreate(key, group, id, latest, mkStream)
+}
+
+// computeXReadGroup handles XREADGROUP GROUP group consumer [COUNT count] [BLOCK ms] STREAMS key id|>.
+func (c *Computer) computeXReadGroup(
+	ctx context.Context, storage StorageInterface, session *Session, command parser.Command,
+) (string, error) {
+	if command.Key != optionGroup {
+		return "", ErrSyntax
+	}
+
+	group, consumer := command.Args[0], command.Args[1]
+
+	opts, rest, err := parseStreamReadOptions(command.Args[2:])
+	if err != nil {
+		return "", err
+	}
+
+	if len(rest) != 3 || rest[0] != optionStreams { //nolint: mnd // STREAMS key id
+		return "", ErrSyntax
+	}
+
+	var id *engine.StreamID
+
+	if rest[2] != streamNewEntries {
+		pendingAfter, err := engine.ParseStreamID(rest[2], 0)
+		if err != nil {
+			return "", err
+		}
+
+		id = &pendingAfter
+	}
+
+	if !opts.Block || id != nil {
+		entries, err := storage.XReadGroup(ctx, rest[1], group, consumer, id, opts, session.now())
+
+		return formatStreamEntries(entries), err
+	}
+
+	var entries []engine.StreamEntry
+
+	err = c.block(ctx, storage, rest[1:2], opts.Timeout, func() (bool, error) {
+		var err error
+
+		entries, err = storage.XReadGroup(ctx, rest[1], group, consumer, nil,
+			engine.StreamReadOptions{Count: opts.Count}, session.now())
+
+		return len(entries) > 0, err
+	})
+
+	return formatStreamEntries(entries), err
+}
+
+// parseStreamReadOptions consumes leading COUNT and BLOCK options and returns the rest of args.
+func parseStreamReadOptions(args []string) (engine.StreamReadOptions, []string, error) {
+	var opts engine.StreamReadOptions
+
+	for len(args) > 1 && (args[0] == optionCount || args[0] == optionBlock) {
+		number, err := parseInt(args[1])
+		if err != nil || number < 0 {
+			return opts, nil, ErrSyntax
+		}
+
+		if args[0] == optionCount {
+			opts.Count = number
+		} else {
+			opts.Block = true
+			opts.Timeout = time.Duration(number) * time.Millisecond
+		}
+
+		args = args[2:]
+	}
+
+	return opts, args, nil
+}
+
+func parseStreamIDs(texts []string) ([]engine.StreamID, error) {
+	ids := make([]engine.StreamID, 0, len(texts))
+
+	for _, text := range texts {
+		id, err := engine.ParseStreamID(text, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func formatStreamEntries(entries []engine.StreamEntry) string {
+	parts := make([]string, 0, 2*len(entries)) //nolint: mnd // id and value
+	for _, entry := range entries {
+		parts = append(parts, entry.ID.String(), entry.Value)
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,138 @@
+package compute
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+	"github.com/pingvincible/kvdatabase/internal/storage/engine"
+)
+
+const (
+	timestampNow = "*"
+
+	optionRetention   = "RETENTION"
+	optionAggregation = "AGG"
+)
+
+type TimeSeriesStorage interface {
+	TSCreate(key string, retention time.Duration) error
+	TSAdd(key string, sample engine.Sample, retention time.Duration) error
+	TSRange(key string, from, to int64, aggregation engine.Aggregation) ([]engine.Sample, error)
+}
+
+func (c *Computer) computeTimeSeries(
+	storage StorageInterface, session *Session, command parser.Command,
+) (string, error) {
+	switch command.Type {
+	case parser.CommandTSCreate:
+		retention, err := parseRetention(command.Args)
+		if err != nil {
+			return "", err
+		}
+
+		return "", storage.TSCreate(command.Key, retention)
+	case parser.CommandTSAdd:
+		return c.computeTSAdd(storage, session, command)
+	case parser.CommandTSRange:
+		return c.computeTSRange(storage, command)
+	}
+
+	return "", nil
+}
+
+// computeTSAdd handles TS.ADD key timestamp|* value [RETENTION ms] and returns the sample timestamp.
+func (c *Computer) computeTSAdd(storage StorageInterface, session *Session, command parser.Command) (string, error) {
+	timestamp := session.now().UnixMilli()
+
+	if command.Args[0] != timestampNow {
+		var err error
+
+		if timestamp, err = strconv.ParseInt(command.Args[0], 10, 64); err != nil {
+			return "", ErrInvalidNumber
+		}
+	}
+
+	value, err := parseFloat(command.Args[1])
+	if err != nil {
+		return "", err
+	}
+
+	retention, err := parseRetention(command.Args[2:])
+	if err != nil {
+		return "", err
+	}
+
+	err = storage.TSAdd(command.Key, engine.Sample{Timestamp: timestamp, Value: value}, retention)
+
+	return strconv.FormatInt(timestamp, 10), err
+}
+
+// computeTSRange handles TS.RANGE key from|- to|+ [AGG avg|min|max|sum bucket].
+func (c *Computer) computeTSRange(storage StorageInterface, command parser.Command) (string, error) {
+	from, err := parseTimestamp(command.Args[0], streamMinID, math.MinInt64)
+	if err != nil {
+		return "", err
+	}
+
+	to, err := parseTimestamp(command.Args[1], streamMaxID, math.MaxInt64)
+	if err != nil {
+		return "", err
+	}
+
+	var aggregation engine.Aggregation
+
+	switch options := command.Args[2:]; {
+	case len(options) == 0:
+	case len(options) == 3 && options[0] == optionAggregation: //nolint: mnd // AGG type bucket
+		aggregation.Type = engine.AggregationType(strings.ToLower(options[1]))
+
+		if aggregation.Bucket, err = strconv.ParseInt(options[2], 10, 64); err != nil {
+			return "", ErrInvalidNumber
+		}
+	default:
+		return "", ErrSyntax
+	}
+
+	samples, err := storage.TSRange(command.Key, from, to, aggregation)
+
+	parts := make([]string, 0, 2*len(samples)) //nolint: mnd // timestamp and value
+	for _, sample := range samples {
+		parts = append(parts, strconv.FormatInt(sample.Timestamp, 10), formatFloat(sample.Value))
+	}
+
+	return strings.Join(parts, " "), err
+}
+
+// parseRetention parses optional RETENTION ms options.
+func parseRetention(options []string) (time.Duration, error) {
+	switch {
+	case len(options) == 0:
+		return 0, nil
+	case len(options) == 2 && options[0] == optionRetention: //nolint: mnd // RETENTION ms
+		milliseconds, err := parseInt(options[1])
+		if err != nil || milliseconds < 0 {
+			return 0, ErrInvalidNumber
+		}
+
+		return time.Duration(milliseconds) * time.Millisecond, nil
+	default:
+		return 0, ErrSyntax
+	}
+}
+
+// parseTimestamp parses a millisecond timestamp or the special value standing for bound.
+func parseTimestamp(text, special string, bound int64) (int64, error) {
+	if text == special {
+		return bound, nil
+	}
+
+	timestamp, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidNumber
+	}
+
+	return timestamp, nil
+}
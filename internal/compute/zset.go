@@ -0,0 +1,117 @@
+package compute
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+	"github.com/pingvincible/kvdatabase/internal/storage/engine"
+)
+
+const withScores = "WITHSCORES"
+
+type ZSetStorage interface {
+	ZAdd(key string, members []engine.ZMember) (int, error)
+	ZRem(key string, members []string) (int, error)
+	ZRange(key string, start, stop int) ([]engine.ZMember, error)
+	ZRangeByScore(key string, minScore, maxScore float64) ([]engine.ZMember, error)
+	ZRank(key, member string) (int, bool, error)
+}
+
+func (c *Computer) computeZSet(storage StorageInterface, command parser.Command) (string, error) {
+	switch command.Type {
+	case parser.CommandZAdd:
+		return c.computeZAdd(storage, command)
+	case parser.CommandZRem:
+		removed, err := storage.ZRem(command.Key, command.Args)
+
+		return strconv.Itoa(removed), err
+	case parser.CommandZRank:
+		rank, ok, err := storage.ZRank(command.Key, command.Args[0])
+		if err != nil || !ok {
+			return "", err
+		}
+
+		return strconv.Itoa(rank), nil
+	case parser.CommandZRange:
+		return c.computeZRange(storage, command)
+	case parser.CommandZRangeByScore:
+		return c.computeZRangeByScore(storage, command)
+	}
+
+	return "", nil
+}
+
+// computeZAdd handles ZADD key score member [score member ...].
+func (c *Computer) computeZAdd(storage StorageInterface, command parser.Command) (string, error) {
+	if len(command.Args)%2 != 0 {
+		return "", ErrWrongArgumentsCount
+	}
+
+	members := make([]engine.ZMember, 0, len(command.Args)/2)
+
+	for i := 0; i < len(command.Args); i += 2 {
+		score, err := parseFloat(command.Args[i])
+		if err != nil {
+			return "", err
+		}
+
+		members = append(members, engine.ZMember{Member: command.Args[i+1], Score: score})
+	}
+
+	added, err := storage.ZAdd(command.Key, members)
+
+	return strconv.Itoa(added), err
+}
+
+// computeZRange handles ZRANGE key start stop [WITHSCORES].
+func (c *Computer) computeZRange(storage StorageInterface, command parser.Command) (string, error) {
+	start, err := parseInt(command.Args[0])
+	if err != nil {
+		return "", err
+	}
+
+	stop, err := parseInt(command.Args[1])
+	if err != nil {
+		return "", err
+	}
+
+	members, err := storage.ZRange(command.Key, start, stop)
+
+	return formatZMembers(members, hasWithScores(command.Args[2:])), err
+}
+
+// computeZRangeByScore handles ZRANGEBYSCORE key min max [WITHSCORES].
+func (c *Computer) computeZRangeByScore(storage StorageInterface, command parser.Command) (string, error) {
+	minScore, err := parseFloat(command.Args[0])
+	if err != nil {
+		return "", err
+	}
+
+	maxScore, err := parseFloat(command.Args[1])
+	if err != nil {
+		return "", err
+	}
+
+	members, err := storage.ZRangeByScore(command.Key, minScore, maxScore)
+
+	return formatZMembers(members, hasWithScores(command.Args[2:])), err
+}
+
+func hasWithScores(options []string) bool {
+	return len(options) > 0 && options[0] == withScores
+}
+
+func formatZMembers(members []engine.ZMember, scores bool) string {
+	parts := make([]string, 0, len(members))
+
+	for _, member := range members {
+		parts = append(parts, member.Member)
+
+		if scores {
+			parts = append(parts, formatFloat(member.Score))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -1,30 +1,61 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
+var ErrInvalidConfig = errors.New("invalid config")
+
+// Replication roles besides the default primary: a replica replicates its primary,
+// a cluster member replicates writes through the consensus of the cluster.
+const (
+	RoleReplica = "replica"
+	RoleCluster = "cluster"
+)
+
 type Flags struct {
-	EngineType     *string
-	Address        *string
-	MaxConnections *int
-	MaxMessageSize *string
-	IdleTimeout    *time.Duration
-	LoggingLevel   *string
-	LoggingOutput  *string
+	EngineType        *string
+	RetentionInterval *time.Duration
+	HistoryRevisions  *int64
+	Address           *string
+	MaxConnections    *int
+	MaxMessageSize    *string
+	IdleTimeout       *time.Duration
+	AdminCommands     *bool
+	LoggingLevel      *string
+	LoggingOutput     *string
+	ReplicationRole   *string
+	PrimaryAddress    *string
+	SyncInterval      *time.Duration
+	MinReplicas       *int
+	AckTimeout        *time.Duration
+	ClusterAddress    *string
+	ClusterMembers    *string
+	ElectionTimeout   *time.Duration
+	HeartbeatInterval *time.Duration
+	SnapshotEntries   *int64
+	StatePath         *string
+	ClusterSlots      *string
+	MigrationBatch    *int
 }
 
 type Config struct {
-	Engine  EngineConfig  `yaml:"engine" env-description:"database engine configuration"`
-	Network NetworkConfig `yaml:"network" env-description:"network configuration"`
-	Logging LogConfig     `yaml:"logging" env-description:"logging configuration"`
+	Engine      EngineConfig      `yaml:"engine" env-description:"database engine configuration"`
+	Network     NetworkConfig     `yaml:"network" env-description:"network configuration"`
+	Logging     LogConfig         `yaml:"logging" env-description:"logging configuration"`
+	Replication ReplicationConfig `yaml:"replication" env-description:"replication configuration"`
+	Cluster     ClusterConfig     `yaml:"cluster" env-description:"cluster configuration"`
 }
 
 type EngineConfig struct {
-	Type string `yaml:"type" env:"ENGINE_TYPE" env-default:"in-memory" env-description:"database engine type"`
+	Type              string        `yaml:"type" env:"ENGINE_TYPE" env-default:"in-memory" env-description:"database engine type"`                                                                                           //nolint: lll
+	RetentionInterval time.Duration `yaml:"retentionInterval" env:"ENGINE_RETENTION_INTERVAL" env-default:"1s" env-description:"interval of time series retention enforcement"`                                              //nolint: lll
+	HistoryRevisions  int64         `yaml:"historyRevisions" env:"ENGINE_HISTORY_REVISIONS" env-default:"1000" env-description:"number of latest revisions whose key versions and watch events are retained, 0 retains all"` //nolint: lll
 }
 
 type NetworkConfig struct {
@@ -32,6 +63,7 @@ type NetworkConfig struct {
 	MaxConnections int           `yaml:"maxConnections" env:"MAX_CONNECTIONS" env-default:"100" env-description:"max client connections"` //nolint: lll
 	MaxMessageSize string        `yaml:"maxMessageSize" env:"MAX_MESSAGE_SIZE" env-default:"4KB" env-description:"max message size"`      //nolint: lll
 	IdleTimeout    time.Duration `yaml:"idleTimeout" env:"IDLE_TIMEOUT" env-default:"5m" env-description:"idle timeout"`
+	AdminCommands  bool          `yaml:"adminCommands" env:"ADMIN_COMMANDS" env-default:"false" env-description:"allow clients to change the replication role, cluster members and slots, and nodes to import slots"` //nolint: lll
 }
 
 type LogConfig struct {
@@ -39,6 +71,25 @@ type LogConfig struct {
 	Output string `yaml:"output" env:"LOG_OUTPUT" env-default:"./kvdatabase.log" env-description:"log output filename"`
 }
 
+type ReplicationConfig struct {
+	Role           string        `yaml:"role" env:"REPLICATION_ROLE" env-default:"primary" env-description:"replication role, primary, replica or cluster"`                                                //nolint: lll
+	PrimaryAddress string        `yaml:"primaryAddress" env:"REPLICATION_PRIMARY_ADDRESS" env-description:"address of the primary a replica replicates"`                                                   //nolint: lll
+	SyncInterval   time.Duration `yaml:"syncInterval" env:"REPLICATION_SYNC_INTERVAL" env-default:"1s" env-description:"interval of pings to replicas and reconnects"`                                     //nolint: lll
+	MinReplicas    int           `yaml:"minReplicas" env:"REPLICATION_MIN_REPLICAS" env-default:"0" env-description:"replicas acknowledging every write before its response, 0 replicates asynchronously"` //nolint: lll
+	AckTimeout     time.Duration `yaml:"ackTimeout" env:"REPLICATION_ACK_TIMEOUT" env-default:"1s" env-description:"wait for replicas acknowledging a write, 0 waits forever"`                             //nolint: lll
+}
+
+type ClusterConfig struct {
+	Address           string        `yaml:"address" env:"CLUSTER_ADDRESS" env-description:"address members and clients reach the node at, defaults to the network address"`         //nolint: lll
+	Members           []string      `yaml:"members" env:"CLUSTER_MEMBERS" env-separator:"," env-description:"initial cluster members, empty to join an existing cluster"`           //nolint: lll
+	ElectionTimeout   time.Duration `yaml:"electionTimeout" env:"CLUSTER_ELECTION_TIMEOUT" env-default:"1s" env-description:"time without a leader before an election"`             //nolint: lll
+	HeartbeatInterval time.Duration `yaml:"heartbeatInterval" env:"CLUSTER_HEARTBEAT_INTERVAL" env-default:"100ms" env-description:"interval of leader heartbeats"`                 //nolint: lll
+	SnapshotEntries   int64         `yaml:"snapshotEntries" env:"CLUSTER_SNAPSHOT_ENTRIES" env-default:"10000" env-description:"applied log entries replaced with a snapshot"`      //nolint: lll
+	StatePath         string        `yaml:"statePath" env:"CLUSTER_STATE_PATH" env-default:"./kvdatabase.raft" env-description:"file the raft term, vote and log are persisted to"` //nolint: lll
+	Slots             []string      `yaml:"slots" env:"CLUSTER_SLOTS" env-separator:"," env-description:"hash slots assigned to nodes as from-to=address, empty serves all keys"`   //nolint: lll
+	MigrationBatch    int           `yaml:"migrationBatch" env:"CLUSTER_MIGRATION_BATCH" env-default:"100" env-description:"keys moved at a time when migrating slots"`             //nolint: lll
+}
+
 func Load(configPath string) (*Config, error) {
 	var cfg Config
 
@@ -49,12 +100,64 @@ func Load(configPath string) (*Config, error) {
 	return &cfg, nil
 }
 
+// Validate checks the values the database cannot run with, e.g. intervals of tickers
+// that must be positive.
+func (c *Config) Validate() error {
+	intervals := []struct {
+		name     string
+		interval time.Duration
+	}{
+		{name: "retentionInterval", interval: c.Engine.RetentionInterval},
+		{name: "syncInterval", interval: c.Replication.SyncInterval},
+		{name: "electionTimeout", interval: c.Cluster.ElectionTimeout},
+		{name: "heartbeatInterval", interval: c.Cluster.HeartbeatInterval},
+	}
+
+	for _, positive := range intervals {
+		if positive.interval <= 0 {
+			return fmt.Errorf("%w: %s must be positive, got %s", ErrInvalidConfig, positive.name, positive.interval)
+		}
+	}
+
+	// a cluster member restarting without its raft state could vote twice or forget entries
+	if c.Replication.Role == RoleCluster && c.Cluster.StatePath == "" {
+		return fmt.Errorf("%w: statePath is required in cluster mode", ErrInvalidConfig)
+	}
+
+	return nil
+}
+
 func (c *Config) UpdateWithFlags(flags Flags) {
 	c.Engine.Type = *flags.EngineType
+	c.Engine.RetentionInterval = *flags.RetentionInterval
+	c.Engine.HistoryRevisions = *flags.HistoryRevisions
 	c.Network.Address = *flags.Address
 	c.Network.MaxConnections = *flags.MaxConnections
 	c.Network.MaxMessageSize = *flags.MaxMessageSize
 	c.Network.IdleTimeout = *flags.IdleTimeout
+	c.Network.AdminCommands = *flags.AdminCommands
 	c.Logging.Level = *flags.LoggingLevel
 	c.Logging.Output = *flags.LoggingOutput
+	c.Replication.Role = *flags.ReplicationRole
+	c.Replication.PrimaryAddress = *flags.PrimaryAddress
+	c.Replication.SyncInterval = *flags.SyncInterval
+	c.Replication.MinReplicas = *flags.MinReplicas
+	c.Replication.AckTimeout = *flags.AckTimeout
+	c.Cluster.Address = *flags.ClusterAddress
+	c.Cluster.Members = splitList(*flags.ClusterMembers)
+	c.Cluster.ElectionTimeout = *flags.ElectionTimeout
+	c.Cluster.HeartbeatInterval = *flags.HeartbeatInterval
+	c.Cluster.SnapshotEntries = *flags.SnapshotEntries
+	c.Cluster.StatePath = *flags.StatePath
+	c.Cluster.Slots = splitList(*flags.ClusterSlots)
+	c.Cluster.MigrationBatch = *flags.MigrationBatch
+}
+
+// splitList splits a comma separated list given as a flag.
+func splitList(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	return strings.Split(text, ",")
 }
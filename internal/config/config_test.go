@@ -216,12 +216,28 @@ logging:
   level: "debug"
   output: "./debug.log"`
 	wantType := "flag-type"
+	wantRetentionInterval := 10 * time.Second
+	wantHistoryRevisions := int64(50)
 	wantAddress := "flag-address"
 	wantMaxConnections := 10000
 	wantMaxMessageSize := "100KB"
 	wantIdleTimeout := 500 * time.Minute
+	wantAdminCommands := true
 	wantLevel := "error"
 	wantOutput := "./flag.log"
+	wantRole := "replica"
+	wantPrimaryAddress := "127.0.0.1:3224"
+	wantSyncInterval := 2 * time.Second
+	wantMinReplicas := 2
+	wantAckTimeout := 3 * time.Second
+	wantClusterAddress := "127.0.0.1:4000"
+	wantClusterMembers := []string{"127.0.0.1:4000", "127.0.0.1:4001"}
+	wantElectionTimeout := 2 * time.Second
+	wantHeartbeatInterval := 200 * time.Millisecond
+	wantSnapshotEntries := int64(500)
+	wantStatePath := "./flag.raft"
+	wantClusterSlots := []string{"0-8191=127.0.0.1:4000", "8192-16383=127.0.0.1:4001"}
+	wantMigrationBatch := 20
 
 	t.Parallel()
 
@@ -239,30 +255,99 @@ logging:
 	cfg.UpdateWithFlags(*flags)
 
 	assert.Equal(t, wantType, cfg.Engine.Type)
+	assert.Equal(t, wantRetentionInterval, cfg.Engine.RetentionInterval)
+	assert.Equal(t, wantHistoryRevisions, cfg.Engine.HistoryRevisions)
 	assert.Equal(t, wantAddress, cfg.Network.Address)
 	assert.Equal(t, wantMaxConnections, cfg.Network.MaxConnections)
 	assert.Equal(t, wantMaxMessageSize, cfg.Network.MaxMessageSize)
 	assert.Equal(t, wantIdleTimeout, cfg.Network.IdleTimeout)
+	assert.Equal(t, wantAdminCommands, cfg.Network.AdminCommands)
 	assert.Equal(t, wantLevel, cfg.Logging.Level)
 	assert.Equal(t, wantOutput, cfg.Logging.Output)
+	assert.Equal(t, wantRole, cfg.Replication.Role)
+	assert.Equal(t, wantPrimaryAddress, cfg.Replication.PrimaryAddress)
+	assert.Equal(t, wantSyncInterval, cfg.Replication.SyncInterval)
+	assert.Equal(t, wantMinReplicas, cfg.Replication.MinReplicas)
+	assert.Equal(t, wantAckTimeout, cfg.Replication.AckTimeout)
+	assert.Equal(t, wantClusterAddress, cfg.Cluster.Address)
+	assert.Equal(t, wantClusterMembers, cfg.Cluster.Members)
+	assert.Equal(t, wantElectionTimeout, cfg.Cluster.ElectionTimeout)
+	assert.Equal(t, wantHeartbeatInterval, cfg.Cluster.HeartbeatInterval)
+	assert.Equal(t, wantSnapshotEntries, cfg.Cluster.SnapshotEntries)
+	assert.Equal(t, wantStatePath, cfg.Cluster.StatePath)
+	assert.Equal(t, wantClusterSlots, cfg.Cluster.Slots)
+	assert.Equal(t, wantMigrationBatch, cfg.Cluster.MigrationBatch)
+	require.NoError(t, cfg.Validate())
+
+	*flags.RetentionInterval = 0
+	cfg.UpdateWithFlags(*flags)
+	require.ErrorIs(t, cfg.Validate(), config.ErrInvalidConfig)
+
+	*flags.RetentionInterval = time.Second
+	*flags.SyncInterval = -time.Second
+	cfg.UpdateWithFlags(*flags)
+	require.ErrorIs(t, cfg.Validate(), config.ErrInvalidConfig)
+
+	*flags.SyncInterval = time.Second
+	*flags.HeartbeatInterval = 0
+	cfg.UpdateWithFlags(*flags)
+	require.ErrorIs(t, cfg.Validate(), config.ErrInvalidConfig)
+
+	*flags.HeartbeatInterval = time.Second
+	*flags.ReplicationRole = config.RoleCluster
+	*flags.StatePath = ""
+	cfg.UpdateWithFlags(*flags)
+	require.ErrorIs(t, cfg.Validate(), config.ErrInvalidConfig)
 }
 
 func createFlags() *config.Flags {
 	engineType := "flag-type"
+	retentionInterval := 10 * time.Second
+	historyRevisions := int64(50)
 	address := "flag-address"
 	maxConnections := 10000
 	maxMessageSize := "100KB"
 	idleTimeout := 500 * time.Minute
+	adminCommands := true
 	loggingLevel := "error"
 	loggingOutput := "./flag.log"
+	replicationRole := "replica"
+	primaryAddress := "127.0.0.1:3224"
+	syncInterval := 2 * time.Second
+	minReplicas := 2
+	ackTimeout := 3 * time.Second
+	clusterAddress := "127.0.0.1:4000"
+	clusterMembers := "127.0.0.1:4000,127.0.0.1:4001"
+	electionTimeout := 2 * time.Second
+	heartbeatInterval := 200 * time.Millisecond
+	snapshotEntries := int64(500)
+	statePath := "./flag.raft"
+	clusterSlots := "0-8191=127.0.0.1:4000,8192-16383=127.0.0.1:4001"
+	migrationBatch := 20
 
 	return &config.Flags{
-		EngineType:     &engineType,
-		Address:        &address,
-		MaxConnections: &maxConnections,
-		MaxMessageSize: &maxMessageSize,
-		IdleTimeout:    &idleTimeout,
-		LoggingLevel:   &loggingLevel,
-		LoggingOutput:  &loggingOutput,
+		EngineType:        &engineType,
+		RetentionInterval: &retentionInterval,
+		HistoryRevisions:  &historyRevisions,
+		Address:           &address,
+		MaxConnections:    &maxConnections,
+		MaxMessageSize:    &maxMessageSize,
+		IdleTimeout:       &idleTimeout,
+		AdminCommands:     &adminCommands,
+		LoggingLevel:      &loggingLevel,
+		LoggingOutput:     &loggingOutput,
+		ReplicationRole:   &replicationRole,
+		PrimaryAddress:    &primaryAddress,
+		SyncInterval:      &syncInterval,
+		MinReplicas:       &minReplicas,
+		AckTimeout:        &ackTimeout,
+		ClusterAddress:    &clusterAddress,
+		ClusterMembers:    &clusterMembers,
+		ElectionTimeout:   &electionTimeout,
+		HeartbeatInterval: &heartbeatInterval,
+		SnapshotEntries:   &snapshotEntries,
+		StatePath:         &statePath,
+		ClusterSlots:      &clusterSlots,
+		MigrationBatch:    &migrationBatch,
 	}
 }
@@ -0,0 +1,187 @@
+package lease
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrLeaseNotFound = errors.New("lease not found")
+
+// Key is a key of a namespace that can be attached to a lease.
+type Key struct {
+	Namespace string
+	Name      string
+}
+
+type lease struct {
+	ttl      time.Duration
+	deadline time.Time
+	timer    *time.Timer
+	keys     map[Key]struct{}
+}
+
+// Lessor grants leases with a time to live and tracks keys attached to them.
+// When a lease is not kept alive in time, expired is called with its ID,
+// the owner then removes the lease with Expire and deletes its keys.
+type Lessor struct {
+	mutex   sync.Mutex
+	leases  map[int64]*lease
+	owners  map[Key]int64
+	lastID  int64
+	expired func(id int64)
+}
+
+func NewLessor(expired func(id int64)) *Lessor {
+	return &Lessor{
+		leases:  make(map[int64]*lease),
+		owners:  make(map[Key]int64),
+		expired: expired,
+	}
+}
+
+// Grant creates a lease expiring after ttl unless kept alive and returns its ID.
+func (l *Lessor) Grant(ttl time.Duration) int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.lastID++
+	id := l.lastID
+
+	l.leases[id] = &lease{
+		ttl:      ttl,
+		deadline: time.Now().Add(ttl),
+		timer:    time.AfterFunc(ttl, func() { l.expired(id) }),
+		keys:     make(map[Key]struct{}),
+	}
+
+	return id
+}
+
+// KeepAlive renews the lease for its time to live and returns it.
+func (l *Lessor) KeepAlive(id int64) (time.Duration, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	lease, ok := l.leases[id]
+	if !ok {
+		return 0, fmt.Errorf("%w: %d", ErrLeaseNotFound, id)
+	}
+
+	lease.deadline = time.Now().Add(lease.ttl)
+	lease.timer.Reset(lease.ttl)
+
+	return lease.ttl, nil
+}
+
+// TimeToLive returns the time left until the lease expires.
+func (l *Lessor) TimeToLive(id int64) (time.Duration, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	lease, ok := l.leases[id]
+	if !ok {
+		return 0, fmt.Errorf("%w: %d", ErrLeaseNotFound, id)
+	}
+
+	return max(time.Until(lease.deadline), 0), nil
+}
+
+// Revoke removes the lease and returns its keys, which the caller deletes.
+func (l *Lessor) Revoke(id int64) ([]Key, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if _, ok := l.leases[id]; !ok {
+		return nil, fmt.Errorf("%w: %d", ErrLeaseNotFound, id)
+	}
+
+	return l.remove(id), nil
+}
+
+// Expire removes the lease if its deadline has passed and returns its keys.
+// It reports false if the lease was revoked or kept alive meanwhile.
+func (l *Lessor) Expire(id int64) ([]Key, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	lease, ok := l.leases[id]
+	if !ok || time.Now().Before(lease.deadline) {
+		return nil, false
+	}
+
+	return l.remove(id), true
+}
+
+// Attach attaches key to the lease, detaching it from its previous lease.
+func (l *Lessor) Attach(id int64, key Key) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	lease, ok := l.leases[id]
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrLeaseNotFound, id)
+	}
+
+	l.detach(key)
+
+	lease.keys[key] = struct{}{}
+	l.owners[key] = id
+
+	return nil
+}
+
+// Detach detaches key from its lease, if any.
+func (l *Lessor) Detach(key Key) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.detach(key)
+}
+
+// DetachNamespace detaches all keys of namespace from their leases.
+func (l *Lessor) DetachNamespace(namespace string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for key := range l.owners {
+		if key.Namespace == namespace {
+			l.detach(key)
+		}
+	}
+}
+
+func (l *Lessor) detach(key Key) {
+	id, ok := l.owners[key]
+	if !ok {
+		return
+	}
+
+	delete(l.leases[id].keys, key)
+	delete(l.owners, key)
+}
+
+func (l *Lessor) remove(id int64) []Key {
+	lease := l.leases[id]
+	lease.timer.Stop()
+
+	keys := slices.SortedFunc(maps.Keys(lease.keys), func(a, b Key) int {
+		if a.Namespace != b.Namespace {
+			return strings.Compare(a.Namespace, b.Namespace)
+		}
+
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	for _, key := range keys {
+		delete(l.owners, key)
+	}
+
+	delete(l.leases, id)
+
+	return keys
+}
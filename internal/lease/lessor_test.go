@@ -0,0 +1,63 @@
+package lease_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/lease"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLessorRevoke(t *testing.T) {
+	t.Parallel()
+
+	lessor := lease.NewLessor(func(int64) {})
+	first, second := lessor.Grant(time.Minute), lessor.Grant(time.Minute)
+	key, other := lease.Key{Namespace: "0", Name: "service/1"}, lease.Key{Namespace: "0", Name: "service/2"}
+
+	require.NoError(t, lessor.Attach(first, key))
+	require.NoError(t, lessor.Attach(first, other))
+	require.NoError(t, lessor.Attach(second, other))
+	require.ErrorIs(t, lessor.Attach(3, key), lease.ErrLeaseNotFound)
+
+	keys, err := lessor.Revoke(first)
+	require.NoError(t, err)
+	assert.Equal(t, []lease.Key{key}, keys)
+
+	_, err = lessor.Revoke(first)
+	require.ErrorIs(t, err, lease.ErrLeaseNotFound)
+
+	lessor.Detach(other)
+
+	keys, err = lessor.Revoke(second)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestLessorExpire(t *testing.T) {
+	t.Parallel()
+
+	expired := make(chan int64, 1)
+	lessor := lease.NewLessor(func(id int64) { expired <- id })
+	id := lessor.Grant(50 * time.Millisecond)
+	key := lease.Key{Namespace: "0", Name: "service/1"}
+
+	require.NoError(t, lessor.Attach(id, key))
+
+	_, ok := lessor.Expire(id)
+	assert.False(t, ok)
+
+	ttl, err := lessor.KeepAlive(id)
+	require.NoError(t, err)
+	assert.Equal(t, 50*time.Millisecond, ttl)
+
+	assert.Equal(t, id, <-expired)
+
+	keys, ok := lessor.Expire(id)
+	assert.True(t, ok)
+	assert.Equal(t, []lease.Key{key}, keys)
+
+	_, err = lessor.TimeToLive(id)
+	require.ErrorIs(t, err, lease.ErrLeaseNotFound)
+}
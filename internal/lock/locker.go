@@ -0,0 +1,260 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotHeld  = errors.New("lock is not held with the token")
+	ErrReleased = errors.New("lock owner went away while waiting")
+)
+
+// minSweep is the number of locks below which expired locks are not swept.
+const minSweep = 64
+
+type lock struct {
+	token    int64
+	owner    any
+	deadline time.Time
+}
+
+func (l lock) expired(now time.Time) bool {
+	return !now.Before(l.deadline)
+}
+
+// waiter is an owner waiting for a held lock, it is woken once the lock is released
+// and cancelled once the owner goes away.
+type waiter struct {
+	owner     any
+	wake      chan struct{}
+	cancelled bool
+}
+
+// Locker issues named locks held until their TTL runs out, they are released or
+// their owner goes away. Every acquisition gets a fencing token greater than all
+// tokens issued before, so resources can reject writes of a holder that lost its lock.
+type Locker struct {
+	mutex     sync.Mutex
+	locks     map[string]lock
+	waiters   map[string][]*waiter
+	lastToken int64
+	sweepAt   int // number of locks at which expired locks are swept
+}
+
+func NewLocker() *Locker {
+	return &Locker{
+		locks:   make(map[string]lock),
+		waiters: make(map[string][]*waiter),
+		sweepAt: minSweep,
+	}
+}
+
+// Lock acquires the lock name for owner for ttl and returns its fencing token.
+// If the lock is held, it waits up to timeout for the lock to be released,
+// a zero timeout waits forever and a negative one does not wait at all.
+// It reports false if the lock was not acquired in time.
+func (l *Locker) Lock(
+	ctx context.Context,
+	name string,
+	owner any,
+	ttl time.Duration,
+	timeout time.Duration,
+) (int64, bool, error) {
+	var expired <-chan time.Time
+
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		expired = timer.C
+	}
+
+	for {
+		// the lock must not be acquired for an owner that went away while waiting
+		if err := ctx.Err(); err != nil {
+			return 0, false, err
+		}
+
+		l.mutex.Lock()
+
+		now := time.Now()
+
+		held, ok := l.lookup(name, now)
+		if !ok {
+			l.lastToken++
+			token := l.lastToken
+			l.locks[name] = lock{token: token, owner: owner, deadline: now.Add(ttl)}
+			l.sweep(now)
+			l.mutex.Unlock()
+
+			return token, true, nil
+		}
+
+		if timeout < 0 {
+			l.mutex.Unlock()
+
+			return 0, false, nil
+		}
+
+		wait := &waiter{owner: owner, wake: make(chan struct{}, 1)}
+		l.waiters[name] = append(l.waiters[name], wait)
+
+		l.mutex.Unlock()
+
+		// the holder may neither release nor renew the lock, retry once it expires
+		expiry := time.NewTimer(held.deadline.Sub(now))
+
+		var err error
+
+		timedOut := false
+
+		select {
+		case <-wait.wake:
+		case <-expiry.C:
+		case <-expired:
+			timedOut = true
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+
+		expiry.Stop()
+
+		l.mutex.Lock()
+		l.removeWaiter(name, wait)
+
+		if wait.cancelled {
+			err = ErrReleased
+		}
+
+		l.mutex.Unlock()
+
+		if timedOut || err != nil {
+			return 0, false, err
+		}
+	}
+}
+
+// Renew extends the lock held with token for ttl from now.
+func (l *Locker) Renew(name string, token int64, ttl time.Duration) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+
+	held, ok := l.lookup(name, now)
+	if !ok || held.token != token {
+		return fmt.Errorf("%w: %s", ErrNotHeld, name)
+	}
+
+	held.deadline = now.Add(ttl)
+	l.locks[name] = held
+
+	return nil
+}
+
+// Unlock releases the lock held with token.
+func (l *Locker) Unlock(name string, token int64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	held, ok := l.lookup(name, time.Now())
+	if !ok || held.token != token {
+		return fmt.Errorf("%w: %s", ErrNotHeld, name)
+	}
+
+	l.release(name)
+
+	return nil
+}
+
+// lookup returns the lock name unless it expired, an expired lock is removed.
+// It must be called with the mutex held.
+func (l *Locker) lookup(name string, now time.Time) (lock, bool) {
+	held, ok := l.locks[name]
+	if ok && held.expired(now) {
+		l.release(name)
+
+		return lock{}, false
+	}
+
+	return held, ok
+}
+
+// Release releases all locks held by owner and cancels its waits, e.g. when its
+// connection is closed.
+func (l *Locker) Release(owner any) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for name, held := range l.locks {
+		if held.owner == owner {
+			l.release(name)
+		}
+	}
+
+	for _, waiters := range l.waiters {
+		for _, wait := range waiters {
+			if wait.owner == owner {
+				wait.cancelled = true
+				wait.notify()
+			}
+		}
+	}
+}
+
+// Len returns the number of locks kept, including expired ones not swept yet.
+func (l *Locker) Len() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return len(l.locks)
+}
+
+// release removes the lock and wakes its waiters, it must be called with the mutex held.
+func (l *Locker) release(name string) {
+	delete(l.locks, name)
+
+	for _, wait := range l.waiters[name] {
+		wait.notify()
+	}
+}
+
+// sweep removes expired locks once their number doubled since the last sweep, so that
+// locks nobody accesses anymore do not pile up. It must be called with the mutex held.
+func (l *Locker) sweep(now time.Time) {
+	if len(l.locks) < l.sweepAt {
+		return
+	}
+
+	for name, held := range l.locks {
+		if held.expired(now) {
+			l.release(name)
+		}
+	}
+
+	l.sweepAt = max(minSweep, 2*len(l.locks)) //nolint: mnd // sweep once the number doubled
+}
+
+func (w *waiter) notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (l *Locker) removeWaiter(name string, wait *waiter) {
+	waiters := slices.DeleteFunc(l.waiters[name], func(w *waiter) bool {
+		return w == wait
+	})
+
+	if len(waiters) == 0 {
+		delete(l.waiters, name)
+	} else {
+		l.waiters[name] = waiters
+	}
+}
@@ -0,0 +1,126 @@
+package lock_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/lock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockerFencingTokens(t *testing.T) {
+	t.Parallel()
+
+	locker := lock.NewLocker()
+	ctx := context.Background()
+
+	first, ok, err := locker.Lock(ctx, "job", "a", time.Minute, -1)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, ok, err = locker.Lock(ctx, "job", "b", time.Minute, -1)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.ErrorIs(t, locker.Unlock("job", first+1), lock.ErrNotHeld)
+	require.NoError(t, locker.Renew("job", first, time.Minute))
+	require.NoError(t, locker.Unlock("job", first))
+	require.ErrorIs(t, locker.Unlock("job", first), lock.ErrNotHeld)
+
+	second, ok, err := locker.Lock(ctx, "job", "b", time.Minute, -1)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Greater(t, second, first)
+}
+
+func TestLockerWait(t *testing.T) {
+	t.Parallel()
+
+	locker := lock.NewLocker()
+	ctx := context.Background()
+
+	_, _, err := locker.Lock(ctx, "job", "a", time.Minute, -1)
+	require.NoError(t, err)
+
+	_, ok, err := locker.Lock(ctx, "job", "b", time.Minute, 20*time.Millisecond)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		locker.Release("a")
+	}()
+
+	_, ok, err = locker.Lock(ctx, "job", "b", 50*time.Millisecond, 0)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// the lock of b is not renewed and expires
+	_, ok, err = locker.Lock(ctx, "job", "c", time.Minute, time.Second)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, ok, err = locker.Lock(cancelled, "job", "d", time.Minute, 0)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.False(t, ok)
+}
+
+func TestLockerReleaseCancelsWaiters(t *testing.T) {
+	t.Parallel()
+
+	locker := lock.NewLocker()
+	ctx := context.Background()
+
+	_, _, err := locker.Lock(ctx, "job", "a", time.Minute, -1)
+	require.NoError(t, err)
+
+	waited := make(chan error)
+
+	go func() {
+		_, _, err := locker.Lock(ctx, "job", "b", time.Minute, 0)
+		waited <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	// the owner goes away while waiting, the lock is released to nobody
+	locker.Release("b")
+	require.ErrorIs(t, <-waited, lock.ErrReleased)
+
+	locker.Release("a")
+	assert.Equal(t, 0, locker.Len())
+}
+
+func TestLockerDropsExpiredLocks(t *testing.T) {
+	t.Parallel()
+
+	locker := lock.NewLocker()
+	ctx := context.Background()
+
+	token, _, err := locker.Lock(ctx, "job", "a", time.Millisecond, -1)
+	require.NoError(t, err)
+
+	for i := range 50 {
+		_, _, err = locker.Lock(ctx, "job/"+strconv.Itoa(i), "a", time.Millisecond, -1)
+		require.NoError(t, err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	require.ErrorIs(t, locker.Unlock("job", token), lock.ErrNotHeld)
+	assert.Equal(t, 50, locker.Len())
+
+	// expired locks nobody accesses anymore are swept once the locks pile up
+	for i := range 100 {
+		_, _, err = locker.Lock(ctx, "other/"+strconv.Itoa(i), "a", time.Minute, -1)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 100, locker.Len())
+}
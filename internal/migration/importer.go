@@ -0,0 +1,115 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pingvincible/kvdatabase/internal/kvio"
+	"github.com/pingvincible/kvdatabase/internal/replication"
+	"github.com/pingvincible/kvdatabase/internal/slot"
+)
+
+var ErrUnexpectedLine = errors.New("unexpected migration line")
+
+// Target is the database slots are migrated to.
+type Target interface {
+	BeginImport(slot int, source string) error
+	Import(entries []replication.Entry) error
+	EndImport(slot int) error
+}
+
+// Importer receives slots migrated to its target by other nodes.
+type Importer struct {
+	target Target
+	logger *slog.Logger
+}
+
+func NewImporter(target Target, logger *slog.Logger) *Importer {
+	return &Importer{target: target, logger: logger}
+}
+
+// Accepts reports whether request is sent by a node migrating a slot to the target.
+func (i *Importer) Accepts(request string) bool {
+	fields := strings.Fields(request)
+
+	return len(fields) > 0 && fields[0] == importRequest
+}
+
+// Serve imports the slot migrated by the node connected over conn until it is handed over.
+func (i *Importer) Serve(ctx context.Context, conn net.Conn, readWriter *kvio.ReadWriter, request string) error {
+	stopClosing := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stopClosing()
+
+	fields := strings.Fields(request)
+	if len(fields) != 3 { //nolint: mnd // IMPORT slot source
+		return reply(readWriter, fmt.Errorf("%w: %s", ErrUnexpectedLine, request))
+	}
+
+	imported, err := strconv.Atoi(fields[1])
+	if err != nil || imported < 0 || imported >= slot.Count {
+		return reply(readWriter, fmt.Errorf("%w: %s", ErrUnexpectedLine, request))
+	}
+
+	err = reply(readWriter, i.target.BeginImport(imported, fields[2]))
+	if err != nil {
+		return err
+	}
+
+	var entries []replication.Entry
+
+	for {
+		line, err := readWriter.ReadLine()
+		if err != nil {
+			return fmt.Errorf("failed to read from source: %w", err)
+		}
+
+		switch {
+		case strings.HasPrefix(line, batchLine):
+			err = i.target.Import(entries)
+			entries = entries[:0]
+		case strings.HasPrefix(line, handoverLine):
+			err = i.target.EndImport(imported)
+			if err != nil {
+				return reply(readWriter, err)
+			}
+
+			i.logger.Info("imported slot", slog.Int("slot", imported), slog.String("source", fields[2]))
+
+			return reply(readWriter, nil)
+		default:
+			var entry replication.Entry
+
+			entry, err = replication.ParseEntry(line)
+			if err == nil {
+				entries = append(entries, entry)
+
+				continue
+			}
+		}
+
+		err = reply(readWriter, err)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// reply replies ok or the error, an error is also returned.
+func reply(readWriter *kvio.ReadWriter, result error) error {
+	line := okLine
+	if result != nil {
+		line = errorPrefix + result.Error()
+	}
+
+	err := readWriter.WriteLine(line)
+	if err != nil {
+		return fmt.Errorf("failed to reply to source: %w", err)
+	}
+
+	return result
+}
@@ -0,0 +1,56 @@
+package migration_test
+
+import (
+	"testing"
+
+	"github.com/pingvincible/kvdatabase/internal/migration"
+	"github.com/pingvincible/kvdatabase/internal/slot"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlan(t *testing.T) {
+	t.Parallel()
+
+	apply := func(slots *slot.Map, moves []migration.Move) map[string]int {
+		for _, move := range moves {
+			slots.Assign(move.Range)
+		}
+
+		counts := make(map[string]int)
+		for keySlot := range slot.Count {
+			counts[slots.Owner(keySlot)]++
+		}
+
+		return counts
+	}
+
+	slots := slot.NewMap()
+
+	moves := migration.Plan(slots, []string{"a", "b"})
+	require.Len(t, moves, 2)
+	assert.Equal(t, migration.Move{Range: slot.Range{From: 0, To: 8191, Address: "a"}}, moves[0])
+	assert.Equal(t, map[string]int{"a": 8192, "b": 8192}, apply(slots, moves))
+
+	// an added node takes a third of the slots of each node
+	moves = migration.Plan(slots, []string{"a", "b", "c"})
+	moved := 0
+
+	for _, move := range moves {
+		assert.Equal(t, "c", move.Range.Address)
+		moved += move.Range.To - move.Range.From + 1
+	}
+
+	assert.Equal(t, 5461, moved)
+	assert.Equal(t, map[string]int{"a": 5462, "b": 5461, "c": 5461}, apply(slots, moves))
+
+	// slots of a removed node are spread over the others
+	moves = migration.Plan(slots, []string{"a", "c"})
+
+	for _, move := range moves {
+		assert.Equal(t, "b", move.Source)
+	}
+
+	assert.Equal(t, map[string]int{"a": 8192, "c": 8192}, apply(slots, moves))
+	assert.Empty(t, migration.Plan(slots, []string{"a", "c"}))
+}
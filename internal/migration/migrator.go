@@ -0,0 +1,144 @@
+package migration
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/kvio"
+	"github.com/pingvincible/kvdatabase/internal/replication"
+)
+
+var ErrRejected = errors.New("node rejected the request")
+
+// Lines of the migration protocol. The source sends IMPORT <slot> <source address>, then
+// batches of entries each followed by batch <entries> and finally handover, after which
+// the target serves the slot. The target replies to each of them with ok or an error line.
+const (
+	importRequest = "IMPORT"
+	batchLine     = "batch"
+	handoverLine  = "handover"
+	okLine        = "ok"
+	errorPrefix   = "error: "
+)
+
+// replyTimeout bounds the wait for a reply of the target.
+const replyTimeout = 30 * time.Second
+
+// Source is the database slots are migrated from.
+type Source interface {
+	BeginMigration(slot int, target string) error
+	MigrateKeys(slot, count int, send func([]replication.Entry) error) (int, error)
+	Handover(slot int, target string, send func([]replication.Entry) error, handover func() error) error
+}
+
+// Migrator moves slots of its source to other nodes while the source serves commands.
+type Migrator struct {
+	source  Source
+	address string
+	batch   int
+	logger  *slog.Logger
+}
+
+// NewMigrator creates a migrator of the source reachable at address moving batch keys at a time.
+func NewMigrator(source Source, address string, batch int, logger *slog.Logger) *Migrator {
+	return &Migrator{
+		source:  source,
+		address: address,
+		batch:   max(batch, 1),
+		logger:  logger,
+	}
+}
+
+// Migrate moves the keys of slot to the node at target in batches and hands the slot over
+// to it. It returns the number of moved keys. A failed migration leaves the slot migrating,
+// migrating it again resumes it.
+func (m *Migrator) Migrate(ctx context.Context, slot int, target string) (int, error) {
+	err := m.source.BeginMigration(slot, target)
+	if err != nil {
+		return 0, err
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", target)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to target: %w", err)
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	stopClosing := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stopClosing()
+
+	readWriter := kvio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	exchange := func(lines ...string) error {
+		_ = conn.SetDeadline(time.Now().Add(replyTimeout))
+
+		return exchange(readWriter, lines)
+	}
+
+	err = exchange(strings.Join([]string{importRequest, strconv.Itoa(slot), m.address}, " "))
+	if err != nil {
+		return 0, err
+	}
+
+	send := func(entries []replication.Entry) error {
+		lines := make([]string, 0, len(entries)+1)
+		for _, entry := range entries {
+			lines = append(lines, entry.String())
+		}
+
+		return exchange(append(lines, batchLine+" "+strconv.Itoa(len(entries)))...)
+	}
+
+	total := 0
+
+	for {
+		moved, err := m.source.MigrateKeys(slot, m.batch, send)
+		total += moved
+
+		if err != nil {
+			return total, err
+		}
+
+		if moved == 0 {
+			break
+		}
+	}
+
+	err = m.source.Handover(slot, target, send, func() error { return exchange(handoverLine) })
+	if err != nil {
+		return total, err
+	}
+
+	m.logger.Info("migrated slot", slog.Int("slot", slot), slog.String("target", target), slog.Int("keys", total))
+
+	return total, nil
+}
+
+// exchange sends lines and reads the reply to them.
+func exchange(readWriter *kvio.ReadWriter, lines []string) error {
+	for _, line := range lines {
+		err := readWriter.WriteLine(line)
+		if err != nil {
+			return fmt.Errorf("failed to send to target: %w", err)
+		}
+	}
+
+	reply, err := readWriter.ReadLine()
+	if err != nil {
+		return fmt.Errorf("failed to read from target: %w", err)
+	}
+
+	reply = strings.TrimSpace(reply)
+	if reply != okLine {
+		return fmt.Errorf("%w: %s", ErrRejected, strings.TrimPrefix(reply, errorPrefix))
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package migration
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/pingvincible/kvdatabase/internal/kvio"
+	"github.com/pingvincible/kvdatabase/internal/slot"
+)
+
+// assignChunk is the number of ranges assigned by a single SLOTS.ASSIGN command.
+const assignChunk = 32
+
+// Move reassigns the slots of Range to Range.Address, the node at Source serves them now.
+// Slots without a source are unassigned.
+type Move struct {
+	Range  slot.Range
+	Source string
+}
+
+// Plan returns the moves assigning slots evenly to nodes, it keeps as many slots as possible
+// on the nodes serving them. Slots of nodes not in nodes are moved.
+func Plan(slots *slot.Map, nodes []string) []Move {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	quota := make(map[string]int, len(nodes))
+	for i, node := range nodes {
+		quota[node] = slot.Count / len(nodes)
+		if i < slot.Count%len(nodes) {
+			quota[node]++
+		}
+	}
+
+	kept := make(map[string]int, len(nodes))
+
+	var surplus []int
+
+	for keySlot := range slot.Count {
+		owner := slots.Owner(keySlot)
+		if limit, ok := quota[owner]; ok && kept[owner] < limit {
+			kept[owner]++
+
+			continue
+		}
+
+		surplus = append(surplus, keySlot)
+	}
+
+	var moves []Move
+
+	node := 0
+
+	for _, keySlot := range surplus {
+		for kept[nodes[node]] >= quota[nodes[node]] {
+			node++
+		}
+
+		kept[nodes[node]]++
+
+		source, target := slots.Owner(keySlot), nodes[node]
+
+		last := len(moves) - 1
+		if last >= 0 && moves[last].Range.To == keySlot-1 && moves[last].Range.Address == target &&
+			moves[last].Source == source {
+			moves[last].Range.To = keySlot
+
+			continue
+		}
+
+		moves = append(moves, Move{Range: slot.Range{From: keySlot, To: keySlot, Address: target}, Source: source})
+	}
+
+	return moves
+}
+
+// Rebalance assigns slots evenly to nodes, e.g. after nodes are added or removed.
+// It learns the slot map from the first node, shares it with all nodes and migrates
+// the slots moved to other nodes, keys of unassigned slots are not migrated.
+func Rebalance(ctx context.Context, nodes []string, logger *slog.Logger) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	response, err := request(ctx, nodes[0], "SLOTS")
+	if err != nil {
+		return err
+	}
+
+	slots, err := slot.ParseMap(strings.Fields(response))
+	if err != nil {
+		return fmt.Errorf("failed to learn slots: %w", err)
+	}
+
+	members := slices.Clone(nodes)
+
+	for _, assigned := range slots.Ranges() {
+		if !slices.Contains(members, assigned.Address) {
+			members = append(members, assigned.Address)
+		}
+	}
+
+	err = assign(ctx, members, slots.Ranges())
+	if err != nil {
+		return err
+	}
+
+	for _, move := range Plan(slots, nodes) {
+		if move.Source != "" {
+			logger.Info("migrating slots", slog.String("range", move.Range.String()), slog.String("source", move.Source))
+
+			slotRange := strings.TrimSuffix(move.Range.String(), "="+move.Range.Address)
+
+			_, err = request(ctx, move.Source, "SLOTS.MIGRATE "+slotRange+" "+move.Range.Address)
+			if err != nil {
+				return fmt.Errorf("failed to migrate slots %s: %w", move.Range, err)
+			}
+		}
+
+		err = assign(ctx, members, []slot.Range{move.Range})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// assign assigns ranges on all nodes.
+func assign(ctx context.Context, nodes []string, ranges []slot.Range) error {
+	for chunk := range slices.Chunk(ranges, assignChunk) {
+		fields := make([]string, 0, len(chunk))
+		for _, assigned := range chunk {
+			fields = append(fields, assigned.String())
+		}
+
+		for _, node := range nodes {
+			_, err := request(ctx, node, "SLOTS.ASSIGN "+strings.Join(fields, " "))
+			if err != nil {
+				return fmt.Errorf("failed to assign slots on %s: %w", node, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// request sends command to the node at address and returns the response.
+func request(ctx context.Context, address, command string) (string, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	stopClosing := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stopClosing()
+
+	readWriter := kvio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+
+	err = readWriter.WriteLine(command)
+	if err != nil {
+		return "", fmt.Errorf("failed to send command: %w", err)
+	}
+
+	response, err := readWriter.ReadLine()
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	response = strings.TrimSuffix(response, "\n")
+	if message, ok := strings.CutPrefix(response, errorPrefix); ok {
+		return "", fmt.Errorf("%w: %s", ErrRejected, message)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/kvio"
+)
+
+// backendConn is a connection to a backend.
+type backendConn struct {
+	conn       net.Conn
+	readWriter *kvio.ReadWriter
+}
+
+// request sends command and returns the response without the line break.
+// The connection is closed when ctx is done, e.g. to abort blocking commands.
+func (c *backendConn) request(ctx context.Context, command string) (string, error) {
+	stopClosing := context.AfterFunc(ctx, func() { _ = c.conn.Close() })
+	defer stopClosing()
+
+	err := c.readWriter.WriteLine(command)
+	if err != nil {
+		return "", fmt.Errorf("failed to send command: %w", err)
+	}
+
+	response, err := c.readWriter.ReadLine()
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return strings.TrimSuffix(response, "\n"), nil
+}
+
+// backend is a server the proxy routes commands to, it keeps up to size idle connections
+// for reuse.
+type backend struct {
+	address string
+	timeout time.Duration
+	idle    chan *backendConn
+	down    atomic.Bool
+}
+
+func newBackend(address string, size int, timeout time.Duration) *backend {
+	return &backend{
+		address: address,
+		timeout: timeout,
+		idle:    make(chan *backendConn, size),
+	}
+}
+
+// request sends command over an idle connection or a new one if there is none.
+// Connections failing a request are closed instead of reused.
+func (b *backend) request(ctx context.Context, command string) (string, error) {
+	if b.down.Load() {
+		return "", fmt.Errorf("%w: %s", ErrBackendDown, b.address)
+	}
+
+	conn, err := b.get(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := conn.request(ctx, command)
+	if err != nil {
+		_ = conn.conn.Close()
+
+		return "", fmt.Errorf("backend %s: %w", b.address, err)
+	}
+
+	b.put(conn)
+
+	return response, nil
+}
+
+func (b *backend) get(ctx context.Context) (*backendConn, error) {
+	select {
+	case conn := <-b.idle:
+		return conn, nil
+	default:
+	}
+
+	return b.dial(ctx)
+}
+
+func (b *backend) dial(ctx context.Context) (*backendConn, error) {
+	conn, err := (&net.Dialer{Timeout: b.timeout}).DialContext(ctx, "tcp", b.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to backend %s: %w", b.address, err)
+	}
+
+	return &backendConn{
+		conn:       conn,
+		readWriter: kvio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)),
+	}, nil
+}
+
+func (b *backend) put(conn *backendConn) {
+	select {
+	case b.idle <- conn:
+	default:
+		_ = conn.conn.Close()
+	}
+}
+
+// drain closes the idle connections.
+func (b *backend) drain() {
+	for {
+		select {
+		case conn := <-b.idle:
+			_ = conn.conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// check sends INFO over a new connection, a backend failing to reply within the timeout
+// is marked down until it replies again. It reports whether the state changed.
+func (b *backend) check(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, b.timeout)
+	defer cancel()
+
+	// a pooled connection may be broken while the backend is up, e.g. after a restart
+	conn, err := b.dial(ctx)
+	if err == nil {
+		_, err = conn.request(ctx, "INFO")
+		if err == nil {
+			b.put(conn)
+		} else {
+			_ = conn.conn.Close()
+		}
+	}
+
+	down := err != nil
+	if down {
+		b.drain()
+	}
+
+	return b.down.Swap(down) != down
+}
@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
+)
+
+var (
+	ErrNoBackends    = errors.New("no backends configured")
+	ErrInvalidConfig = errors.New("invalid proxy config")
+	ErrBackendDown   = errors.New("backend is down")
+	ErrUnsupported   = errors.New("command is not supported by the proxy")
+	ErrCrossBackend  = errors.New("CROSSBACKEND keys of the command are on different backends, use a hash tag")
+)
+
+const errorPrefix = "error: "
+
+// unsupportedCommands depend on the state of a client connection or a single server,
+// which pooled backend connections do not keep.
+func unsupportedCommands() map[parser.CommandType]bool {
+	return map[parser.CommandType]bool{
+		parser.CommandSelect: true, parser.CommandFlush: true, parser.CommandNamespaces: true,
+		parser.CommandSubscribe: true, parser.CommandPSubscribe: true, parser.CommandUnsubscribe: true,
+		parser.CommandPUnsubscribe: true, parser.CommandWatch: true, parser.CommandUnwatch: true,
+		parser.CommandCompact: true, parser.CommandLease: true, parser.CommandWait: true,
+		parser.CommandReplicaOf: true, parser.CommandPromote: true, parser.CommandClusterAdd: true,
+		parser.CommandClusterRemove: true, parser.CommandSlots: true, parser.CommandSlotsAssign: true,
+		parser.CommandSlotsMigrate: true, parser.CommandAsking: true,
+	}
+}
+
+type Config struct {
+	Backends       []string
+	PoolSize       int           // idle connections kept per backend
+	Timeout        time.Duration // timeout of connecting to backends and of health checks
+	HealthInterval time.Duration
+}
+
+// Proxy routes commands to backends by consistent hashing of their keys.
+type Proxy struct {
+	cfg      Config
+	ring     *Ring
+	backends map[string]*backend
+	logger   *slog.Logger
+}
+
+func New(cfg Config, logger *slog.Logger) (*Proxy, error) {
+	if len(cfg.Backends) == 0 {
+		return nil, ErrNoBackends
+	}
+
+	if cfg.Timeout <= 0 || cfg.HealthInterval <= 0 || cfg.PoolSize < 0 {
+		return nil, fmt.Errorf("%w: timeout and health interval must be positive and pool size not negative",
+			ErrInvalidConfig)
+	}
+
+	backends := make(map[string]*backend, len(cfg.Backends))
+	for _, address := range cfg.Backends {
+		backends[address] = newBackend(address, cfg.PoolSize, cfg.Timeout)
+	}
+
+	return &Proxy{
+		cfg:      cfg,
+		ring:     NewRing(cfg.Backends),
+		backends: backends,
+		logger:   logger,
+	}, nil
+}
+
+// Process routes a command to the backend of its keys and returns its response. DBSIZE and
+// PUBLISH are sent to all backends. Of the multi-key commands with keys on several backends,
+// only SINTER and SUNION are fanned out and their results merged. The others fail with
+// ErrCrossBackend: PFCOUNT counts the union of the sketches, PFMERGE and BITOP write a key
+// from keys of other backends, BLPOP pops a single element and XREAD and XREADGROUP block
+// on all their streams. Keys sharing a hash tag are kept on one backend.
+func (p *Proxy) Process(ctx context.Context, text string) (string, error) {
+	command, err := parser.Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse command: %w", err)
+	}
+
+	switch command.Type { //nolint: exhaustive // other commands are routed by their keys
+	case parser.CommandInfo:
+		return p.Info(), nil
+	case parser.CommandDBSize, parser.CommandPublish:
+		return p.fanOutSum(ctx, text)
+	case parser.CommandLock, parser.CommandUnlock, parser.CommandLockRenew:
+		return p.backends[p.ring.Backend(command.Key)].request(ctx, text)
+	}
+
+	keys := command.Keys()
+	if unsupportedCommands()[command.Type] || len(keys) == 0 {
+		return "", ErrUnsupported
+	}
+
+	groups := make(map[string][]string)
+	for _, key := range keys {
+		address := p.ring.Backend(key)
+		groups[address] = append(groups[address], key)
+	}
+
+	if len(groups) == 1 {
+		return p.backends[p.ring.Backend(keys[0])].request(ctx, text)
+	}
+
+	switch command.Type { //nolint: exhaustive // only set operations are merged
+	case parser.CommandSInter, parser.CommandSUnion:
+		return p.fanOutSets(ctx, command.Type, groups)
+	}
+
+	return "", ErrCrossBackend
+}
+
+// fanOutSets computes SINTER or SUNION of keys grouped by backend from the results
+// of the backends.
+func (p *Proxy) fanOutSets(ctx context.Context, operation parser.CommandType, groups map[string][]string) (string, error) {
+	var members map[string]struct{}
+
+	for _, address := range slices.Sorted(maps.Keys(groups)) {
+		response, err := p.backends[address].request(ctx, string(operation)+" "+strings.Join(groups[address], " "))
+		if err != nil || strings.HasPrefix(response, errorPrefix) {
+			return response, err
+		}
+
+		result := make(map[string]struct{})
+		for _, member := range strings.Fields(response) {
+			if _, ok := members[member]; members == nil || operation == parser.CommandSUnion || ok {
+				result[member] = struct{}{}
+			}
+		}
+
+		if operation == parser.CommandSUnion {
+			maps.Copy(result, members)
+		}
+
+		members = result
+	}
+
+	return strings.Join(slices.Sorted(maps.Keys(members)), " "), nil
+}
+
+// fanOutSum sends text to all backends and replies with the sum of their numeric responses.
+func (p *Proxy) fanOutSum(ctx context.Context, text string) (string, error) {
+	sum := 0
+
+	for _, address := range p.cfg.Backends {
+		response, err := p.backends[address].request(ctx, text)
+		if err != nil || strings.HasPrefix(response, errorPrefix) {
+			return response, err
+		}
+
+		count, err := strconv.Atoi(response)
+		if err != nil {
+			return "", fmt.Errorf("backend %s: unexpected response %q", address, response)
+		}
+
+		sum += count
+	}
+
+	return strconv.Itoa(sum), nil
+}
+
+// RunHealthChecks checks the backends every health interval until ctx is done,
+// commands of keys on backends marked down fail with ErrBackendDown.
+func (p *Proxy) RunHealthChecks(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.HealthInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, address := range p.cfg.Backends {
+			backend := p.backends[address]
+
+			if backend.check(ctx) {
+				p.logger.Info(
+					"backend health changed",
+					slog.String("backend", address),
+					slog.Bool("down", backend.down.Load()),
+				)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Info reports the backends and whether they are up.
+func (p *Proxy) Info() string {
+	fields := []string{"role proxy", "backends " + strconv.Itoa(len(p.cfg.Backends))}
+
+	for _, address := range p.cfg.Backends {
+		state := "up"
+		if p.backends[address].down.Load() {
+			state = "down"
+		}
+
+		fields = append(fields, "backend "+address+" "+state)
+	}
+
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,216 @@
+package proxy_test
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/compute"
+	"github.com/pingvincible/kvdatabase/internal/config"
+	"github.com/pingvincible/kvdatabase/internal/logger"
+	"github.com/pingvincible/kvdatabase/internal/proxy"
+	"github.com/pingvincible/kvdatabase/internal/storage/engine"
+	"github.com/pingvincible/kvdatabase/internal/tcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRing(t *testing.T) {
+	t.Parallel()
+
+	backends := []string{"a:1", "b:1", "c:1"}
+	ring := proxy.NewRing(backends)
+	moved, counts := 0, make(map[string]int)
+
+	grown := proxy.NewRing(append(backends, "d:1"))
+
+	for i := range 3000 {
+		key := "key" + strconv.Itoa(i)
+		backend := ring.Backend(key)
+		counts[backend]++
+
+		assert.Equal(t, backend, proxy.NewRing([]string{"c:1", "a:1", "b:1"}).Backend(key))
+
+		if added := grown.Backend(key); added != backend {
+			assert.Equal(t, "d:1", added)
+
+			moved++
+		}
+	}
+
+	for _, backend := range backends {
+		assert.Greater(t, counts[backend], 600, backend)
+	}
+
+	assert.Less(t, moved, 1200)
+	assert.Equal(t, ring.Backend("user1000"), ring.Backend("{user1000}.following"))
+	assert.Empty(t, proxy.NewRing(nil).Backend("key"))
+}
+
+func startBackend(t *testing.T) (*tcp.Server, string) {
+	t.Helper()
+
+	server, err := tcp.NewServer(config.NetworkConfig{
+		Address:        "",
+		MaxConnections: 10,
+		MaxMessageSize: "1KB",
+		IdleTimeout:    2 * time.Minute,
+	}, compute.NewComputer(engine.New()), logger.NewDiscardLogger())
+	require.NoError(t, err)
+
+	addr, err := server.Addr()
+	require.NoError(t, err)
+
+	go server.Run()
+
+	return server, addr
+}
+
+func TestProxy(t *testing.T) {
+	t.Parallel()
+
+	servers := make([]*tcp.Server, 2)
+	addrs := make([]string, 2)
+
+	for i := range servers {
+		servers[i], addrs[i] = startBackend(t)
+	}
+
+	defer func() { _ = servers[0].Stop() }()
+
+	_, err := proxy.New(proxy.Config{}, logger.NewDiscardLogger())
+	require.ErrorIs(t, err, proxy.ErrNoBackends)
+
+	_, err = proxy.New(proxy.Config{Backends: addrs, Timeout: time.Second}, logger.NewDiscardLogger())
+	require.ErrorIs(t, err, proxy.ErrInvalidConfig)
+
+	kvProxy, err := proxy.New(proxy.Config{
+		Backends:       addrs,
+		PoolSize:       2,
+		Timeout:        time.Second,
+		HealthInterval: 10 * time.Millisecond,
+	}, logger.NewDiscardLogger())
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = kvProxy.Serve(ctx, listener) }()
+
+	client, err := tcp.NewClient(listener.Addr().String())
+	require.NoError(t, err)
+
+	defer func() { _ = client.Close() }()
+
+	do := func(text string) string {
+		response, err := client.Do(text)
+		require.NoError(t, err)
+
+		return response
+	}
+
+	// find a key of each backend
+	ring := proxy.NewRing(addrs)
+	keys := make(map[string]string)
+
+	for i := 0; len(keys) < len(addrs); i++ {
+		key := "key" + strconv.Itoa(i)
+		if _, ok := keys[ring.Backend(key)]; !ok {
+			keys[ring.Backend(key)] = key
+		}
+	}
+
+	first, second := keys[addrs[0]], keys[addrs[1]]
+
+	assert.Equal(t, "rev 1", do("SET "+first+" 1"))
+	assert.Equal(t, "rev 1", do("SET "+second+" 2"))
+	assert.Equal(t, "1", do("GET "+first))
+	assert.Equal(t, "2", do("GET "+second))
+	assert.Equal(t, "2", do("DBSIZE"))
+
+	assert.Equal(t, "rev 2 2", do("SADD {"+first+"}.tags a b"))
+	assert.Equal(t, "rev 2 2", do("SADD {"+second+"}.tags b c"))
+	assert.Equal(t, "a b c", do("SUNION {"+first+"}.tags {"+second+"}.tags"))
+	assert.Equal(t, "b", do("SINTER {"+first+"}.tags {"+second+"}.tags"))
+	assert.Equal(t, "rev 3 1", do("SADD {"+first+"}.other b"))
+	assert.Equal(t, "b", do("SINTER {"+first+"}.tags {"+first+"}.other"))
+
+	// commands whose results cannot be merged from the backends are rejected
+	for _, command := range []string{
+		"PFCOUNT " + first + " " + second,
+		"PFMERGE " + first + " " + second,
+		"BITOP AND " + first + " " + first + " " + second,
+		"BLPOP " + first + " " + second + " 0",
+		"XREAD STREAMS " + first + " " + second + " 0 0",
+		"XREADGROUP GROUP group consumer STREAMS " + first + " " + second + " > >",
+	} {
+		assert.Equal(t, "error: "+proxy.ErrCrossBackend.Error(), do(command), command)
+	}
+
+	assert.Equal(t, "error: "+proxy.ErrUnsupported.Error(), do("SELECT users"))
+	assert.Equal(t, "role proxy backends 2 backend "+addrs[0]+" up backend "+addrs[1]+" up", do("INFO"))
+
+	go kvProxy.RunHealthChecks(ctx)
+
+	require.NoError(t, servers[1].Stop())
+
+	require.Eventually(t, func() bool {
+		return do("INFO") == "role proxy backends 2 backend "+addrs[0]+" up backend "+addrs[1]+" down"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "1", do("GET "+first))
+	assert.Contains(t, do("GET "+second), proxy.ErrBackendDown.Error())
+}
+
+func TestProxyDisconnectReleasesBlockedClient(t *testing.T) {
+	t.Parallel()
+
+	server, addr := startBackend(t)
+
+	defer func() { _ = server.Stop() }()
+
+	kvProxy, err := proxy.New(proxy.Config{
+		Backends:       []string{addr},
+		PoolSize:       2,
+		Timeout:        time.Second,
+		HealthInterval: time.Second,
+	}, logger.NewDiscardLogger())
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = kvProxy.Serve(ctx, listener) }()
+
+	blocked, err := tcp.NewClient(listener.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, blocked.ReadWriter.WriteLine("BLPOP jobs 0"))
+
+	require.Eventually(t, func() bool { return server.GetClients() == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, blocked.Close())
+
+	// the backend connection of the blocked command is closed
+	require.Eventually(t, func() bool { return server.GetClients() == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	client, err := tcp.NewClient(listener.Addr().String())
+	require.NoError(t, err)
+
+	defer func() { _ = client.Close() }()
+
+	response, err := client.Do("RPUSH jobs job")
+	require.NoError(t, err)
+	assert.Equal(t, "rev 1 1", response)
+
+	// the element is not popped for the disconnected client
+	response, err = client.Do("LPOP jobs")
+	require.NoError(t, err)
+	assert.Equal(t, "rev 2 job", response)
+}
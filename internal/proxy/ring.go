@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"cmp"
+	"hash/crc32"
+	"slices"
+	"strconv"
+
+	"github.com/pingvincible/kvdatabase/internal/slot"
+)
+
+// ringPoints is the number of points of each backend on the ring, more points spread
+// keys more evenly.
+const ringPoints = 160
+
+type point struct {
+	hash    uint32
+	backend string
+}
+
+// Ring maps keys to backends by consistent hashing: adding or removing a backend
+// only moves the keys of its points. Keys sharing a hash tag map to the same backend.
+type Ring struct {
+	points []point
+}
+
+func NewRing(backends []string) *Ring {
+	points := make([]point, 0, len(backends)*ringPoints)
+
+	for _, backend := range backends {
+		for i := range ringPoints {
+			points = append(points, point{hash: hash(backend + "#" + strconv.Itoa(i)), backend: backend})
+		}
+	}
+
+	slices.SortFunc(points, func(a, b point) int {
+		return cmp.Or(cmp.Compare(a.hash, b.hash), cmp.Compare(a.backend, b.backend))
+	})
+
+	return &Ring{points: points}
+}
+
+// Backend returns the backend of key, the first one clockwise from the hash of key.
+func (r *Ring) Backend(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+
+	keyHash := hash(slot.Tag(key))
+
+	i, _ := slices.BinarySearchFunc(r.points, keyHash, func(p point, target uint32) int {
+		return cmp.Compare(p.hash, target)
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+
+	return r.points[i].backend
+}
+
+func hash(text string) uint32 {
+	return crc32.ChecksumIEEE([]byte(text))
+}
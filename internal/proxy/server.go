@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+
+	"github.com/pingvincible/kvdatabase/internal/kvio"
+)
+
+// Serve accepts clients on listener and processes their commands until ctx is done
+// or listener is closed. It waits for the clients to disconnect before returning.
+func (p *Proxy) Serve(ctx context.Context, listener net.Listener) error {
+	stopClosing := context.AfterFunc(ctx, func() { _ = listener.Close() })
+	defer stopClosing()
+
+	var clients sync.WaitGroup
+	defer clients.Wait()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+
+		clients.Add(1)
+
+		go func() {
+			defer clients.Done()
+
+			p.handleClient(ctx, conn)
+		}()
+	}
+}
+
+func (p *Proxy) handleClient(ctx context.Context, conn net.Conn) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// closing the connection on shutdown unblocks the read below
+	stopClosing := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stopClosing()
+
+	defer func() {
+		err := conn.Close()
+		if err != nil && !errors.Is(err, net.ErrClosed) {
+			p.logger.Error(
+				"failed to close client connection",
+				slog.String("error", err.Error()),
+			)
+		}
+	}()
+
+	readWriter := kvio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+
+	// the client context is cancelled once the client disconnects, aborting its blocking commands
+	requests, done := make(chan string), make(chan struct{})
+	defer close(done)
+
+	go p.readRequests(readWriter, cancel, requests, done)
+
+	for request := range requests {
+		response, err := p.Process(ctx, request)
+		if err != nil {
+			p.logger.Error(
+				"failed to process client query",
+				slog.String("error", err.Error()),
+			)
+
+			response = fmt.Sprintf("error: %s", err)
+		}
+
+		err = readWriter.WriteLine(response)
+		if err != nil {
+			p.logger.Error(
+				"failed to send data to client",
+				slog.String("error", err.Error()),
+			)
+
+			return
+		}
+	}
+}
+
+// readRequests reads the lines of a client into requests while its previous command
+// is processed, so that a disconnecting client cancels its context even while blocked.
+// It stops once done is closed.
+func (p *Proxy) readRequests(
+	readWriter *kvio.ReadWriter, cancel context.CancelFunc, requests chan<- string, done <-chan struct{},
+) {
+	defer close(requests)
+	defer cancel()
+
+	for {
+		request, err := readWriter.ReadLine()
+		if err != nil {
+			p.logger.Debug(
+				"client disconnected",
+				slog.String("error", err.Error()),
+			)
+
+			return
+		}
+
+		select {
+		case requests <- request:
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package pubsub
+
+import (
+	"maps"
+	"path"
+	"slices"
+	"sync"
+)
+
+// Subscriber receives messages published to its channels and patterns. Messages are
+// buffered, a subscriber that does not keep up is dropped instead of blocking publishers.
+type Subscriber struct {
+	messages chan string
+	dropped  chan struct{}
+	dropOnce sync.Once
+
+	// guarded by the broker mutex
+	channels map[string]struct{}
+	patterns map[string]struct{}
+}
+
+func NewSubscriber(buffer int) *Subscriber {
+	return &Subscriber{
+		messages: make(chan string, buffer),
+		dropped:  make(chan struct{}),
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+	}
+}
+
+// Messages returns messages to be pushed to the subscriber.
+func (s *Subscriber) Messages() <-chan string {
+	return s.messages
+}
+
+// Dropped is closed when the subscriber was dropped for being too slow.
+func (s *Subscriber) Dropped() <-chan struct{} {
+	return s.dropped
+}
+
+// Send queues message without blocking and reports whether it was queued.
+// A subscriber with a full buffer is marked as dropped.
+func (s *Subscriber) Send(message string) bool {
+	select {
+	case <-s.dropped:
+		return false
+	default:
+	}
+
+	select {
+	case s.messages <- message:
+		return true
+	default:
+		s.dropOnce.Do(func() { close(s.dropped) })
+
+		return false
+	}
+}
+
+type Broker struct {
+	mutex    sync.RWMutex
+	channels map[string]map[*Subscriber]struct{}
+	patterns map[string]map[*Subscriber]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		channels: make(map[string]map[*Subscriber]struct{}),
+		patterns: make(map[string]map[*Subscriber]struct{}),
+	}
+}
+
+// Subscribe subscribes to channels and returns the number of subscriptions of subscriber.
+func (b *Broker) Subscribe(subscriber *Subscriber, channels []string) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, channel := range channels {
+		add(b.channels, channel, subscriber)
+		subscriber.channels[channel] = struct{}{}
+	}
+
+	return len(subscriber.channels) + len(subscriber.patterns)
+}
+
+// PSubscribe subscribes to channels matching glob patterns and returns
+// the number of subscriptions of subscriber.
+func (b *Broker) PSubscribe(subscriber *Subscriber, patterns []string) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, pattern := range patterns {
+		add(b.patterns, pattern, subscriber)
+		subscriber.patterns[pattern] = struct{}{}
+	}
+
+	return len(subscriber.channels) + len(subscriber.patterns)
+}
+
+// Unsubscribe unsubscribes from channels, or from all channels if none are given,
+// and returns the number of remaining subscriptions.
+func (b *Broker) Unsubscribe(subscriber *Subscriber, channels []string) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(channels) == 0 {
+		channels = slices.Collect(maps.Keys(subscriber.channels))
+	}
+
+	for _, channel := range channels {
+		remove(b.channels, channel, subscriber)
+		delete(subscriber.channels, channel)
+	}
+
+	return len(subscriber.channels) + len(subscriber.patterns)
+}
+
+// PUnsubscribe unsubscribes from patterns, or from all patterns if none are given,
+// and returns the number of remaining subscriptions.
+func (b *Broker) PUnsubscribe(subscriber *Subscriber, patterns []string) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(patterns) == 0 {
+		patterns = slices.Collect(maps.Keys(subscriber.patterns))
+	}
+
+	for _, pattern := range patterns {
+		remove(b.patterns, pattern, subscriber)
+		delete(subscriber.patterns, pattern)
+	}
+
+	return len(subscriber.channels) + len(subscriber.patterns)
+}
+
+// Subscriptions returns the number of channels and patterns subscriber listens to.
+func (b *Broker) Subscriptions(subscriber *Subscriber) int {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return len(subscriber.channels) + len(subscriber.patterns)
+}
+
+// Remove drops all subscriptions of subscriber.
+func (b *Broker) Remove(subscriber *Subscriber) {
+	b.Unsubscribe(subscriber, nil)
+	b.PUnsubscribe(subscriber, nil)
+}
+
+// Publish sends message to subscribers of channel and of patterns matching it and
+// returns the number of subscribers that received it. Slow subscribers are dropped.
+func (b *Broker) Publish(channel, message string) int {
+	b.mutex.RLock()
+
+	var dropped []*Subscriber
+
+	received := 0
+
+	for subscriber := range b.channels[channel] {
+		if subscriber.Send("message " + channel + " " + message) {
+			received++
+		} else {
+			dropped = append(dropped, subscriber)
+		}
+	}
+
+	for pattern, subscribers := range b.patterns {
+		if matched, _ := path.Match(pattern, channel); !matched {
+			continue
+		}
+
+		for subscriber := range subscribers {
+			if subscriber.Send("pmessage " + pattern + " " + channel + " " + message) {
+				received++
+			} else {
+				dropped = append(dropped, subscriber)
+			}
+		}
+	}
+
+	b.mutex.RUnlock()
+
+	for _, subscriber := range dropped {
+		b.Remove(subscriber)
+	}
+
+	return received
+}
+
+func add(index map[string]map[*Subscriber]struct{}, name string, subscriber *Subscriber) {
+	subscribers, ok := index[name]
+	if !ok {
+		subscribers = make(map[*Subscriber]struct{})
+		index[name] = subscribers
+	}
+
+	subscribers[subscriber] = struct{}{}
+}
+
+func remove(index map[string]map[*Subscriber]struct{}, name string, subscriber *Subscriber) {
+	delete(index[name], subscriber)
+
+	if len(index[name]) == 0 {
+		delete(index, name)
+	}
+}
@@ -0,0 +1,54 @@
+package pubsub_test
+
+import (
+	"testing"
+
+	"github.com/pingvincible/kvdatabase/internal/pubsub"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrokerPublish(t *testing.T) {
+	t.Parallel()
+
+	broker := pubsub.NewBroker()
+	news, all := pubsub.NewSubscriber(10), pubsub.NewSubscriber(10)
+
+	assert.Equal(t, 2, broker.Subscribe(news, []string{"news", "sport"}))
+	assert.Equal(t, 1, broker.PSubscribe(all, []string{"n*"}))
+
+	assert.Equal(t, 2, broker.Publish("news", "hello"))
+	assert.Equal(t, 1, broker.Publish("sport", "goal"))
+	assert.Equal(t, 0, broker.Publish("weather", "rain"))
+
+	assert.Equal(t, "message news hello", <-news.Messages())
+	assert.Equal(t, "message sport goal", <-news.Messages())
+	assert.Equal(t, "pmessage n* news hello", <-all.Messages())
+
+	assert.Equal(t, 1, broker.Unsubscribe(news, []string{"news"}))
+	assert.Equal(t, 1, broker.Publish("news", "again"))
+	assert.Equal(t, 0, broker.Unsubscribe(news, nil))
+	assert.Equal(t, 0, broker.PUnsubscribe(all, nil))
+	assert.Equal(t, 0, broker.Publish("news", "nobody"))
+}
+
+func TestBrokerDropsSlowSubscribers(t *testing.T) {
+	t.Parallel()
+
+	broker := pubsub.NewBroker()
+	slow := pubsub.NewSubscriber(2)
+
+	broker.Subscribe(slow, []string{"events"})
+
+	assert.Equal(t, 1, broker.Publish("events", "1"))
+	assert.Equal(t, 1, broker.Publish("events", "2"))
+	assert.Equal(t, 0, broker.Publish("events", "3"))
+
+	select {
+	case <-slow.Dropped():
+	default:
+		t.Fatal("slow subscriber was not dropped")
+	}
+
+	assert.Equal(t, 0, broker.Subscriptions(slow))
+	assert.Equal(t, 0, broker.Publish("events", "4"))
+}
@@ -0,0 +1,71 @@
+package raft
+
+// EntryType is the kind of change a log entry makes.
+type EntryType int
+
+const (
+	// EntryCommand is applied to the state machine.
+	EntryCommand EntryType = iota
+	// EntryMembers replaces the cluster members, it takes effect once appended to the log.
+	EntryMembers
+	// EntryNoop is appended by a new leader to commit entries of previous terms.
+	EntryNoop
+)
+
+// LogEntry is an entry of the replicated log.
+type LogEntry struct {
+	Index   int64
+	Term    int64
+	Type    EntryType
+	Data    []byte
+	Members []string
+}
+
+// Snapshot is the state machine state after applying the log up to Index,
+// it replaces those entries.
+type Snapshot struct {
+	Index   int64
+	Term    int64
+	Members []string
+	Data    []byte
+}
+
+// MessageType is the kind of a request nodes send each other.
+type MessageType int
+
+const (
+	// MessageVote asks for a vote in the election of Term.
+	MessageVote MessageType = iota
+	// MessageAppend replicates Entries following PrevLogIndex, an empty one is a heartbeat.
+	MessageAppend
+	// MessageSnapshot replaces the log of a follower lagging behind the leader log with Snapshot.
+	MessageSnapshot
+)
+
+// Message is a request a node sends to another node.
+type Message struct {
+	Type MessageType
+	From string
+	Term int64
+
+	// MessageVote
+	LastLogIndex int64
+	LastLogTerm  int64
+
+	// MessageAppend
+	PrevLogIndex int64
+	PrevLogTerm  int64
+	Entries      []LogEntry
+	LeaderCommit int64
+
+	// MessageSnapshot
+	Snapshot *Snapshot
+}
+
+// Reply answers a Message. Success reports a granted vote or an accepted append,
+// a rejected append carries LastIndex, the last index the follower may share with the leader.
+type Reply struct {
+	Term      int64
+	Success   bool
+	LastIndex int64
+}
@@ -0,0 +1,83 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrUnreachable = errors.New("node is unreachable")
+
+// Network is an in-process network of nodes for running a cluster in a single process,
+// e.g. in tests. Nodes can be disconnected to simulate failures and partitions.
+type Network struct {
+	mutex        sync.RWMutex
+	nodes        map[string]*Node
+	disconnected map[string]bool
+	latency      time.Duration
+}
+
+func NewNetwork(latency time.Duration) *Network {
+	return &Network{
+		nodes:        make(map[string]*Node),
+		disconnected: make(map[string]bool),
+		latency:      latency,
+	}
+}
+
+// Add makes node reachable by its id.
+func (n *Network) Add(node *Node) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.nodes[node.cfg.ID] = node
+}
+
+// Disconnect drops all messages sent to and from the node with id.
+func (n *Network) Disconnect(id string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.disconnected[id] = true
+}
+
+// Connect restores messages of the node with id.
+func (n *Network) Connect(id string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	delete(n.disconnected, id)
+}
+
+// Transport returns the transport of the node with id.
+func (n *Network) Transport(id string) Transport {
+	return networkTransport{network: n, from: id}
+}
+
+type networkTransport struct {
+	network *Network
+	from    string
+}
+
+func (t networkTransport) Send(ctx context.Context, to string, message Message) (Reply, error) {
+	network := t.network
+
+	select {
+	case <-ctx.Done():
+		return Reply{}, fmt.Errorf("failed to send message: %w", ctx.Err())
+	case <-time.After(network.latency):
+	}
+
+	network.mutex.RLock()
+	node, ok := network.nodes[to]
+	reachable := ok && !network.disconnected[t.from] && !network.disconnected[to]
+	network.mutex.RUnlock()
+
+	if !reachable {
+		return Reply{}, fmt.Errorf("%w: %s", ErrUnreachable, to)
+	}
+
+	return node.Handle(message), nil
+}
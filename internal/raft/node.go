@@ -0,0 +1,1049 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotLeader         = errors.New("NOTLEADER")
+	ErrNoLeader          = errors.New("NOLEADER no leader is elected")
+	ErrLeadershipLost    = errors.New("leadership was lost before the entry was applied, it may or may not be applied")
+	ErrMembershipPending = errors.New("a membership change is in progress")
+	ErrMember            = errors.New("invalid member")
+)
+
+// maxAppendEntries bounds the number of entries sent in a single append.
+const maxAppendEntries = 256
+
+type role int
+
+const (
+	follower role = iota
+	candidate
+	leader
+)
+
+func (r role) String() string {
+	switch r {
+	case candidate:
+		return "candidate"
+	case leader:
+		return "leader"
+	default:
+		return "follower"
+	}
+}
+
+// StateMachine is the state replicated by the log. Apply must be deterministic,
+// so that every node applying the same commands reaches the same state.
+type StateMachine interface {
+	Apply(command []byte) (string, error)
+	Snapshot() ([]byte, error)
+	Restore(data []byte) error
+}
+
+// Transport delivers messages to other nodes.
+type Transport interface {
+	Send(ctx context.Context, to string, message Message) (Reply, error)
+}
+
+type Config struct {
+	// ID is the address other nodes and clients reach the node at.
+	ID string
+	// Members are the initial cluster members, a node joining an existing cluster starts
+	// without members and waits for the leader to add it.
+	Members []string
+	// ElectionTimeout is the minimal time without a leader before a follower starts an election.
+	ElectionTimeout time.Duration
+	// HeartbeatInterval is the interval of appends a leader sends to keep its followers.
+	HeartbeatInterval time.Duration
+	// SnapshotEntries is the number of applied entries after which the log is replaced
+	// with a snapshot, zero never takes snapshots.
+	SnapshotEntries int64
+	// StatePath is the file the term, the vote and the log are persisted to. Empty keeps them
+	// in memory only, such a node must not rejoin the cluster under its ID after a restart.
+	StatePath string
+}
+
+type result struct {
+	value string
+	err   error
+}
+
+type waiter struct {
+	term int64
+	done chan result
+}
+
+// Node is a member of a Raft cluster. Writes are proposed to the leader, which appends
+// them to its log and replicates the log to the followers. Entries stored by a majority
+// of members are committed and applied to the state machine of every node in log order.
+// The term, the vote and the log are persisted before the node acts on them, a restarted
+// node restores its state machine from the persisted snapshot and catches up from the leader.
+type Node struct {
+	cfg          Config
+	state        *stateFile // nil keeps the state in memory only
+	stateMachine StateMachine
+	transport    Transport
+	logger       *slog.Logger
+
+	// applyMutex serializes changes of the state machine, it is taken before mutex
+	applyMutex sync.Mutex
+
+	mutex       sync.Mutex
+	ctx         context.Context //nolint: containedctx // lifetime of Run
+	role        role
+	term        int64
+	votedFor    string
+	leader      string
+	lastContact time.Time // the latest append from the leader
+	deadline    time.Time // the election starts if no leader is heard of until deadline
+
+	snapshot    Snapshot
+	entries     []LogEntry // entries after the snapshot
+	members     []string   // members of the latest membership entry of the log
+	configIndex int64      // index of the latest membership entry
+	commitIndex int64
+	lastApplied int64
+	committed   chan struct{} // wakes the applier when commitIndex grows
+
+	nextIndex   map[string]int64
+	matchIndex  map[string]int64
+	replicators map[string]replicator
+	waiters     map[int64]waiter
+}
+
+type replicator struct {
+	cancel context.CancelFunc
+	notify chan struct{}
+}
+
+// NewNode creates the node with the state persisted at cfg.StatePath, it restores
+// the state machine from the persisted snapshot.
+func NewNode(cfg Config, stateMachine StateMachine, transport Transport, logger *slog.Logger) (*Node, error) {
+	node := &Node{
+		cfg:          cfg,
+		stateMachine: stateMachine,
+		transport:    transport,
+		logger:       logger,
+		ctx:          context.Background(),
+		snapshot:     Snapshot{Members: slices.Clone(cfg.Members)},
+		members:      slices.Clone(cfg.Members),
+		committed:    make(chan struct{}, 1),
+		nextIndex:    make(map[string]int64),
+		matchIndex:   make(map[string]int64),
+		replicators:  make(map[string]replicator),
+		waiters:      make(map[int64]waiter),
+	}
+	node.resetDeadline()
+
+	if cfg.StatePath == "" {
+		return node, nil
+	}
+
+	err := node.restore(cfg.StatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+// restore loads the state persisted at path.
+func (n *Node) restore(path string) error {
+	file, state, err := openState(path)
+	if err != nil {
+		return err
+	}
+
+	n.state = file
+	n.term, n.votedFor = state.term, state.votedFor
+
+	if state.snapshot != nil {
+		n.snapshot, n.entries = *state.snapshot, state.entries
+		n.updateMembers()
+	}
+
+	if n.snapshot.Index > 0 {
+		err = n.stateMachine.Restore(n.snapshot.Data)
+		if err != nil {
+			return fmt.Errorf("failed to restore snapshot: %w", err)
+		}
+
+		n.lastApplied, n.commitIndex = n.snapshot.Index, n.snapshot.Index
+	}
+
+	// drops records torn by a crash and compacts the records appended since the last snapshot
+	return n.persistAll(n.snapshot, n.entries)
+}
+
+// persist appends the term, the vote and entries replacing the log from their first index on
+// to the persisted state. It must be called with mutex held, before acting on the change.
+func (n *Node) persist(entries ...LogEntry) error {
+	if n.state == nil {
+		return nil
+	}
+
+	return n.state.append(stateRecord{Term: n.term, VotedFor: n.votedFor, Entries: entries})
+}
+
+// persistAll replaces the persisted state with the term, the vote, snapshot and the entries
+// following it. It must be called with mutex held.
+func (n *Node) persistAll(snapshot Snapshot, entries []LogEntry) error {
+	if n.state == nil {
+		return nil
+	}
+
+	return n.state.rewrite(stateRecord{Term: n.term, VotedFor: n.votedFor, Snapshot: &snapshot, Entries: entries})
+}
+
+// Run runs elections, replication and applying of committed entries until ctx is done.
+func (n *Node) Run(ctx context.Context) {
+	n.mutex.Lock()
+	n.ctx = ctx
+	n.resetDeadline()
+	n.mutex.Unlock()
+
+	go n.applyCommitted(ctx)
+
+	ticker := time.NewTicker(n.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			n.mutex.Lock()
+			n.stopReplicators()
+			n.failWaiters()
+			n.mutex.Unlock()
+
+			return
+		case <-ticker.C:
+			n.mutex.Lock()
+			if n.role != leader && slices.Contains(n.members, n.cfg.ID) && time.Now().After(n.deadline) {
+				n.campaign()
+			}
+			n.mutex.Unlock()
+		}
+	}
+}
+
+// Propose appends command to the log and returns the result of applying it once it is committed.
+// Only the leader accepts proposals, other nodes fail with ErrNotLeader naming the leader.
+func (n *Node) Propose(ctx context.Context, command []byte) (string, error) {
+	return n.propose(ctx, LogEntry{Type: EntryCommand, Data: command})
+}
+
+// AddMember adds the node with id to the cluster, it receives the log from the leader.
+func (n *Node) AddMember(ctx context.Context, id string) error {
+	return n.changeMembers(ctx, id, true)
+}
+
+// RemoveMember removes the node with id from the cluster. A removed leader steps down
+// once the removal is committed.
+func (n *Node) RemoveMember(ctx context.Context, id string) error {
+	return n.changeMembers(ctx, id, false)
+}
+
+// changeMembers adds or removes a single member, so that any majority of the old members
+// overlaps any majority of the new ones.
+func (n *Node) changeMembers(ctx context.Context, id string, add bool) error {
+	if id == "" || strings.ContainsAny(id, " ,") {
+		return fmt.Errorf("%w: %q", ErrMember, id)
+	}
+
+	n.mutex.Lock()
+
+	if err := n.leaderError(); err != nil {
+		n.mutex.Unlock()
+
+		return err
+	}
+
+	// a new leader must commit an entry of its term before changing members
+	if term, _ := n.termAt(n.commitIndex); n.configIndex > n.commitIndex || term != n.term {
+		n.mutex.Unlock()
+
+		return ErrMembershipPending
+	}
+
+	members := slices.Clone(n.members)
+	if add == slices.Contains(members, id) {
+		n.mutex.Unlock()
+
+		return nil
+	}
+
+	if add {
+		members = append(members, id)
+		slices.Sort(members)
+	} else {
+		members = slices.DeleteFunc(members, func(member string) bool { return member == id })
+	}
+
+	index, done, err := n.appendWaiting(LogEntry{Type: EntryMembers, Members: members})
+	n.mutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = n.wait(ctx, index, done)
+
+	return err
+}
+
+func (n *Node) propose(ctx context.Context, entry LogEntry) (string, error) {
+	n.mutex.Lock()
+
+	if err := n.leaderError(); err != nil {
+		n.mutex.Unlock()
+
+		return "", err
+	}
+
+	index, done, err := n.appendWaiting(entry)
+	n.mutex.Unlock()
+
+	if err != nil {
+		return "", err
+	}
+
+	return n.wait(ctx, index, done)
+}
+
+// appendWaiting appends entry to the log of the leader and returns a channel receiving
+// the result of applying it. It must be called with mutex held.
+func (n *Node) appendWaiting(entry LogEntry) (int64, <-chan result, error) {
+	index, err := n.append(entry)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	done := make(chan result, 1)
+	n.waiters[index] = waiter{term: n.term, done: done}
+
+	n.advanceCommit()
+	n.notifyReplicators()
+
+	return index, done, nil
+}
+
+func (n *Node) wait(ctx context.Context, index int64, done <-chan result) (string, error) {
+	select {
+	case result := <-done:
+		return result.value, result.err
+	case <-ctx.Done():
+		n.mutex.Lock()
+		delete(n.waiters, index)
+		n.mutex.Unlock()
+
+		return "", fmt.Errorf("failed to wait for entry %d: %w", index, ctx.Err())
+	}
+}
+
+// leaderError returns why the node does not accept proposals. It must be called with mutex held.
+func (n *Node) leaderError() error {
+	switch {
+	case n.role == leader:
+		return nil
+	case n.leader == "":
+		return ErrNoLeader
+	default:
+		return fmt.Errorf("%w %s", ErrNotLeader, n.leader)
+	}
+}
+
+// Handle handles a message of another node.
+func (n *Node) Handle(message Message) Reply {
+	switch message.Type {
+	case MessageVote:
+		return n.handleVote(message)
+	case MessageAppend:
+		return n.handleAppend(message)
+	case MessageSnapshot:
+		return n.handleSnapshot(message)
+	}
+
+	return Reply{}
+}
+
+func (n *Node) handleVote(message Message) Reply {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	// members hearing from a leader ignore candidates, e.g. removed members unaware of their removal
+	if n.role == leader || (n.leader != "" && time.Since(n.lastContact) < n.cfg.ElectionTimeout) {
+		return Reply{Term: n.term}
+	}
+
+	if message.Term < n.term {
+		return Reply{Term: n.term}
+	}
+
+	if message.Term > n.term {
+		n.follow(message.Term, "")
+	}
+
+	upToDate := message.LastLogTerm > n.lastTerm() ||
+		(message.LastLogTerm == n.lastTerm() && message.LastLogIndex >= n.lastIndex())
+
+	if !upToDate || (n.votedFor != "" && n.votedFor != message.From) {
+		return Reply{Term: n.term}
+	}
+
+	n.votedFor = message.From
+	n.resetDeadline()
+
+	// the vote must survive a restart, so that the node does not vote twice in the term
+	err := n.persist()
+	if err != nil {
+		n.logger.Error("failed to persist vote", slog.String("error", err.Error()))
+
+		return Reply{Term: n.term}
+	}
+
+	return Reply{Term: n.term, Success: true}
+}
+
+func (n *Node) handleAppend(message Message) Reply {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if message.Term < n.term {
+		return Reply{Term: n.term}
+	}
+
+	term := n.term
+	n.follow(message.Term, message.From)
+
+	prevIndex, prevTerm, entries := message.PrevLogIndex, message.PrevLogTerm, message.Entries
+
+	// entries covered by the snapshot are committed, so they match the leader log
+	if prevIndex < n.snapshot.Index {
+		skip := min(n.snapshot.Index-prevIndex, int64(len(entries)))
+		entries = entries[skip:]
+		prevIndex += skip
+		prevTerm, _ = n.termAt(prevIndex)
+	}
+
+	if prevIndex > n.lastIndex() {
+		return Reply{Term: n.term, LastIndex: n.lastIndex()}
+	}
+
+	if term, _ := n.termAt(prevIndex); term != prevTerm {
+		return Reply{Term: n.term, LastIndex: prevIndex - 1}
+	}
+
+	// entries from the first one the log does not hold replace the rest of the log
+	for len(entries) > 0 {
+		if term, ok := n.termAt(entries[0].Index); !ok || term != entries[0].Term {
+			break
+		}
+
+		entries = entries[1:]
+	}
+
+	if len(entries) > 0 || n.term != term {
+		err := n.persist(entries...)
+		if err != nil {
+			n.logger.Error("failed to persist entries", slog.String("error", err.Error()))
+
+			return Reply{Term: n.term, LastIndex: prevIndex}
+		}
+	}
+
+	if len(entries) > 0 {
+		n.entries = append(n.entries[:min(entries[0].Index-n.snapshot.Index-1, int64(len(n.entries)))], entries...)
+	}
+
+	n.updateMembers()
+
+	lastNew := message.PrevLogIndex + int64(len(message.Entries))
+	if commit := min(message.LeaderCommit, lastNew); commit > n.commitIndex {
+		n.setCommitIndex(commit)
+	}
+
+	return Reply{Term: n.term, Success: true}
+}
+
+func (n *Node) handleSnapshot(message Message) Reply {
+	n.applyMutex.Lock()
+	defer n.applyMutex.Unlock()
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if message.Term < n.term || message.Snapshot == nil {
+		return Reply{Term: n.term}
+	}
+
+	n.follow(message.Term, message.From)
+
+	snapshot := *message.Snapshot
+	if snapshot.Index <= n.lastApplied {
+		return Reply{Term: n.term, Success: true}
+	}
+
+	err := n.stateMachine.Restore(snapshot.Data)
+	if err != nil {
+		n.logger.Error("failed to restore snapshot", slog.String("error", err.Error()))
+
+		return Reply{Term: n.term}
+	}
+
+	var entries []LogEntry
+	if term, ok := n.termAt(snapshot.Index); ok && term == snapshot.Term {
+		entries = slices.Clone(n.entries[snapshot.Index-n.snapshot.Index:])
+	}
+
+	err = n.persistAll(snapshot, entries)
+	if err != nil {
+		n.logger.Error("failed to persist snapshot", slog.String("error", err.Error()))
+
+		return Reply{Term: n.term}
+	}
+
+	n.entries = entries
+	n.snapshot = snapshot
+	n.lastApplied = snapshot.Index
+	n.commitIndex = max(n.commitIndex, snapshot.Index)
+	n.updateMembers()
+
+	return Reply{Term: n.term, Success: true}
+}
+
+// follow makes the node a follower of leader in term, an empty leader is not known yet.
+// It must be called with mutex held.
+func (n *Node) follow(term int64, leaderID string) {
+	if term > n.term {
+		n.term = term
+		n.votedFor = ""
+		n.leader = ""
+	}
+
+	if n.role == leader {
+		n.stopReplicators()
+		n.failWaiters()
+	}
+
+	n.role = follower
+
+	if leaderID != "" {
+		n.leader = leaderID
+		n.lastContact = time.Now()
+	}
+
+	n.resetDeadline()
+}
+
+// campaign starts an election in the next term. It must be called with mutex held.
+func (n *Node) campaign() {
+	n.term++
+	n.role = candidate
+	n.votedFor = n.cfg.ID
+	n.leader = ""
+	n.resetDeadline()
+
+	err := n.persist()
+	if err != nil {
+		n.logger.Error("failed to persist vote", slog.String("error", err.Error()))
+
+		return
+	}
+
+	n.logger.Info("starting election", slog.String("node", n.cfg.ID), slog.Int64("term", n.term))
+
+	votes := 1
+	if votes > len(n.members)/2 {
+		n.becomeLeader()
+
+		return
+	}
+
+	term := n.term
+	message := Message{
+		Type:         MessageVote,
+		From:         n.cfg.ID,
+		Term:         term,
+		LastLogIndex: n.lastIndex(),
+		LastLogTerm:  n.lastTerm(),
+	}
+
+	for _, peer := range n.members {
+		if peer == n.cfg.ID {
+			continue
+		}
+
+		go func() {
+			reply, err := n.send(peer, message)
+			if err != nil {
+				return
+			}
+
+			n.mutex.Lock()
+			defer n.mutex.Unlock()
+
+			if reply.Term > n.term {
+				n.follow(reply.Term, "")
+
+				return
+			}
+
+			if n.role != candidate || n.term != term || !reply.Success {
+				return
+			}
+
+			votes++
+			if votes > len(n.members)/2 {
+				n.becomeLeader()
+			}
+		}()
+	}
+}
+
+// becomeLeader makes the node the leader of the current term. It must be called with mutex held.
+func (n *Node) becomeLeader() {
+	n.logger.Info("elected leader", slog.String("node", n.cfg.ID), slog.Int64("term", n.term))
+
+	n.role = leader
+	n.leader = n.cfg.ID
+	clear(n.nextIndex)
+	clear(n.matchIndex)
+
+	_, err := n.append(LogEntry{Type: EntryNoop})
+	if err != nil {
+		n.logger.Error("failed to persist entry", slog.String("error", err.Error()))
+		n.follow(n.term, "")
+
+		return
+	}
+
+	n.syncReplicators()
+	n.advanceCommit()
+}
+
+// append appends entry to the log of the leader. It must be called with mutex held.
+func (n *Node) append(entry LogEntry) (int64, error) {
+	entry.Index = n.lastIndex() + 1
+	entry.Term = n.term
+
+	err := n.persist(entry)
+	if err != nil {
+		return 0, err
+	}
+
+	n.entries = append(n.entries, entry)
+
+	if entry.Type == EntryMembers {
+		n.updateMembers()
+		n.syncReplicators()
+	}
+
+	return entry.Index, nil
+}
+
+// advanceCommit commits entries of the current term stored by a majority of members.
+// It must be called with mutex held.
+func (n *Node) advanceCommit() {
+	for index := n.lastIndex(); index > n.commitIndex; index-- {
+		if term, _ := n.termAt(index); term != n.term {
+			return
+		}
+
+		stored := 0
+
+		for _, member := range n.members {
+			if member == n.cfg.ID || n.matchIndex[member] >= index {
+				stored++
+			}
+		}
+
+		if stored > len(n.members)/2 {
+			committedConfig := n.configIndex > n.commitIndex && n.configIndex <= index
+			n.setCommitIndex(index)
+
+			if committedConfig {
+				n.syncReplicators()
+			}
+
+			// followers learn the commit index from the next append
+			n.notifyReplicators()
+
+			return
+		}
+	}
+}
+
+func (n *Node) setCommitIndex(index int64) {
+	n.commitIndex = index
+
+	select {
+	case n.committed <- struct{}{}:
+	default:
+	}
+}
+
+// syncReplicators replicates the log to the latest and the committed members.
+// It must be called with mutex held.
+func (n *Node) syncReplicators() {
+	if n.role != leader {
+		return
+	}
+
+	peers := make(map[string]bool)
+	for _, member := range append(n.membersAt(n.commitIndex), n.members...) {
+		peers[member] = member != n.cfg.ID
+	}
+
+	for peer, current := range n.replicators {
+		if !peers[peer] {
+			current.cancel()
+			delete(n.replicators, peer)
+		}
+	}
+
+	for peer, replicate := range peers {
+		if _, ok := n.replicators[peer]; ok || !replicate {
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(n.ctx)
+		current := replicator{cancel: cancel, notify: make(chan struct{}, 1)}
+		n.replicators[peer] = current
+		n.nextIndex[peer] = n.lastIndex() + 1
+		n.matchIndex[peer] = 0
+
+		go n.replicate(ctx, peer, current.notify)
+	}
+}
+
+// stopReplicators must be called with mutex held.
+func (n *Node) stopReplicators() {
+	for peer, current := range n.replicators {
+		current.cancel()
+		delete(n.replicators, peer)
+	}
+}
+
+// notifyReplicators wakes replicators to send new entries. It must be called with mutex held.
+func (n *Node) notifyReplicators() {
+	for _, current := range n.replicators {
+		select {
+		case current.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// failWaiters fails proposals of a deposed leader. It must be called with mutex held.
+func (n *Node) failWaiters() {
+	for index, waiting := range n.waiters {
+		waiting.done <- result{err: ErrLeadershipLost}
+		delete(n.waiters, index)
+	}
+}
+
+// replicate sends the log to peer every heartbeat interval and on new entries until ctx is done.
+func (n *Node) replicate(ctx context.Context, peer string, notify <-chan struct{}) {
+	ticker := time.NewTicker(n.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		if n.replicateOnce(ctx, peer) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-notify:
+		case <-ticker.C:
+		}
+	}
+}
+
+// replicateOnce sends peer the entries it misses and reports whether more are left to send.
+func (n *Node) replicateOnce(ctx context.Context, peer string) bool {
+	n.mutex.Lock()
+
+	if n.role != leader || ctx.Err() != nil {
+		n.mutex.Unlock()
+
+		return false
+	}
+
+	term := n.term
+	message := n.replicationMessage(peer)
+	n.mutex.Unlock()
+
+	reply, err := n.send(peer, message)
+	if err != nil {
+		return false
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if reply.Term > n.term {
+		n.follow(reply.Term, "")
+
+		return false
+	}
+
+	if n.role != leader || n.term != term || ctx.Err() != nil {
+		return false
+	}
+
+	switch {
+	case !reply.Success && message.Type == MessageAppend:
+		n.nextIndex[peer] = max(1, min(message.PrevLogIndex, reply.LastIndex+1))
+	case !reply.Success:
+		return false
+	case message.Type == MessageSnapshot:
+		n.matchIndex[peer] = max(n.matchIndex[peer], message.Snapshot.Index)
+		n.nextIndex[peer] = n.matchIndex[peer] + 1
+	default:
+		n.matchIndex[peer] = max(n.matchIndex[peer], message.PrevLogIndex+int64(len(message.Entries)))
+		n.nextIndex[peer] = n.matchIndex[peer] + 1
+		n.advanceCommit()
+	}
+
+	return n.role == leader && n.nextIndex[peer] <= n.lastIndex()
+}
+
+// replicationMessage returns the append or the snapshot peer needs next.
+// It must be called with mutex held.
+func (n *Node) replicationMessage(peer string) Message {
+	next := n.nextIndex[peer]
+	if next <= n.snapshot.Index {
+		snapshot := n.snapshot
+
+		return Message{Type: MessageSnapshot, From: n.cfg.ID, Term: n.term, Snapshot: &snapshot}
+	}
+
+	prevTerm, _ := n.termAt(next - 1)
+	start := next - n.snapshot.Index - 1
+	end := min(int64(len(n.entries)), start+maxAppendEntries)
+
+	return Message{
+		Type:         MessageAppend,
+		From:         n.cfg.ID,
+		Term:         n.term,
+		PrevLogIndex: next - 1,
+		PrevLogTerm:  prevTerm,
+		Entries:      slices.Clone(n.entries[start:end]),
+		LeaderCommit: n.commitIndex,
+	}
+}
+
+func (n *Node) send(peer string, message Message) (Reply, error) {
+	n.mutex.Lock()
+	ctx := n.ctx
+	n.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, n.cfg.ElectionTimeout)
+	defer cancel()
+
+	reply, err := n.transport.Send(ctx, peer, message)
+	if err != nil {
+		return Reply{}, fmt.Errorf("failed to send message to %s: %w", peer, err)
+	}
+
+	return reply, nil
+}
+
+// applyCommitted applies committed entries to the state machine until ctx is done.
+func (n *Node) applyCommitted(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-n.committed:
+		}
+
+		for n.applyNext() {
+		}
+	}
+}
+
+// applyNext applies the next committed entry and reports whether one was applied.
+func (n *Node) applyNext() bool {
+	n.applyMutex.Lock()
+	defer n.applyMutex.Unlock()
+
+	n.mutex.Lock()
+	if n.lastApplied >= n.commitIndex {
+		n.mutex.Unlock()
+
+		return false
+	}
+
+	entry := n.entries[n.lastApplied-n.snapshot.Index]
+	n.mutex.Unlock()
+
+	var applied result
+	if entry.Type == EntryCommand {
+		applied.value, applied.err = n.stateMachine.Apply(entry.Data)
+	}
+
+	n.mutex.Lock()
+	n.lastApplied = entry.Index
+
+	if waiting, ok := n.waiters[entry.Index]; ok {
+		delete(n.waiters, entry.Index)
+
+		if waiting.term != entry.Term {
+			applied = result{err: ErrLeadershipLost}
+		}
+
+		waiting.done <- applied
+	}
+
+	// a leader removed from the cluster steps down once its removal is applied
+	if entry.Type == EntryMembers && entry.Index == n.configIndex && n.role == leader &&
+		!slices.Contains(n.members, n.cfg.ID) {
+		n.logger.Info("leader removed from cluster", slog.String("node", n.cfg.ID))
+		n.follow(n.term, "")
+	}
+
+	compact := n.cfg.SnapshotEntries > 0 && n.lastApplied-n.snapshot.Index >= n.cfg.SnapshotEntries
+	n.mutex.Unlock()
+
+	if compact {
+		n.takeSnapshot(entry.Index)
+	}
+
+	return true
+}
+
+// takeSnapshot replaces the log up to the applied index with a snapshot of the state machine.
+// It must be called with applyMutex held.
+func (n *Node) takeSnapshot(index int64) {
+	data, err := n.stateMachine.Snapshot()
+	if err != nil {
+		n.logger.Error("failed to take snapshot", slog.String("error", err.Error()))
+
+		return
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	term, _ := n.termAt(index)
+	snapshot := Snapshot{Index: index, Term: term, Members: n.membersAt(index), Data: data}
+	entries := slices.Clone(n.entries[index-n.snapshot.Index:])
+
+	err = n.persistAll(snapshot, entries)
+	if err != nil {
+		n.logger.Error("failed to persist snapshot", slog.String("error", err.Error()))
+
+		return
+	}
+
+	n.entries = entries
+	n.snapshot = snapshot
+}
+
+// resetDeadline must be called with mutex held.
+func (n *Node) resetDeadline() {
+	timeout := n.cfg.ElectionTimeout + rand.N(n.cfg.ElectionTimeout+1) //nolint: gosec // election jitter
+	n.deadline = time.Now().Add(timeout)
+}
+
+// lastIndex must be called with mutex held.
+func (n *Node) lastIndex() int64 {
+	return n.snapshot.Index + int64(len(n.entries))
+}
+
+// lastTerm must be called with mutex held.
+func (n *Node) lastTerm() int64 {
+	if len(n.entries) == 0 {
+		return n.snapshot.Term
+	}
+
+	return n.entries[len(n.entries)-1].Term
+}
+
+// termAt returns the term of the entry at index, it reports false if the entry
+// is not in the log. It must be called with mutex held.
+func (n *Node) termAt(index int64) (int64, bool) {
+	switch {
+	case index == n.snapshot.Index:
+		return n.snapshot.Term, true
+	case index < n.snapshot.Index || index > n.lastIndex():
+		return 0, false
+	default:
+		return n.entries[index-n.snapshot.Index-1].Term, true
+	}
+}
+
+// membersAt returns the members as of the entry at index. It must be called with mutex held.
+func (n *Node) membersAt(index int64) []string {
+	for i := min(index, n.lastIndex()) - n.snapshot.Index - 1; i >= 0; i-- {
+		if n.entries[i].Type == EntryMembers {
+			return n.entries[i].Members
+		}
+	}
+
+	return n.snapshot.Members
+}
+
+// updateMembers applies the latest membership entry of the log. It must be called with mutex held.
+func (n *Node) updateMembers() {
+	n.members = n.membersAt(n.lastIndex())
+	n.configIndex = n.snapshot.Index
+
+	for i := len(n.entries) - 1; i >= 0; i-- {
+		if n.entries[i].Type == EntryMembers {
+			n.configIndex = n.entries[i].Index
+
+			break
+		}
+	}
+}
+
+// Leader returns the id of the current leader, empty if it is not known.
+func (n *Node) Leader() string {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.leader
+}
+
+// Members returns the current cluster members.
+func (n *Node) Members() []string {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return slices.Clone(n.members)
+}
+
+// Info reports the role, the term, the leader, the members and the log indexes of the node.
+func (n *Node) Info() string {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	leaderID := n.leader
+	if leaderID == "" {
+		leaderID = "-"
+	}
+
+	return strings.Join([]string{
+		"role " + n.role.String(),
+		"term " + strconv.FormatInt(n.term, 10),
+		"leader " + leaderID,
+		"members " + strings.Join(n.members, ","),
+		"commit " + strconv.FormatInt(n.commitIndex, 10),
+		"applied " + strconv.FormatInt(n.lastApplied, 10),
+	}, " ")
+}
@@ -0,0 +1,324 @@
+package raft_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/logger"
+	"github.com/pingvincible/kvdatabase/internal/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	electionTimeout   = 100 * time.Millisecond
+	heartbeatInterval = 20 * time.Millisecond
+	waitFor           = 5 * time.Second
+	tick              = 5 * time.Millisecond
+)
+
+// journal is a state machine recording applied commands.
+type journal struct {
+	mutex    sync.Mutex
+	commands []string
+}
+
+func (j *journal) Apply(command []byte) (string, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.commands = append(j.commands, string(command))
+
+	return fmt.Sprintf("%d %s", len(j.commands), command), nil
+}
+
+func (j *journal) Snapshot() ([]byte, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return []byte(strings.Join(j.commands, ",")), nil
+}
+
+func (j *journal) Restore(data []byte) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.commands = strings.Split(string(data), ",")
+
+	return nil
+}
+
+func (j *journal) Commands() []string {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return append([]string(nil), j.commands...)
+}
+
+type cluster struct {
+	t        *testing.T
+	network  *raft.Network
+	nodes    map[string]*raft.Node
+	journals map[string]*journal
+	stops    map[string]context.CancelFunc
+	dir      string          // the nodes persist their state here
+	ctx      context.Context //nolint: containedctx // test lifetime
+}
+
+func newCluster(t *testing.T, ids []string, snapshotEntries int64) *cluster {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	c := &cluster{
+		t:        t,
+		network:  raft.NewNetwork(time.Millisecond),
+		nodes:    make(map[string]*raft.Node),
+		journals: make(map[string]*journal),
+		stops:    make(map[string]context.CancelFunc),
+		dir:      t.TempDir(),
+		ctx:      ctx,
+	}
+
+	for _, id := range ids {
+		c.start(id, ids, snapshotEntries)
+	}
+
+	return c
+}
+
+func (c *cluster) start(id string, members []string, snapshotEntries int64) *raft.Node {
+	state := &journal{}
+	node, err := raft.NewNode(raft.Config{
+		ID:                id,
+		Members:           members,
+		ElectionTimeout:   electionTimeout,
+		HeartbeatInterval: heartbeatInterval,
+		SnapshotEntries:   snapshotEntries,
+		StatePath:         filepath.Join(c.dir, id),
+	}, state, c.network.Transport(id), logger.NewDiscardLogger())
+	require.NoError(c.t, err)
+
+	ctx, stop := context.WithCancel(c.ctx)
+
+	c.network.Add(node)
+	c.nodes[id] = node
+	c.journals[id] = state
+	c.stops[id] = stop
+
+	go node.Run(ctx)
+
+	return node
+}
+
+// stop stops the node with id and disconnects it, it can be started again from its persisted state.
+func (c *cluster) stop(id string) {
+	c.network.Disconnect(id)
+	c.stops[id]()
+}
+
+// leader waits until the nodes with ids agree on a leader among them.
+func (c *cluster) leader(t *testing.T, ids ...string) string {
+	t.Helper()
+
+	var leader string
+
+	require.Eventually(t, func() bool {
+		leader = c.nodes[ids[0]].Leader()
+
+		for _, id := range ids {
+			if c.nodes[id].Leader() != leader {
+				return false
+			}
+		}
+
+		return leader != "" && strings.Contains(c.nodes[leader].Info(), "role leader")
+	}, waitFor, tick)
+
+	return leader
+}
+
+func (c *cluster) assertApplied(t *testing.T, want []string, ids ...string) {
+	t.Helper()
+
+	for _, id := range ids {
+		assert.Eventually(t, func() bool {
+			return strings.Join(c.journals[id].Commands(), ",") == strings.Join(want, ",")
+		}, waitFor, tick, "node %s applied %v", id, c.journals[id].Commands())
+	}
+}
+
+func TestNodeReplication(t *testing.T) {
+	t.Parallel()
+
+	ids := []string{"a", "b", "c"}
+	c := newCluster(t, ids, 0)
+	leader := c.leader(t, ids...)
+
+	result, err := c.nodes[leader].Propose(context.Background(), []byte("x"))
+	require.NoError(t, err)
+	assert.Equal(t, "1 x", result)
+
+	result, err = c.nodes[leader].Propose(context.Background(), []byte("y"))
+	require.NoError(t, err)
+	assert.Equal(t, "2 y", result)
+
+	c.assertApplied(t, []string{"x", "y"}, ids...)
+
+	for _, id := range ids {
+		if id == leader {
+			continue
+		}
+
+		_, err = c.nodes[id].Propose(context.Background(), []byte("z"))
+		require.ErrorIs(t, err, raft.ErrNotLeader)
+		assert.Contains(t, err.Error(), leader)
+	}
+}
+
+func TestNodeLeaderFailure(t *testing.T) {
+	t.Parallel()
+
+	ids := []string{"a", "b", "c"}
+	c := newCluster(t, ids, 0)
+	oldLeader := c.leader(t, ids...)
+
+	_, err := c.nodes[oldLeader].Propose(context.Background(), []byte("x"))
+	require.NoError(t, err)
+
+	c.network.Disconnect(oldLeader)
+
+	var rest []string
+
+	for _, id := range ids {
+		if id != oldLeader {
+			rest = append(rest, id)
+		}
+	}
+
+	// the isolated leader cannot commit
+	ctx, cancel := context.WithTimeout(context.Background(), 3*electionTimeout)
+	defer cancel()
+
+	_, err = c.nodes[oldLeader].Propose(ctx, []byte("lost"))
+	require.Error(t, err)
+
+	newLeader := c.leader(t, rest...)
+	assert.NotEqual(t, oldLeader, newLeader)
+
+	_, err = c.nodes[newLeader].Propose(context.Background(), []byte("y"))
+	require.NoError(t, err)
+
+	c.network.Connect(oldLeader)
+
+	c.leader(t, ids...)
+	c.assertApplied(t, []string{"x", "y"}, ids...)
+}
+
+func TestNodeSnapshot(t *testing.T) {
+	t.Parallel()
+
+	ids := []string{"a", "b", "c"}
+	c := newCluster(t, ids, 5)
+	leader := c.leader(t, ids...)
+
+	lagging := ids[0]
+	if lagging == leader {
+		lagging = ids[1]
+	}
+
+	c.network.Disconnect(lagging)
+
+	var want []string
+
+	for i := range 20 {
+		command := fmt.Sprintf("c%d", i)
+		want = append(want, command)
+
+		_, err := c.nodes[leader].Propose(context.Background(), []byte(command))
+		require.NoError(t, err)
+	}
+
+	c.network.Connect(lagging)
+
+	// the entries the lagging node misses were replaced with a snapshot
+	c.assertApplied(t, want, ids...)
+}
+
+func TestNodeMembership(t *testing.T) {
+	t.Parallel()
+
+	c := newCluster(t, []string{"a"}, 0)
+	assert.Equal(t, "a", c.leader(t, "a"))
+
+	_, err := c.nodes["a"].Propose(context.Background(), []byte("x"))
+	require.NoError(t, err)
+
+	for _, id := range []string{"b", "c"} {
+		c.start(id, nil, 0)
+
+		require.Eventually(t, func() bool {
+			return c.nodes["a"].AddMember(context.Background(), id) == nil
+		}, waitFor, tick)
+	}
+
+	assert.Equal(t, []string{"a", "b", "c"}, c.nodes["a"].Members())
+	c.assertApplied(t, []string{"x"}, "a", "b", "c")
+
+	_, err = c.nodes["a"].Propose(context.Background(), []byte("y"))
+	require.NoError(t, err)
+	c.assertApplied(t, []string{"x", "y"}, "a", "b", "c")
+
+	// the leader removes itself and the remaining members elect a new one
+	require.NoError(t, c.nodes["a"].RemoveMember(context.Background(), "a"))
+
+	leader := c.leader(t, "b", "c")
+	assert.Equal(t, []string{"b", "c"}, c.nodes[leader].Members())
+
+	_, err = c.nodes[leader].Propose(context.Background(), []byte("z"))
+	require.NoError(t, err)
+	c.assertApplied(t, []string{"x", "y", "z"}, "b", "c")
+}
+
+func TestNodeRestart(t *testing.T) {
+	t.Parallel()
+
+	ids := []string{"a", "b", "c"}
+	c := newCluster(t, ids, 1)
+	leader := c.leader(t, ids...)
+
+	for _, command := range []string{"x", "y", "z"} {
+		_, err := c.nodes[leader].Propose(context.Background(), []byte(command))
+		require.NoError(t, err)
+	}
+
+	c.assertApplied(t, []string{"x", "y", "z"}, ids...)
+
+	restarted := ids[0]
+	if restarted == leader {
+		restarted = ids[1]
+	}
+
+	term := strings.Fields(c.nodes[restarted].Info())[3]
+
+	c.stop(restarted)
+	// lets a snapshot taken while stopping finish
+	time.Sleep(10 * tick)
+
+	// the restarted node keeps its term and restores the applied entries from its snapshot
+	node := c.start(restarted, ids, 1)
+	assert.Contains(t, node.Info(), "term "+term)
+	assert.Equal(t, []string{"x", "y", "z"}, c.journals[restarted].Commands())
+
+	c.network.Connect(restarted)
+
+	_, err := c.nodes[c.leader(t, ids...)].Propose(context.Background(), []byte("w"))
+	require.NoError(t, err)
+	c.assertApplied(t, []string{"x", "y", "z", "w"}, ids...)
+}
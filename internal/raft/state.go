@@ -0,0 +1,147 @@
+package raft
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// stateRecord is a change of the persisted state of a node. Every record carries the term
+// and the vote, Entries replace the log from the index of the first one on. A record with
+// a Snapshot holds the whole state: the snapshot and the entries following it.
+type stateRecord struct {
+	Term     int64
+	VotedFor string
+	Snapshot *Snapshot
+	Entries  []LogEntry
+}
+
+// persistedState is the state a node must not lose over restarts: it must not vote twice
+// in a term and must not forget entries it acknowledged.
+type persistedState struct {
+	term     int64
+	votedFor string
+	snapshot *Snapshot
+	entries  []LogEntry
+}
+
+func (s *persistedState) apply(record stateRecord) {
+	s.term, s.votedFor = record.Term, record.VotedFor
+
+	if record.Snapshot != nil {
+		s.snapshot = record.Snapshot
+		s.entries = record.Entries
+
+		return
+	}
+
+	if len(record.Entries) == 0 {
+		return
+	}
+
+	kept := len(s.entries)
+	for kept > 0 && s.entries[kept-1].Index >= record.Entries[0].Index {
+		kept--
+	}
+
+	s.entries = append(s.entries[:kept], record.Entries...)
+}
+
+// stateFile persists the state of a node as json records. Changes are appended and synced
+// before the node acts on them, the whole state is rewritten when the log is replaced with
+// a snapshot.
+type stateFile struct {
+	path string
+	file *os.File
+}
+
+// openState reads the state persisted at path, an empty state if the file does not exist.
+// A record torn by a crash while it was appended is dropped, it was never acted on.
+// The state must be rewritten before records are appended.
+func openState(path string) (*stateFile, persistedState, error) {
+	var state persistedState
+
+	file, err := os.Open(path)
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, state, fmt.Errorf("failed to open raft state: %w", err)
+	default:
+		decoder := json.NewDecoder(file)
+
+		for {
+			var record stateRecord
+
+			err = decoder.Decode(&record)
+			if err != nil {
+				break
+			}
+
+			state.apply(record)
+		}
+
+		_ = file.Close()
+
+		var syntaxError *json.SyntaxError
+		if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.As(err, &syntaxError) {
+			return nil, state, fmt.Errorf("failed to read raft state: %w", err)
+		}
+	}
+
+	return &stateFile{path: path}, state, nil
+}
+
+// append adds record to the state and syncs it to disk.
+func (f *stateFile) append(record stateRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode raft state: %w", err)
+	}
+
+	_, err = f.file.Write(append(data, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to write raft state: %w", err)
+	}
+
+	err = f.file.Sync()
+	if err != nil {
+		return fmt.Errorf("failed to sync raft state: %w", err)
+	}
+
+	return nil
+}
+
+// rewrite replaces the state with record, which must hold a snapshot. The new state is
+// written aside and renamed over the old one, so a crash leaves either of them.
+func (f *stateFile) rewrite(record stateRecord) error {
+	temporary := f.path + ".tmp"
+
+	file, err := os.Create(temporary)
+	if err != nil {
+		return fmt.Errorf("failed to create raft state: %w", err)
+	}
+
+	rewritten := &stateFile{path: f.path, file: file}
+
+	err = rewritten.append(record)
+	if err == nil {
+		err = os.Rename(temporary, f.path)
+	}
+
+	if err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("failed to replace raft state: %w", err)
+	}
+
+	if f.file != nil {
+		_ = f.file.Close()
+	}
+
+	f.file = file
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package raft
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/pingvincible/kvdatabase/internal/kvio"
+)
+
+var ErrInvalidMessage = errors.New("invalid raft message")
+
+// messagePrefix starts lines carrying messages between nodes: RAFT <base64 gob message>,
+// the receiver replies with a line of the base64 gob reply.
+const messagePrefix = "RAFT"
+
+// TCPTransport sends messages to nodes over the line protocol, reusing a connection per node.
+type TCPTransport struct {
+	mutex sync.Mutex
+	conns map[string]*peerConn
+}
+
+type peerConn struct {
+	mutex      sync.Mutex
+	conn       net.Conn
+	readWriter *kvio.ReadWriter
+}
+
+func NewTCPTransport() *TCPTransport {
+	return &TCPTransport{conns: make(map[string]*peerConn)}
+}
+
+func (t *TCPTransport) Send(ctx context.Context, to string, message Message) (Reply, error) {
+	peer, err := t.conn(ctx, to)
+	if err != nil {
+		return Reply{}, err
+	}
+
+	peer.mutex.Lock()
+	defer peer.mutex.Unlock()
+
+	reply, err := peer.send(ctx, message)
+	if err != nil {
+		// the connection state is unknown after a failure, the next message reconnects
+		t.mutex.Lock()
+		if t.conns[to] == peer {
+			delete(t.conns, to)
+		}
+		t.mutex.Unlock()
+
+		_ = peer.conn.Close()
+
+		return Reply{}, err
+	}
+
+	return reply, nil
+}
+
+func (t *TCPTransport) conn(ctx context.Context, to string) (*peerConn, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if peer, ok := t.conns[to]; ok {
+		return peer, nil
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", to, err)
+	}
+
+	peer := &peerConn{conn: conn, readWriter: kvio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))}
+	t.conns[to] = peer
+
+	return peer, nil
+}
+
+func (p *peerConn) send(ctx context.Context, message Message) (Reply, error) {
+	// no deadline of ctx leaves the zero time, which clears the deadline
+	deadline, _ := ctx.Deadline()
+	_ = p.conn.SetDeadline(deadline)
+
+	line, err := encode(message)
+	if err != nil {
+		return Reply{}, err
+	}
+
+	err = p.readWriter.WriteLine(messagePrefix + " " + line)
+	if err != nil {
+		return Reply{}, fmt.Errorf("failed to send message: %w", err)
+	}
+
+	line, err = p.readWriter.ReadLine()
+	if err != nil {
+		return Reply{}, fmt.Errorf("failed to read reply: %w", err)
+	}
+
+	var reply Reply
+
+	err = decode(line, &reply)
+	if err != nil {
+		return Reply{}, err
+	}
+
+	return reply, nil
+}
+
+// Close closes connections to other nodes.
+func (t *TCPTransport) Close() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for to, peer := range t.conns {
+		_ = peer.conn.Close()
+		delete(t.conns, to)
+	}
+}
+
+// Accepts reports whether request is a message of another node.
+func (n *Node) Accepts(request string) bool {
+	return strings.HasPrefix(request, messagePrefix+" ")
+}
+
+// Serve handles messages another node sends over conn until it disconnects.
+func (n *Node) Serve(_ context.Context, _ net.Conn, readWriter *kvio.ReadWriter, request string) error {
+	for {
+		var message Message
+
+		err := decode(strings.TrimPrefix(request, messagePrefix+" "), &message)
+		if err != nil {
+			return err
+		}
+
+		line, err := encode(n.Handle(message))
+		if err != nil {
+			return err
+		}
+
+		err = readWriter.WriteLine(line)
+		if err != nil {
+			return fmt.Errorf("failed to send reply: %w", err)
+		}
+
+		request, err = readWriter.ReadLine()
+		if err != nil {
+			return fmt.Errorf("failed to read message: %w", err)
+		}
+	}
+}
+
+func encode(value any) (string, error) {
+	var buffer bytes.Buffer
+
+	err := gob.NewEncoder(&buffer).Encode(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	return base64.RawStdEncoding.EncodeToString(buffer.Bytes()), nil
+}
+
+func decode(line string, value any) error {
+	data, err := base64.RawStdEncoding.DecodeString(strings.TrimSpace(line))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+	}
+
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(value)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package replication
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidEntry = errors.New("invalid replication entry")
+
+const (
+	entryPrefix = "entry"
+	entryFields = 7
+
+	// entryOverhead approximates the memory taken by an entry besides its strings.
+	entryOverhead = 64
+
+	// emptyField stands for an empty key or value on the wire, base64 never contains it.
+	emptyField = "."
+)
+
+// Entry is a change of a single key made by the mutation with Revision and reported
+// to watchers as Op. The key is restored from Value, a dump of its new value, or deleted
+// if Value is empty. The flush op clears the whole namespace. Entries carry whole values,
+// so applying an entry again does no harm.
+type Entry struct {
+	Revision  int64
+	Time      time.Time
+	Namespace string
+	Op        string
+	Key       string
+	Value     []byte
+}
+
+// Size returns the approximate number of bytes the entry takes in memory.
+func (e Entry) Size() int {
+	return entryOverhead + len(e.Namespace) + len(e.Op) + len(e.Key) + len(e.Value)
+}
+
+// String renders the entry as a line: entry <revision> <unix ms> <namespace> <op> <key> <value>,
+// the key and the value are base64 encoded.
+func (e Entry) String() string {
+	return strings.Join([]string{
+		entryPrefix,
+		strconv.FormatInt(e.Revision, 10),
+		strconv.FormatInt(e.Time.UnixMilli(), 10),
+		e.Namespace,
+		e.Op,
+		encodeField([]byte(e.Key)),
+		encodeField(e.Value),
+	}, " ")
+}
+
+// ParseEntry parses a line rendered by Entry.String.
+func ParseEntry(line string) (Entry, error) {
+	fields := strings.Fields(line)
+	if len(fields) != entryFields || fields[0] != entryPrefix {
+		return Entry{}, fmt.Errorf("%w: %s", ErrInvalidEntry, line)
+	}
+
+	revision, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return Entry{}, fmt.Errorf("%w: revision %s", ErrInvalidEntry, fields[1])
+	}
+
+	ms, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return Entry{}, fmt.Errorf("%w: time %s", ErrInvalidEntry, fields[2])
+	}
+
+	key, err := decodeField(fields[5])
+	if err != nil {
+		return Entry{}, err
+	}
+
+	value, err := decodeField(fields[6])
+	if err != nil {
+		return Entry{}, err
+	}
+
+	return Entry{
+		Revision:  revision,
+		Time:      time.UnixMilli(ms),
+		Namespace: fields[3],
+		Op:        fields[4],
+		Key:       string(key),
+		Value:     value,
+	}, nil
+}
+
+func encodeField(data []byte) string {
+	if len(data) == 0 {
+		return emptyField
+	}
+
+	return base64.RawStdEncoding.EncodeToString(data)
+}
+
+func decodeField(field string) ([]byte, error) {
+	if field == emptyField {
+		return nil, nil
+	}
+
+	data, err := base64.RawStdEncoding.DecodeString(field)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEntry, err)
+	}
+
+	return data, nil
+}
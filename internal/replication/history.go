@@ -0,0 +1,87 @@
+package replication
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync"
+)
+
+// history identifies the changes a database went through by a replication ID and revisions.
+// Databases with the same ID and revision hold the same keys. A promoted replica starts
+// a new ID and remembers the previous one up to the revision it was promoted at,
+// so replicas of the former primary can continue from its backlog.
+type history struct {
+	mutex          sync.Mutex
+	id             string
+	previousID     string
+	previousOffset int64 // the latest revision shared with the history of previousID
+}
+
+func newHistory() *history {
+	return &history{id: newReplicationID()}
+}
+
+func newReplicationID() string {
+	id := make([]byte, 20) //nolint: mnd // 160 bits, as long as a sha1 hash
+	_, _ = rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
+
+func (h *history) ID() string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.id
+}
+
+// promote starts a new history after revision, e.g. when a replica becomes a primary.
+func (h *history) promote(revision int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.previousID, h.previousOffset = h.id, revision
+	h.id = newReplicationID()
+}
+
+// adopt takes over the history of a primary after loading its snapshot.
+func (h *history) adopt(id string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.id, h.previousID, h.previousOffset = id, "", 0
+}
+
+// follow takes over the history of a primary continuing the history of the database
+// from revision.
+func (h *history) follow(id string, revision int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.id != id {
+		h.previousID, h.previousOffset = h.id, revision
+		h.id = id
+	}
+}
+
+// continues reports whether a database at revision of the history with id holds a state
+// of this history, so that it can continue with later changes instead of a snapshot.
+func (h *history) continues(id string, revision int64) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return id == h.id || (id == h.previousID && revision <= h.previousOffset)
+}
+
+func (h *history) info() string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	info := "replid " + h.id
+	if h.previousID != "" {
+		info += " previous_replid " + h.previousID + " previous_offset " + strconv.FormatInt(h.previousOffset, 10)
+	}
+
+	return info
+}
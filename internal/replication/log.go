@@ -0,0 +1,105 @@
+package replication
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrBacklogExceeded = errors.New("revision is no longer in the replication backlog")
+
+// Log retains the latest replication entries for replicas streaming them.
+type Log struct {
+	mutex   sync.Mutex
+	entries []Entry
+	size    int // the total size of entries
+	limit   int
+	trimmed int64 // the latest revision no longer in entries
+	changed chan struct{}
+	streams int
+}
+
+// NewLog returns a log retaining the latest entries up to limit bytes.
+func NewLog(limit int) *Log {
+	return &Log{limit: limit, changed: make(chan struct{})}
+}
+
+// Append adds entries of a mutation and wakes readers waiting for them.
+func (l *Log) Append(entries ...Entry) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.entries = append(l.entries, entries...)
+
+	for _, entry := range entries {
+		l.size += entry.Size()
+	}
+
+	// entries of a revision are trimmed together
+	excess := 0
+	for excess < len(l.entries) &&
+		(l.size > l.limit || excess > 0 && l.entries[excess].Revision == l.entries[excess-1].Revision) {
+		l.size -= l.entries[excess].Size()
+		excess++
+	}
+
+	if excess > 0 {
+		l.trimmed = l.entries[excess-1].Revision
+		clear(l.entries[:excess])
+		l.entries = l.entries[excess:]
+	}
+
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// Stream registers a replica streaming the log until the returned function is called.
+func (l *Log) Stream() func() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.streams++
+
+	return func() {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+
+		l.streams--
+	}
+}
+
+// Streamed reports whether a replica streams the log. While nobody does, changes
+// need not be appended: the log is Reset and replicas connecting later get a snapshot.
+func (l *Log) Streamed() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.streams > 0
+}
+
+// Since returns entries of revisions after revision and a channel closed on the next
+// Append. It fails with ErrBacklogExceeded if some of those entries are no longer retained.
+func (l *Log) Since(revision int64) ([]Entry, <-chan struct{}, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if revision < l.trimmed {
+		return nil, nil, fmt.Errorf("%w: %d", ErrBacklogExceeded, revision)
+	}
+
+	start := len(l.entries)
+	for start > 0 && l.entries[start-1].Revision > revision {
+		start--
+	}
+
+	return append([]Entry(nil), l.entries[start:]...), l.changed, nil
+}
+
+// Reset drops all entries, e.g. after loading a snapshot taken at revision.
+func (l *Log) Reset(revision int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.entries, l.size = nil, 0
+	l.trimmed = revision
+}
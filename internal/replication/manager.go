@@ -0,0 +1,282 @@
+package replication
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/kvio"
+)
+
+var (
+	ErrReplicaOfSelf = errors.New("database can not replicate itself")
+	ErrRejected      = errors.New("replica rejected the primary")
+)
+
+// replicaOfCommand is the command re-pointing a replica to another primary.
+const replicaOfCommand = "REPLICAOF"
+
+// Database is the database replicated by a manager, either as a primary or a replica.
+type Database interface {
+	Source
+	Target
+	SetReadOnly(readOnly bool)
+}
+
+// Manager switches its database between the primary and the replica role, keeping
+// the history of the database so that it continues from its revision after a switch.
+type Manager struct {
+	database Database
+	address  string
+	interval time.Duration
+	logger   *slog.Logger
+	history  *history
+	primary  *Primary
+
+	mutex   sync.Mutex
+	replica *Replica
+	stop    context.CancelFunc
+	done    chan struct{}
+}
+
+// NewManager creates a manager of database reachable at address, the database starts
+// as a primary.
+func NewManager(database Database, address string, interval time.Duration, logger *slog.Logger) *Manager {
+	primary := NewPrimary(database, interval, logger)
+
+	return &Manager{
+		database: database,
+		address:  address,
+		interval: interval,
+		logger:   logger,
+		history:  primary.history,
+		primary:  primary,
+	}
+}
+
+// ReplicaOf makes the database a read only replica of the primary at address.
+// Replicas of the database are disconnected to sync the new history on reconnect.
+func (m *Manager) ReplicaOf(address string) error {
+	if m.isSelf(address) {
+		return ErrReplicaOfSelf
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.stopReplica()
+	m.database.SetReadOnly(true)
+	m.primary.disconnectReplicas()
+
+	replica := NewReplica(m.database, address, m.interval, m.logger)
+	replica.history = m.history
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		replica.Run(ctx)
+	}()
+
+	m.replica, m.stop, m.done = replica, stop, done
+
+	m.logger.Info("replicating primary", slog.String("primary", address))
+
+	return nil
+}
+
+// Promote makes a replica database a primary starting a new history and re-points
+// replicas to it. Replicas of the database are disconnected to sync the new history.
+func (m *Manager) Promote(ctx context.Context, replicas []string) error {
+	m.mutex.Lock()
+
+	if m.replica != nil {
+		m.stopReplica()
+		m.history.promote(m.database.Revision())
+		m.database.SetReadOnly(false)
+		m.primary.disconnectReplicas()
+
+		m.logger.Info("promoted to primary", slog.Int64("revision", m.database.Revision()))
+	}
+
+	m.mutex.Unlock()
+
+	var errs []error
+
+	for _, replica := range replicas {
+		err := m.repoint(ctx, replica)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to re-point replica %s: %w", replica, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// isSelf reports whether address reaches the database, e.g. localhost reaches a database
+// listening at 127.0.0.1 and any local address reaches one listening at all interfaces.
+func (m *Manager) isSelf(address string) bool {
+	if address == m.address {
+		return true
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+
+	selfHost, selfPort, err := net.SplitHostPort(m.address)
+	if err != nil || port != selfPort {
+		return false
+	}
+
+	ips := resolve(host)
+
+	selfIPs := resolve(selfHost)
+	if slices.ContainsFunc(selfIPs, net.IP.IsUnspecified) {
+		selfIPs = append(selfIPs, localIPs()...)
+	}
+
+	for _, ip := range ips {
+		if slices.ContainsFunc(selfIPs, ip.Equal) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolve returns the addresses of host, none if it can not be resolved.
+func resolve(host string) []net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}
+	}
+
+	if host == "" {
+		return []net.IP{net.IPv4zero, net.IPv6unspecified}
+	}
+
+	ips, _ := net.LookupIP(host)
+
+	return ips
+}
+
+// localIPs returns the addresses of the network interfaces of the host.
+func localIPs() []net.IP {
+	addrs, _ := net.InterfaceAddrs()
+
+	ips := make([]net.IP, 0, len(addrs))
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			ips = append(ips, ipNet.IP)
+		}
+	}
+
+	return ips
+}
+
+// stopReplica stops replication from the primary and waits for it to end.
+func (m *Manager) stopReplica() {
+	if m.replica == nil {
+		return
+	}
+
+	m.stop()
+	<-m.done
+
+	m.replica, m.stop, m.done = nil, nil, nil
+}
+
+// repoint sends REPLICAOF with the address of the database to the database at address.
+func (m *Manager) repoint(ctx context.Context, address string) error {
+	conn, err := (&net.Dialer{Timeout: m.interval}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	_ = conn.SetDeadline(time.Now().Add(m.interval))
+
+	readWriter := kvio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+
+	err = readWriter.WriteLine(replicaOfCommand + " " + m.address)
+	if err != nil {
+		return fmt.Errorf("failed to send command: %w", err)
+	}
+
+	response, err := readWriter.ReadLine()
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if message, ok := strings.CutPrefix(strings.TrimSpace(response), "error: "); ok {
+		return fmt.Errorf("%w: %s", ErrRejected, message)
+	}
+
+	return nil
+}
+
+// Accepts reports whether request is sent by a replica to start replication,
+// replicas serve replicas of their own.
+func (m *Manager) Accepts(request string) bool {
+	return m.primary.Accepts(request)
+}
+
+// Serve streams changes of the database to the replica connected over conn.
+func (m *Manager) Serve(ctx context.Context, conn net.Conn, readWriter *kvio.ReadWriter, request string) error {
+	return m.primary.Serve(ctx, conn, readWriter, request)
+}
+
+// WaitAcks waits until replicas replicas acknowledge revision, see Primary.WaitAcks.
+func (m *Manager) WaitAcks(ctx context.Context, revision int64, replicas int) int {
+	return m.primary.WaitAcks(ctx, revision, replicas)
+}
+
+// Lag returns the replication lag of a replica, see Replica.Lag.
+// A primary is never behind.
+func (m *Manager) Lag() (time.Duration, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.replica == nil {
+		return 0, true
+	}
+
+	return m.replica.Lag()
+}
+
+// PrimaryAddress returns the address of the primary a replica replicates,
+// it is empty for a primary.
+func (m *Manager) PrimaryAddress() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.replica == nil {
+		return ""
+	}
+
+	return m.replica.PrimaryAddress()
+}
+
+// Info reports the replication state of the database in its current role.
+func (m *Manager) Info() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.replica != nil {
+		return m.replica.Info()
+	}
+
+	return m.primary.Info()
+}
@@ -0,0 +1,293 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/kvio"
+)
+
+// Lines of the replication protocol. A replica sends SYNC <replication id> <revision>.
+// If the primary retains the changes after that revision of the history, it replies with
+// continue <replication id> <revision>, otherwise with snapshot <replication id> <revision>,
+// entries restoring all keys and synced <revision>. Then it streams entries of later
+// mutations and pings with its revision every sync interval. The replica acknowledges
+// applied revisions with ack <revision>.
+const (
+	syncRequest  = "SYNC"
+	continueLine = "continue"
+	snapshotLine = "snapshot"
+	syncedLine   = "synced"
+	pingLine     = "ping"
+	ackLine      = "ack"
+)
+
+// Source is the database replicated by the primary.
+type Source interface {
+	Snapshot() (int64, []Entry, error)
+	Log() *Log
+	Revision() int64
+}
+
+type replicaState struct {
+	addr       string
+	acked      atomic.Int64
+	disconnect context.CancelFunc
+}
+
+// Primary streams changes of its source to connected replicas.
+type Primary struct {
+	source   Source
+	history  *history
+	interval time.Duration
+	logger   *slog.Logger
+
+	mutex    sync.Mutex
+	replicas map[*replicaState]struct{}
+	acked    chan struct{} // closed when a replica acknowledges a revision
+}
+
+func NewPrimary(source Source, interval time.Duration, logger *slog.Logger) *Primary {
+	return &Primary{
+		source:   source,
+		history:  newHistory(),
+		interval: interval,
+		logger:   logger,
+		replicas: make(map[*replicaState]struct{}),
+		acked:    make(chan struct{}),
+	}
+}
+
+// Accepts reports whether request is sent by a replica to start replication.
+func (p *Primary) Accepts(request string) bool {
+	fields := strings.Fields(request)
+
+	return len(fields) > 0 && fields[0] == syncRequest
+}
+
+// Serve sends the replica connected over conn the changes it misses or a snapshot
+// and then streams changes until the replica disconnects or ctx is done.
+func (p *Primary) Serve(ctx context.Context, conn net.Conn, readWriter *kvio.ReadWriter, request string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	replica := &replicaState{addr: conn.RemoteAddr().String(), disconnect: cancel}
+
+	// changes are appended to the log before the replica is told where it continues from
+	defer p.source.Log().Stream()()
+
+	p.mutex.Lock()
+	p.replicas[replica] = struct{}{}
+	p.mutex.Unlock()
+
+	defer func() {
+		p.mutex.Lock()
+		delete(p.replicas, replica)
+		p.mutex.Unlock()
+	}()
+
+	go p.readAcks(readWriter, replica, cancel)
+
+	if revision, ok := p.continues(request); ok {
+		p.logger.Info("continuing replica", slog.String("replica", replica.addr), slog.Int64("revision", revision))
+
+		err := readWriter.WriteLine(strings.Join([]string{
+			continueLine, p.history.ID(), strconv.FormatInt(revision, 10),
+		}, " "))
+		if err != nil {
+			return fmt.Errorf("failed to continue replica: %w", err)
+		}
+
+		return p.stream(ctx, readWriter, revision)
+	}
+
+	revision, entries, err := p.source.Snapshot()
+	if err != nil {
+		return fmt.Errorf("failed to take snapshot: %w", err)
+	}
+
+	p.logger.Info("sending snapshot to replica", slog.String("replica", replica.addr), slog.Int64("revision", revision))
+
+	err = writeSnapshot(readWriter, p.history.ID(), revision, entries)
+	if err != nil {
+		return err
+	}
+
+	return p.stream(ctx, readWriter, revision)
+}
+
+// continues returns the revision a replica sending request continues from, it reports false
+// if the replica holds another history or the changes it misses are no longer retained.
+func (p *Primary) continues(request string) (int64, bool) {
+	fields := strings.Fields(request)
+	if len(fields) != 3 { //nolint: mnd // SYNC id revision
+		return 0, false
+	}
+
+	revision, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil || revision > p.source.Revision() || !p.history.continues(fields[1], revision) {
+		return 0, false
+	}
+
+	_, _, err = p.source.Log().Since(revision)
+
+	return revision, err == nil
+}
+
+// disconnectReplicas drops connections of all replicas.
+func (p *Primary) disconnectReplicas() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for replica := range p.replicas {
+		replica.disconnect()
+	}
+}
+
+func writeSnapshot(readWriter *kvio.ReadWriter, id string, revision int64, entries []Entry) error {
+	err := readWriter.WriteLine(strings.Join([]string{snapshotLine, id, strconv.FormatInt(revision, 10)}, " "))
+	if err != nil {
+		return fmt.Errorf("failed to send snapshot: %w", err)
+	}
+
+	for _, entry := range entries {
+		err = readWriter.WriteLine(entry.String())
+		if err != nil {
+			return fmt.Errorf("failed to send snapshot: %w", err)
+		}
+	}
+
+	err = readWriter.WriteLine(syncedLine + " " + strconv.FormatInt(revision, 10))
+	if err != nil {
+		return fmt.Errorf("failed to send snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// stream sends entries after revision as they are appended to the log.
+func (p *Primary) stream(ctx context.Context, readWriter *kvio.ReadWriter, revision int64) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		entries, changed, err := p.source.Log().Since(revision)
+		if err != nil {
+			return fmt.Errorf("failed to stream changes: %w", err)
+		}
+
+		for _, entry := range entries {
+			err = readWriter.WriteLine(entry.String())
+			if err != nil {
+				return fmt.Errorf("failed to stream changes: %w", err)
+			}
+
+			revision = entry.Revision
+		}
+
+		select {
+		case <-changed:
+		case <-ticker.C:
+			err = readWriter.WriteLine(strings.Join([]string{
+				pingLine,
+				strconv.FormatInt(revision, 10),
+				strconv.FormatInt(time.Now().UnixMilli(), 10),
+			}, " "))
+			if err != nil {
+				return fmt.Errorf("failed to ping replica: %w", err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (p *Primary) readAcks(readWriter *kvio.ReadWriter, replica *replicaState, disconnected context.CancelFunc) {
+	defer disconnected()
+
+	for {
+		line, err := readWriter.ReadLine()
+		if err != nil {
+			return
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != ackLine { //nolint: mnd // ack revision
+			p.logger.Error("unexpected line from replica", slog.String("line", line))
+
+			continue
+		}
+
+		revision, err := strconv.ParseInt(fields[1], 10, 64)
+		if err == nil {
+			p.mutex.Lock()
+			replica.acked.Store(revision)
+			close(p.acked)
+			p.acked = make(chan struct{})
+			p.mutex.Unlock()
+		}
+	}
+}
+
+// WaitAcks waits until at least replicas connected replicas acknowledge revision or ctx is done.
+// It returns the number of replicas that acknowledged revision.
+func (p *Primary) WaitAcks(ctx context.Context, revision int64, replicas int) int {
+	for {
+		p.mutex.Lock()
+		acked, changed := 0, p.acked
+
+		for replica := range p.replicas {
+			if replica.acked.Load() >= revision {
+				acked++
+			}
+		}
+		p.mutex.Unlock()
+
+		if acked >= replicas {
+			return acked
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return acked
+		}
+	}
+}
+
+// Info reports the revision of the primary and the revisions acknowledged by its replicas.
+func (p *Primary) Info() string {
+	revision := p.source.Revision()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	replicas := make([]string, 0, len(p.replicas))
+	for replica := range p.replicas {
+		acked := replica.acked.Load()
+		replicas = append(replicas, strings.Join([]string{
+			"replica", replica.addr,
+			"acked", strconv.FormatInt(acked, 10),
+			"lag_revisions", strconv.FormatInt(max(revision-acked, 0), 10),
+		}, " "))
+	}
+
+	slices.Sort(replicas)
+
+	info := []string{
+		"role primary",
+		"revision " + strconv.FormatInt(revision, 10),
+		p.history.info(),
+		"replicas " + strconv.Itoa(len(replicas)),
+	}
+
+	return strings.Join(append(info, replicas...), " ")
+}
@@ -0,0 +1,311 @@
+package replication
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/kvio"
+)
+
+var ErrUnexpectedLine = errors.New("unexpected replication line")
+
+// missedPings is the number of sync intervals without a line from the primary
+// after which the replica reconnects.
+const missedPings = 3
+
+// Target is the database a replica applies changes of the primary to.
+type Target interface {
+	Load(revision int64, entries []Entry) error
+	Apply(entry Entry) error
+	Revision() int64
+}
+
+// Replica keeps its target in sync with the primary at primaryAddress.
+type Replica struct {
+	target         Target
+	history        *history
+	primaryAddress string
+	interval       time.Duration
+	logger         *slog.Logger
+
+	connected atomic.Bool
+	applied   atomic.Int64 // the revision applied to the target
+	primary   atomic.Int64 // the latest revision of the primary known to the replica
+	caughtUp  atomic.Int64 // unix ms of the primary state the target is in sync with
+	contact   atomic.Int64 // unix ms of the latest line received from the primary
+	partial   atomic.Bool  // whether the last sync continued from the target revision
+}
+
+func NewReplica(target Target, primaryAddress string, interval time.Duration, logger *slog.Logger) *Replica {
+	return &Replica{
+		target:         target,
+		history:        newHistory(),
+		primaryAddress: primaryAddress,
+		interval:       interval,
+		logger:         logger,
+	}
+}
+
+// Run replicates the primary until ctx is done, reconnecting every sync interval
+// after the connection is lost.
+func (r *Replica) Run(ctx context.Context) {
+	for {
+		err := r.sync(ctx)
+		r.connected.Store(false)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		r.logger.Error(
+			"replication from primary stopped",
+			slog.String("primary", r.primaryAddress),
+			slog.String("error", err.Error()),
+		)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+func (r *Replica) sync(ctx context.Context) error {
+	conn, err := (&net.Dialer{Timeout: r.interval}).DialContext(ctx, "tcp", r.primaryAddress)
+	if err != nil {
+		return fmt.Errorf("failed to connect to primary: %w", err)
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	stopClosing := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stopClosing()
+
+	readWriter := kvio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+
+	err = readWriter.WriteLine(strings.Join([]string{
+		syncRequest, r.history.ID(), strconv.FormatInt(r.target.Revision(), 10),
+	}, " "))
+	if err != nil {
+		return fmt.Errorf("failed to request sync: %w", err)
+	}
+
+	read := func() (string, error) {
+		_ = conn.SetReadDeadline(time.Now().Add(missedPings * r.interval))
+
+		line, err := readWriter.ReadLine()
+		if err == nil {
+			r.contact.Store(time.Now().UnixMilli())
+		}
+
+		return line, err
+	}
+
+	err = r.start(read)
+	if err != nil {
+		return err
+	}
+
+	r.connected.Store(true)
+	r.logger.Info("replica is in sync with primary", slog.Int64("revision", r.applied.Load()))
+
+	for {
+		err = r.ack(readWriter)
+		if err != nil {
+			return err
+		}
+
+		line, err := read()
+		if err != nil {
+			return fmt.Errorf("failed to read from primary: %w", err)
+		}
+
+		err = r.handle(line)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// start handles the reply to SYNC: the primary either continues from the target revision
+// or sends a snapshot.
+func (r *Replica) start(read func() (string, error)) error {
+	line, err := read()
+	if err != nil {
+		return fmt.Errorf("failed to read sync reply: %w", err)
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) != 3 { //nolint: mnd // reply id revision
+		return fmt.Errorf("%w: %s", ErrUnexpectedLine, line)
+	}
+
+	revision, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrUnexpectedLine, line)
+	}
+
+	switch fields[0] {
+	case continueLine:
+		r.history.follow(fields[1], revision)
+		r.partial.Store(true)
+	case snapshotLine:
+		err = r.loadSnapshot(read, revision)
+		if err != nil {
+			return err
+		}
+
+		r.history.adopt(fields[1])
+		r.partial.Store(false)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnexpectedLine, line)
+	}
+
+	r.applied.Store(revision)
+	r.primary.Store(revision)
+	r.caughtUp.Store(time.Now().UnixMilli())
+
+	return nil
+}
+
+func (r *Replica) loadSnapshot(read func() (string, error), revision int64) error {
+	var entries []Entry
+
+	for {
+		line, err := read()
+		if err != nil {
+			return fmt.Errorf("failed to read snapshot: %w", err)
+		}
+
+		if strings.HasPrefix(line, syncedLine) {
+			break
+		}
+
+		entry, err := ParseEntry(line)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	err := r.target.Load(revision, entries)
+	if err != nil {
+		return fmt.Errorf("failed to load snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// handle applies an entry or records the state of the primary reported by a ping.
+func (r *Replica) handle(line string) error {
+	if strings.HasPrefix(line, pingLine) {
+		fields := strings.Fields(line)
+		if len(fields) != 3 { //nolint: mnd // ping revision time
+			return fmt.Errorf("%w: %s", ErrUnexpectedLine, line)
+		}
+
+		revision, errRevision := strconv.ParseInt(fields[1], 10, 64)
+		ms, errTime := strconv.ParseInt(fields[2], 10, 64)
+
+		if errRevision != nil || errTime != nil {
+			return fmt.Errorf("%w: %s", ErrUnexpectedLine, line)
+		}
+
+		r.primary.Store(max(r.primary.Load(), revision))
+
+		if r.applied.Load() >= revision {
+			r.caughtUp.Store(ms)
+		}
+
+		return nil
+	}
+
+	entry, err := ParseEntry(line)
+	if err != nil {
+		return err
+	}
+
+	err = r.target.Apply(entry)
+	if err != nil {
+		return fmt.Errorf("failed to apply entry: %w", err)
+	}
+
+	r.applied.Store(entry.Revision)
+	r.primary.Store(max(r.primary.Load(), entry.Revision))
+	r.caughtUp.Store(entry.Time.UnixMilli())
+
+	return nil
+}
+
+func (r *Replica) ack(readWriter *kvio.ReadWriter) error {
+	err := readWriter.WriteLine(ackLine + " " + strconv.FormatInt(r.applied.Load(), 10))
+	if err != nil {
+		return fmt.Errorf("failed to acknowledge revision: %w", err)
+	}
+
+	return nil
+}
+
+// PrimaryAddress returns the address of the replicated primary.
+func (r *Replica) PrimaryAddress() string {
+	return r.primaryAddress
+}
+
+// Lag returns how far the target is behind the primary: the time passed since the state
+// of the primary the target is in sync with. It reports false if the replica is not connected.
+//
+// The primary streams its changes as it makes them and pings every sync interval, so a target
+// that applied the latest known revision misses changes only once a ping is overdue: its lag
+// is the time passed since the latest contact with the primary beyond the sync interval.
+func (r *Replica) Lag() (time.Duration, bool) {
+	if !r.connected.Load() {
+		return 0, false
+	}
+
+	if r.applied.Load() >= r.primary.Load() {
+		return max(time.Since(time.UnixMilli(r.contact.Load()))-r.interval, 0), true
+	}
+
+	return max(time.Since(time.UnixMilli(r.caughtUp.Load())), 0), true
+}
+
+// Info reports the connection to the primary, the applied revision and the replication lag.
+func (r *Replica) Info() string {
+	applied, primary := r.applied.Load(), r.primary.Load()
+	lag, connected := r.Lag()
+
+	lastSync := "full"
+	if r.partial.Load() {
+		lastSync = "partial"
+	}
+
+	return strings.Join([]string{
+		"role replica",
+		"primary " + r.primaryAddress,
+		"connected " + formatBool(connected),
+		"revision " + strconv.FormatInt(applied, 10),
+		"primary_revision " + strconv.FormatInt(primary, 10),
+		"lag_revisions " + strconv.FormatInt(max(primary-applied, 0), 10),
+		"lag_ms " + strconv.FormatInt(lag.Milliseconds(), 10),
+		"last_sync " + lastSync,
+		r.history.info(),
+	}, " ")
+}
+
+func formatBool(value bool) string {
+	if value {
+		return "1"
+	}
+
+	return "0"
+}
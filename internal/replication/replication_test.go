@@ -0,0 +1,97 @@
+package replication_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/replication"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntry(t *testing.T) {
+	t.Parallel()
+
+	entry := replication.Entry{
+		Revision:  7,
+		Time:      time.UnixMilli(1700000000000),
+		Namespace: "0",
+		Op:        "set",
+		Key:       "user/1",
+		Value:     []byte{0, 1, 2, 255},
+	}
+
+	parsed, err := replication.ParseEntry(entry.String() + "\n")
+	require.NoError(t, err)
+	assert.Equal(t, entry.Revision, parsed.Revision)
+	assert.True(t, entry.Time.Equal(parsed.Time))
+	assert.Equal(t, entry.Namespace, parsed.Namespace)
+	assert.Equal(t, entry.Op, parsed.Op)
+	assert.Equal(t, entry.Key, parsed.Key)
+	assert.Equal(t, entry.Value, parsed.Value)
+
+	flush := replication.Entry{Revision: 8, Time: time.UnixMilli(0), Namespace: "0", Op: "flush"}
+
+	parsed, err = replication.ParseEntry(flush.String())
+	require.NoError(t, err)
+	assert.Empty(t, parsed.Key)
+	assert.Empty(t, parsed.Value)
+
+	_, err = replication.ParseEntry("entry 1 2 0 set")
+	require.ErrorIs(t, err, replication.ErrInvalidEntry)
+
+	_, err = replication.ParseEntry("entry x 2 0 set . .")
+	require.ErrorIs(t, err, replication.ErrInvalidEntry)
+}
+
+func TestLog(t *testing.T) {
+	t.Parallel()
+
+	// room for three entries of single letter keys
+	log := replication.NewLog(3 * replication.Entry{Key: "a"}.Size())
+
+	entries, changed, err := log.Since(0)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	log.Append(replication.Entry{Revision: 1, Key: "a"})
+
+	select {
+	case <-changed:
+	default:
+		t.Fatal("append did not wake readers")
+	}
+
+	log.Append(replication.Entry{Revision: 2, Key: "b"}, replication.Entry{Revision: 2, Key: "c"})
+
+	entries, _, err = log.Since(1)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	// revision 1 and both entries of revision 2 are trimmed together
+	log.Append(replication.Entry{Revision: 3, Key: "d"}, replication.Entry{Revision: 4, Key: "e"})
+
+	entries, _, err = log.Since(2)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	_, _, err = log.Since(1)
+	require.ErrorIs(t, err, replication.ErrBacklogExceeded)
+
+	log.Reset(10)
+
+	entries, _, err = log.Since(10)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	_, _, err = log.Since(9)
+	require.ErrorIs(t, err, replication.ErrBacklogExceeded)
+
+	assert.False(t, log.Streamed())
+
+	stop := log.Stream()
+	assert.True(t, log.Streamed())
+
+	stop()
+	assert.False(t, log.Streamed())
+}
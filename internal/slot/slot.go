@@ -0,0 +1,164 @@
+package slot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var ErrInvalidAssignment = errors.New("invalid slot assignment")
+
+// Count is the number of hash slots keys are partitioned into.
+const Count = 16384
+
+// Of returns the hash slot of key, keys sharing a hash tag are in the same slot.
+func Of(key string) int {
+	return int(crc16(Tag(key))) % Count
+}
+
+// Tag returns the part of key that is hashed: the part between the first { and
+// the following } if it is not empty, otherwise the whole key.
+func Tag(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+
+	return key
+}
+
+// crc16 is the CRC-16/XMODEM checksum of text.
+func crc16(text string) uint16 {
+	var crc uint16
+
+	for i := range len(text) {
+		crc ^= uint16(text[i]) << 8 //nolint: mnd // high byte
+
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
+
+// Range is a range of slots from From to To inclusive assigned to the node at Address.
+type Range struct {
+	From    int
+	To      int
+	Address string
+}
+
+func (r Range) String() string {
+	if r.From == r.To {
+		return strconv.Itoa(r.From) + "=" + r.Address
+	}
+
+	return strconv.Itoa(r.From) + "-" + strconv.Itoa(r.To) + "=" + r.Address
+}
+
+// ParseRange parses a range in the form from-to=address or slot=address.
+func ParseRange(text string) (Range, error) {
+	slots, address, ok := strings.Cut(text, "=")
+	if !ok || address == "" {
+		return Range{}, fmt.Errorf("%w: %s", ErrInvalidAssignment, text)
+	}
+
+	from, to, isRange := strings.Cut(slots, "-")
+	if !isRange {
+		to = from
+	}
+
+	first, errFrom := strconv.Atoi(from)
+	last, errTo := strconv.Atoi(to)
+
+	if errFrom != nil || errTo != nil || first < 0 || first > last || last >= Count {
+		return Range{}, fmt.Errorf("%w: %s", ErrInvalidAssignment, text)
+	}
+
+	return Range{From: first, To: last, Address: address}, nil
+}
+
+// Map assigns slots to the addresses of the nodes serving their keys.
+// It is safe for concurrent use.
+type Map struct {
+	mutex  sync.RWMutex
+	owners []string
+}
+
+func NewMap() *Map {
+	return &Map{owners: make([]string, Count)}
+}
+
+// ParseMap creates a map from ranges in the form accepted by ParseRange.
+func ParseMap(ranges []string) (*Map, error) {
+	slots := NewMap()
+
+	for _, text := range ranges {
+		assigned, err := ParseRange(text)
+		if err != nil {
+			return nil, err
+		}
+
+		slots.Assign(assigned)
+	}
+
+	return slots, nil
+}
+
+// Assign assigns the slots of assigned to its address.
+func (m *Map) Assign(assigned Range) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for slot := assigned.From; slot <= assigned.To; slot++ {
+		m.owners[slot] = assigned.Address
+	}
+}
+
+// Owner returns the address of the node serving slot, it is empty for unassigned slots.
+func (m *Map) Owner(slot int) string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.owners[slot]
+}
+
+// Ranges returns the assigned slots as ranges of consecutive slots of the same node.
+func (m *Map) Ranges() []Range {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var ranges []Range
+
+	for slot, owner := range m.owners {
+		switch {
+		case owner == "":
+		case len(ranges) > 0 && ranges[len(ranges)-1].To == slot-1 && ranges[len(ranges)-1].Address == owner:
+			ranges[len(ranges)-1].To = slot
+		default:
+			ranges = append(ranges, Range{From: slot, To: slot, Address: owner})
+		}
+	}
+
+	return ranges
+}
+
+// String returns the ranges of the map separated by spaces, ParseMap accepts its fields.
+func (m *Map) String() string {
+	ranges := m.Ranges()
+	fields := make([]string, 0, len(ranges))
+
+	for _, assigned := range ranges {
+		fields = append(fields, assigned.String())
+	}
+
+	return strings.Join(fields, " ")
+}
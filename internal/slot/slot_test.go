@@ -0,0 +1,47 @@
+package slot_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pingvincible/kvdatabase/internal/slot"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOf(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 12739, slot.Of("123456789"))
+	assert.Equal(t, 12182, slot.Of("foo"))
+	assert.Equal(t, 5061, slot.Of("bar"))
+	assert.Equal(t, slot.Of("user1000"), slot.Of("{user1000}.following"))
+	assert.Equal(t, slot.Of("{user1000}.followers"), slot.Of("{user1000}.following"))
+	assert.Equal(t, slot.Of("{}.following"), slot.Of("{}.following"))
+	assert.NotEqual(t, slot.Of("{}.following"), slot.Of("{}.followers"))
+}
+
+func TestMap(t *testing.T) {
+	t.Parallel()
+
+	slots, err := slot.ParseMap([]string{"0-8191=127.0.0.1:3223", "8192-16383=127.0.0.1:3224"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "127.0.0.1:3223", slots.Owner(0))
+	assert.Equal(t, "127.0.0.1:3224", slots.Owner(slot.Of("foo")))
+
+	slots.Assign(slot.Range{From: 100, To: 100, Address: "127.0.0.1:3225"})
+	assert.Equal(t, "0-99=127.0.0.1:3223 100=127.0.0.1:3225 101-8191=127.0.0.1:3223 8192-16383=127.0.0.1:3224",
+		slots.String())
+
+	parsed, err := slot.ParseMap(strings.Fields(slots.String()))
+	require.NoError(t, err)
+	assert.Equal(t, slots.Ranges(), parsed.Ranges())
+
+	for _, text := range []string{"0-10", "10-0=a", "0-16384=a", "x=a", "-1=a"} {
+		_, err = slot.ParseRange(text)
+		require.ErrorIs(t, err, slot.ErrInvalidAssignment, text)
+	}
+
+	assert.Empty(t, slot.NewMap().String())
+}
@@ -0,0 +1,268 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// MaxBitOffset limits bitmaps to 512MB like string values.
+const MaxBitOffset = 1<<32 - 1
+
+var (
+	ErrBitOffset = errors.New("bit offset is not an integer or out of range")
+	ErrBitValue  = errors.New("bit is not an integer or out of range")
+	ErrBitOp     = errors.New("invalid bit operation")
+)
+
+type BitOp string
+
+const (
+	BitOpAnd BitOp = "AND"
+	BitOpOr  BitOp = "OR"
+	BitOpXor BitOp = "XOR"
+	BitOpNot BitOp = "NOT"
+)
+
+// Bits are addressed from the most significant bit of the first byte of a string value.
+func bitMask(offset int) byte {
+	return 1 << (7 - offset%8) //nolint: mnd // bits in a byte
+}
+
+// bitmap reads the bytes of a string value in either form without copying it.
+type bitmap struct {
+	str  stringValue
+	data bytesValue
+}
+
+func lookupBitmap(storage map[string]value, key string) (bitmap, error) {
+	switch stored := storage[key].(type) {
+	case nil:
+		return bitmap{}, nil
+	case stringValue:
+		return bitmap{str: stored}, nil
+	case bytesValue:
+		return bitmap{data: stored}, nil
+	default:
+		return bitmap{}, ErrWrongType
+	}
+}
+
+func (b bitmap) len() int {
+	return len(b.str) + len(b.data)
+}
+
+func (b bitmap) at(index int) byte {
+	if b.data != nil {
+		return b.data[index]
+	}
+
+	return b.str[index]
+}
+
+// SetBit sets the bit at offset of the string at key, growing it with zero bytes
+// as needed, and returns the previous bit.
+func (e *Engine) SetBit(key string, offset int, bit int) (int, error) {
+	if offset < 0 || offset > MaxBitOffset {
+		return 0, ErrBitOffset
+	}
+
+	if bit != 0 && bit != 1 {
+		return 0, ErrBitValue
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var data bytesValue
+
+	switch stored := e.storage[key].(type) {
+	case nil:
+	case bytesValue:
+		data = stored
+	case stringValue:
+		// the string is copied once, later bits are set in place
+		data = bytesValue(stored)
+	default:
+		return 0, ErrWrongType
+	}
+
+	if index := offset / 8; index >= len(data) { //nolint: mnd // bits in a byte
+		data = append(data, make([]byte, index-len(data)+1)...)
+	}
+
+	previous := 0
+	if data[offset/8]&bitMask(offset) != 0 {
+		previous = 1
+	}
+
+	if bit == 1 {
+		data[offset/8] |= bitMask(offset)
+	} else {
+		data[offset/8] &^= bitMask(offset)
+	}
+
+	e.store(key, data)
+
+	return previous, nil
+}
+
+// GetBit returns the bit at offset of the string at key, bits past its end are zero.
+func (e *Engine) GetBit(key string, offset int) (int, error) {
+	if offset < 0 || offset > MaxBitOffset {
+		return 0, ErrBitOffset
+	}
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	str, err := lookupBitmap(e.storage, key)
+	if err != nil || offset/8 >= str.len() {
+		return 0, err
+	}
+
+	if str.at(offset/8)&bitMask(offset) != 0 {
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+// BitCount counts set bits in the bytes between start and end inclusive,
+// negative indexes count from the end of the string.
+func (e *Engine) BitCount(key string, start, end int) (int, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	str, err := lookupBitmap(e.storage, key)
+	if err != nil {
+		return 0, err
+	}
+
+	start, end, ok := normalizeRange(start, end, str.len())
+	if !ok {
+		return 0, nil
+	}
+
+	count := 0
+	for i := start; i <= end; i++ {
+		count += bits.OnesCount8(str.at(i))
+	}
+
+	return count, nil
+}
+
+// BitOp stores the result of op over the strings at keys into destination and
+// returns its length. Shorter strings are padded with zero bytes, NOT takes a single key.
+func (e *Engine) BitOp(op BitOp, destination string, keys []string) (int, error) {
+	if op == BitOpNot && len(keys) != 1 {
+		return 0, fmt.Errorf("%w: NOT takes a single key", ErrBitOp)
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	sources := make([]bitmap, 0, len(keys))
+	length := 0
+
+	for _, key := range keys {
+		str, err := lookupBitmap(e.storage, key)
+		if err != nil {
+			return 0, err
+		}
+
+		sources = append(sources, str)
+		length = max(length, str.len())
+	}
+
+	result := make(bytesValue, length)
+	for i := range sources[0].len() {
+		result[i] = sources[0].at(i)
+	}
+
+	for _, source := range sources[1:] {
+		for i := range result {
+			var sourceByte byte
+			if i < source.len() {
+				sourceByte = source.at(i)
+			}
+
+			switch op {
+			case BitOpAnd:
+				result[i] &= sourceByte
+			case BitOpOr:
+				result[i] |= sourceByte
+			case BitOpXor:
+				result[i] ^= sourceByte
+			case BitOpNot:
+			default:
+				return 0, fmt.Errorf("%w: %s", ErrBitOp, op)
+			}
+		}
+	}
+
+	switch op {
+	case BitOpNot:
+		for i := range result {
+			result[i] = ^result[i]
+		}
+	case BitOpAnd, BitOpOr, BitOpXor:
+	default:
+		return 0, fmt.Errorf("%w: %s", ErrBitOp, op)
+	}
+
+	if length == 0 {
+		e.remove(destination)
+	} else {
+		e.store(destination, result)
+	}
+
+	return length, nil
+}
+
+// BitPos returns the position of the first bit equal to bit in the bytes between start
+// and end inclusive, or -1 if there is none. Looking for a clear bit without an explicit
+// end treats the string as padded with zero bytes.
+func (e *Engine) BitPos(key string, bit int, start, end int, hasEnd bool) (int, error) {
+	if bit != 0 && bit != 1 {
+		return 0, ErrBitValue
+	}
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	str, err := lookupBitmap(e.storage, key)
+	if err != nil {
+		return 0, err
+	}
+
+	if str.len() == 0 {
+		if bit == 0 {
+			return 0, nil
+		}
+
+		return -1, nil
+	}
+
+	start, end, ok := normalizeRange(start, end, str.len())
+	if !ok {
+		return -1, nil
+	}
+
+	for i := start; i <= end; i++ {
+		current := str.at(i)
+		if bit == 0 {
+			current = ^current
+		}
+
+		if current != 0 {
+			return i*8 + bits.LeadingZeros8(current), nil //nolint: mnd // bits in a byte
+		}
+	}
+
+	if bit == 0 && !hasEnd {
+		return (end + 1) * 8, nil //nolint: mnd // bits in a byte
+	}
+
+	return -1, nil
+}
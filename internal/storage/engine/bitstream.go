@@ -0,0 +1,52 @@
+package engine
+
+// bitWriter appends bits to a byte slice, most significant bit first.
+type bitWriter struct {
+	data []byte
+	bits int
+}
+
+func (w *bitWriter) writeBit(bit bool) {
+	if w.bits%8 == 0 {
+		w.data = append(w.data, 0)
+	}
+
+	if bit {
+		w.data[len(w.data)-1] |= 1 << (7 - w.bits%8) //nolint: mnd // bits in a byte
+	}
+
+	w.bits++
+}
+
+// writeBits writes the count lowest bits of value.
+func (w *bitWriter) writeBits(value uint64, count int) {
+	for i := count - 1; i >= 0; i-- {
+		w.writeBit(value&(1<<i) != 0)
+	}
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) readBit() bool {
+	bit := r.data[r.pos/8]&(1<<(7-r.pos%8)) != 0 //nolint: mnd // bits in a byte
+	r.pos++
+
+	return bit
+}
+
+func (r *bitReader) readBits(count int) uint64 {
+	var value uint64
+
+	for range count {
+		value <<= 1
+
+		if r.readBit() {
+			value |= 1
+		}
+	}
+
+	return value
+}
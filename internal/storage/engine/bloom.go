@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	DefaultBloomCapacity  = 10000
+	DefaultBloomErrorRate = 0.01
+
+	// MaxBloomBits bounds the size of a bloom filter to 128 MiB.
+	MaxBloomBits = 1 << 30
+)
+
+var (
+	ErrInvalidBloomParams = errors.New("bloom filter capacity must be positive and error rate between 0 and 1")
+	ErrBloomExists        = errors.New("bloom filter already exists")
+	ErrBloomTooLarge      = errors.New("bloom filter for this capacity and error rate is too large")
+)
+
+// bloomValue is a bloom filter sized once on creation for the expected capacity and
+// false positive rate, so its memory does not grow with the number of added items.
+type bloomValue struct {
+	bits   []uint64
+	size   uint64
+	hashes uint64
+}
+
+func (*bloomValue) Type() ValueType {
+	return TypeBloom
+}
+
+func newBloom(capacity int, errorRate float64) (*bloomValue, error) {
+	if capacity <= 0 || !(errorRate > 0 && errorRate < 1) {
+		return nil, ErrInvalidBloomParams
+	}
+
+	size := math.Ceil(-float64(capacity) * math.Log(errorRate) / (math.Ln2 * math.Ln2))
+	if size > MaxBloomBits {
+		return nil, fmt.Errorf("%w: %.0f bits, at most %d are allowed", ErrBloomTooLarge, size, MaxBloomBits)
+	}
+	hashes := max(math.Round(size/float64(capacity)*math.Ln2), 1)
+
+	return &bloomValue{
+		bits:   make([]uint64, (uint64(size)+63)/64), //nolint: mnd // bits in a word
+		size:   uint64(size),
+		hashes: uint64(hashes),
+	}, nil
+}
+
+// positions derives the bit positions of item with double hashing.
+func (b *bloomValue) positions(item string) []uint64 {
+	hash := hash64(item)
+	first, second := hash&math.MaxUint32, hash>>32|1 //nolint: mnd // upper half
+
+	positions := make([]uint64, b.hashes)
+	for i := range positions {
+		positions[i] = (first + uint64(i)*second) % b.size //nolint: gosec // i is positive
+	}
+
+	return positions
+}
+
+func (b *bloomValue) add(item string) bool {
+	added := false
+
+	for _, position := range b.positions(item) {
+		word, mask := position/64, uint64(1)<<(position%64) //nolint: mnd // bits in a word
+		if b.bits[word]&mask == 0 {
+			b.bits[word] |= mask
+			added = true
+		}
+	}
+
+	return added
+}
+
+func (b *bloomValue) exists(item string) bool {
+	for _, position := range b.positions(item) {
+		if b.bits[position/64]&(uint64(1)<<(position%64)) == 0 { //nolint: mnd // bits in a word
+			return false
+		}
+	}
+
+	return true
+}
+
+// BFReserve creates an empty bloom filter at key for capacity items with the given false positive rate.
+func (e *Engine) BFReserve(key string, errorRate float64, capacity int) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if _, exists := e.storage[key]; exists {
+		return fmt.Errorf("%w: %s", ErrBloomExists, key)
+	}
+
+	bloom, err := newBloom(capacity, errorRate)
+	if err != nil {
+		return err
+	}
+
+	e.store(key, bloom)
+
+	return nil
+}
+
+// BFAdd adds item to the bloom filter at key, creating it with default parameters if needed.
+// It returns false if the item may have been added before.
+func (e *Engine) BFAdd(key, item string) (bool, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	bloom, ok, err := lookup[*bloomValue](e.storage, key)
+	if err != nil {
+		return false, err
+	}
+
+	if !ok {
+		if bloom, err = newBloom(DefaultBloomCapacity, DefaultBloomErrorRate); err != nil {
+			return false, err
+		}
+
+		e.store(key, bloom)
+	}
+
+	return bloom.add(item), nil
+}
+
+// BFExists reports whether item may have been added to the bloom filter at key.
+func (e *Engine) BFExists(key, item string) (bool, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	bloom, ok, err := lookup[*bloomValue](e.storage, key)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	return bloom.exists(item), nil
+}
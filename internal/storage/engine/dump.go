@@ -0,0 +1,383 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+var ErrInvalidDump = errors.New("invalid dump")
+
+// dumped is the serialized form of a value, only the fields of its Type are set.
+type dumped struct {
+	Type ValueType
+
+	String     string
+	Hash       map[string]string
+	List       []string
+	Members    []string
+	ZMembers   []ZMember
+	Document   []byte
+	Stream     *dumpedStream
+	Registers  []byte
+	Bloom      *dumpedBloom
+	TimeSeries *dumpedTimeSeries
+	Queue      *dumpedQueue
+	RateLimit  *dumpedRateLimit
+}
+
+type dumpedGroup struct {
+	LastDelivered StreamID
+	Pending       []PendingEntry
+}
+
+type dumpedStream struct {
+	Entries []StreamEntry
+	LastID  StreamID
+	Groups  map[string]dumpedGroup
+}
+
+type dumpedBloom struct {
+	Bits   []uint64
+	Size   uint64
+	Hashes uint64
+}
+
+type dumpedChunk struct {
+	Data      []byte
+	Bits      int
+	Count     int
+	First     int64
+	Last      int64
+	LastValue float64
+	LastDelta int64
+	Leading   int
+	Trailing  int
+}
+
+type dumpedTimeSeries struct {
+	Retention time.Duration
+	Chunks    []dumpedChunk
+}
+
+type dumpedMessage struct {
+	Seq        uint64
+	Body       string
+	Deliveries int
+	VisibleAt  time.Time
+}
+
+type dumpedQueue struct {
+	LastSeq       uint64
+	Ready         []dumpedMessage
+	Inflight      []dumpedMessage
+	MaxDeliveries int
+	DeadLetter    string
+}
+
+type dumpedRateLimit struct {
+	Tokens   float64
+	Time     time.Time
+	Previous float64
+	Current  float64
+	IdleAt   time.Time
+}
+
+// Dump serializes the value at key, so that Restore can recreate it on another database.
+// It reports false if the key does not exist.
+func (e *Engine) Dump(key string) ([]byte, bool, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	stored, ok := e.storage[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	data, err := dump(stored)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return data, true, nil
+}
+
+// Restore replaces the value at key with a value serialized by Dump.
+func (e *Engine) Restore(key string, data []byte) error {
+	restored, err := restore(data)
+	if err != nil {
+		return err
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.store(key, restored)
+	e.notifyWaiters(key)
+
+	return nil
+}
+
+// Keys returns the sorted keys of the namespace.
+func (e *Engine) Keys() []string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return slices.Sorted(maps.Keys(e.storage))
+}
+
+func dump(stored value) ([]byte, error) {
+	d := dumped{Type: stored.Type()}
+
+	switch v := stored.(type) {
+	case stringValue:
+		d.String = string(v)
+	case bytesValue:
+		d.String = string(v)
+	case hashValue:
+		d.Hash = v
+	case *listValue:
+		d.List = v.elements()
+	case setValue:
+		d.Members = slices.Collect(maps.Keys(v))
+	case *zsetValue:
+		d.ZMembers = v.sorted
+	case *jsonValue:
+		document, err := json.Marshal(v.document)
+		if err != nil {
+			return nil, fmt.Errorf("failed to dump json: %w", err)
+		}
+
+		d.Document = document
+	case *streamValue:
+		d.Stream = dumpStream(v)
+	case *hllValue:
+		d.Registers = v.registers[:]
+	case *bloomValue:
+		d.Bloom = &dumpedBloom{Bits: v.bits, Size: v.size, Hashes: v.hashes}
+	case *timeSeriesValue:
+		d.TimeSeries = dumpTimeSeries(v)
+	case *queueValue:
+		d.Queue = dumpQueue(v)
+	case *tokenBucketValue:
+		d.RateLimit = &dumpedRateLimit{Tokens: v.tokens, Time: v.refilled, IdleAt: v.fullAt}
+	case *slidingWindowValue:
+		d.RateLimit = &dumpedRateLimit{Time: v.start, Previous: v.previous, Current: v.current, IdleAt: v.emptyAt}
+	default:
+		return nil, fmt.Errorf("%w: unsupported type %s", ErrInvalidDump, stored.Type())
+	}
+
+	var buffer bytes.Buffer
+
+	err := gob.NewEncoder(&buffer).Encode(d)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump value: %w", err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func restore(data []byte) (value, error) { //nolint: cyclop // a case per type
+	var d dumped
+
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&d)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDump, err)
+	}
+
+	switch d.Type {
+	case TypeString:
+		return stringValue(d.String), nil
+	case TypeHash:
+		return hashValue(orEmpty(d.Hash)), nil
+	case TypeList:
+		return newList(d.List), nil
+	case TypeSet:
+		set := make(setValue, len(d.Members))
+		for _, member := range d.Members {
+			set[member] = struct{}{}
+		}
+
+		return set, nil
+	case TypeZSet:
+		zset := &zsetValue{scores: make(map[string]float64, len(d.ZMembers)), sorted: d.ZMembers}
+		for _, member := range d.ZMembers {
+			zset.scores[member.Member] = member.Score
+		}
+
+		return zset, nil
+	case TypeJSON:
+		var document any
+
+		err = json.Unmarshal(d.Document, &document)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidDump, err)
+		}
+
+		return &jsonValue{document: document}, nil
+	case TypeHyperLogLog:
+		hll := &hllValue{}
+		if copy(hll.registers[:], d.Registers) != hllRegisters {
+			return nil, fmt.Errorf("%w: hyperloglog registers", ErrInvalidDump)
+		}
+
+		return hll, nil
+	default:
+		return restoreStructured(d)
+	}
+}
+
+func restoreStructured(d dumped) (value, error) {
+	switch {
+	case d.Type == TypeStream && d.Stream != nil:
+		return restoreStream(d.Stream), nil
+	case d.Type == TypeBloom && d.Bloom != nil:
+		return &bloomValue{bits: d.Bloom.Bits, size: d.Bloom.Size, hashes: d.Bloom.Hashes}, nil
+	case d.Type == TypeTimeSeries && d.TimeSeries != nil:
+		return restoreTimeSeries(d.TimeSeries), nil
+	case d.Type == TypeQueue && d.Queue != nil:
+		return restoreQueue(d.Queue), nil
+	case d.Type == TypeTokenBucket && d.RateLimit != nil:
+		return &tokenBucketValue{tokens: d.RateLimit.Tokens, refilled: d.RateLimit.Time, fullAt: d.RateLimit.IdleAt}, nil
+	case d.Type == TypeSlidingWindow && d.RateLimit != nil:
+		return &slidingWindowValue{
+			start:    d.RateLimit.Time,
+			previous: d.RateLimit.Previous,
+			current:  d.RateLimit.Current,
+			emptyAt:  d.RateLimit.IdleAt,
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported type %s", ErrInvalidDump, d.Type)
+	}
+}
+
+func orEmpty[K comparable, V any](m map[K]V) map[K]V {
+	if m == nil {
+		return make(map[K]V)
+	}
+
+	return m
+}
+
+func dumpStream(stream *streamValue) *dumpedStream {
+	d := &dumpedStream{Entries: stream.entries, LastID: stream.lastID, Groups: make(map[string]dumpedGroup)}
+
+	for name, group := range stream.groups {
+		pending := make([]PendingEntry, 0, len(group.pending))
+		for _, entry := range group.pending {
+			pending = append(pending, *entry)
+		}
+
+		d.Groups[name] = dumpedGroup{LastDelivered: group.lastDelivered, Pending: pending}
+	}
+
+	return d
+}
+
+func restoreStream(d *dumpedStream) *streamValue {
+	stream := newStream()
+	stream.entries, stream.lastID = d.Entries, d.LastID
+
+	for name, dumpedGroup := range d.Groups {
+		group := &consumerGroup{lastDelivered: dumpedGroup.LastDelivered, pending: make(map[StreamID]*PendingEntry)}
+		for _, entry := range dumpedGroup.Pending {
+			group.pending[entry.ID] = &entry
+		}
+
+		stream.groups[name] = group
+	}
+
+	return stream
+}
+
+func dumpTimeSeries(series *timeSeriesValue) *dumpedTimeSeries {
+	d := &dumpedTimeSeries{Retention: series.retention, Chunks: make([]dumpedChunk, 0, len(series.chunks))}
+
+	for _, chunk := range series.chunks {
+		d.Chunks = append(d.Chunks, dumpedChunk{
+			Data:      chunk.stream.data,
+			Bits:      chunk.stream.bits,
+			Count:     chunk.count,
+			First:     chunk.first,
+			Last:      chunk.last,
+			LastValue: chunk.lastValue,
+			LastDelta: chunk.lastDelta,
+			Leading:   chunk.leading,
+			Trailing:  chunk.trailing,
+		})
+	}
+
+	return d
+}
+
+func restoreTimeSeries(d *dumpedTimeSeries) *timeSeriesValue {
+	series := &timeSeriesValue{retention: d.Retention, chunks: make([]*tsChunk, 0, len(d.Chunks))}
+
+	for _, chunk := range d.Chunks {
+		series.chunks = append(series.chunks, &tsChunk{
+			stream:    bitWriter{data: chunk.Data, bits: chunk.Bits},
+			count:     chunk.Count,
+			first:     chunk.First,
+			last:      chunk.Last,
+			lastValue: chunk.LastValue,
+			lastDelta: chunk.LastDelta,
+			leading:   chunk.Leading,
+			trailing:  chunk.Trailing,
+		})
+	}
+
+	return series
+}
+
+func dumpQueue(queue *queueValue) *dumpedQueue {
+	d := &dumpedQueue{LastSeq: queue.lastSeq, MaxDeliveries: queue.maxDeliveries, DeadLetter: queue.deadLetter}
+
+	for _, message := range queue.ready {
+		d.Ready = append(d.Ready, dumpMessage(message))
+	}
+
+	for _, message := range queue.visible {
+		d.Inflight = append(d.Inflight, dumpMessage(message))
+	}
+
+	return d
+}
+
+func dumpMessage(message *queueMessage) dumpedMessage {
+	return dumpedMessage{
+		Seq:        message.seq,
+		Body:       message.body,
+		Deliveries: message.deliveries,
+		VisibleAt:  message.visibleAt,
+	}
+}
+
+func restoreQueue(d *dumpedQueue) *queueValue {
+	queue := newQueue()
+	queue.lastSeq, queue.maxDeliveries, queue.deadLetter = d.LastSeq, d.MaxDeliveries, d.DeadLetter
+
+	for _, message := range d.Ready {
+		queue.ready = append(queue.ready, restoreMessage(message))
+	}
+
+	for _, message := range d.Inflight {
+		restored := restoreMessage(message)
+		queue.deliver(restored, restored.visibleAt)
+	}
+
+	return queue
+}
+
+func restoreMessage(message dumpedMessage) *queueMessage {
+	return &queueMessage{
+		seq:        message.Seq,
+		body:       message.Body,
+		deliveries: message.Deliveries,
+		visibleAt:  message.VisibleAt,
+	}
+}
@@ -1,35 +1,122 @@
 package engine
 
-import "sync"
+import (
+	"errors"
+	"sync"
 
+	"github.com/pingvincible/kvdatabase/internal/slot"
+)
+
+var ErrWrongType = errors.New("WRONGTYPE operation against a key holding the wrong kind of value")
+
+// Engine is a view of a single namespace. All namespaces of a database share
+// the mutex, so commands touching several keys stay atomic.
 type Engine struct {
-	mutex   sync.RWMutex
-	Storage map[string]string
+	mutex      *sync.RWMutex
+	storage    map[string]value
+	slots      map[int]map[string]struct{} // keys of storage by their hash slot
+	waiters    map[string][]chan struct{}
+	versions   map[string][]Version
+	namespaces map[string]*Engine
+	history    *history
 }
 
+// New creates a database and returns its default namespace.
 func New() *Engine {
-	return &Engine{
-		Storage: make(map[string]string),
+	engine := &Engine{
+		mutex:      &sync.RWMutex{},
+		namespaces: make(map[string]*Engine),
+		history:    &history{window: defaultHistoryRevisions},
 	}
+
+	return engine.newNamespace(DefaultNamespace)
 }
 
 func (e *Engine) Set(key, value string) {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
 
-	e.Storage[key] = value
+	e.store(key, stringValue(value))
+}
+
+func (e *Engine) Get(key string) (string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	str, _, err := lookupString(e.storage, key)
+
+	return str, err
 }
 
-func (e *Engine) Get(key string) string {
+// Exists reports whether key exists.
+func (e *Engine) Exists(key string) bool {
 	e.mutex.RLock()
 	defer e.mutex.RUnlock()
 
-	return e.Storage[key]
+	_, ok := e.storage[key]
+
+	return ok
+}
+
+// SlotKeys returns up to count keys of the namespace in keySlot.
+func (e *Engine) SlotKeys(keySlot, count int) []string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	keys := make([]string, 0, min(count, len(e.slots[keySlot])))
+
+	for key := range e.slots[keySlot] {
+		if len(keys) == count {
+			break
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys
 }
 
-func (e *Engine) Delete(key string) {
+// store sets the value of key, indexing the key by its slot if it is new.
+func (e *Engine) store(key string, stored value) {
+	if _, ok := e.storage[key]; !ok {
+		keySlot := slot.Of(key)
+
+		keys, ok := e.slots[keySlot]
+		if !ok {
+			keys = make(map[string]struct{})
+			e.slots[keySlot] = keys
+		}
+
+		keys[key] = struct{}{}
+	}
+
+	e.storage[key] = stored
+}
+
+// remove deletes key and its slot index entry.
+func (e *Engine) remove(key string) {
+	if _, ok := e.storage[key]; !ok {
+		return
+	}
+
+	delete(e.storage, key)
+
+	keySlot := slot.Of(key)
+
+	delete(e.slots[keySlot], key)
+
+	if len(e.slots[keySlot]) == 0 {
+		delete(e.slots, keySlot)
+	}
+}
+
+// Delete removes key and reports whether it existed.
+func (e *Engine) Delete(key string) bool {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
 
-	delete(e.Storage, key)
+	_, ok := e.storage[key]
+	e.remove(key)
+
+	return ok
 }
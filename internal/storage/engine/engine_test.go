@@ -1,10 +1,15 @@
 package engine_test
 
 import (
+	"context"
+	"math"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/pingvincible/kvdatabase/internal/storage/engine"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestEngineMethods(t *testing.T) {
@@ -29,11 +34,1018 @@ func TestEngineMethods(t *testing.T) {
 
 			kvDatabase := engine.New()
 			kvDatabase.Set(testCase.key, testCase.value)
-			value := kvDatabase.Get(testCase.key)
+			value, err := kvDatabase.Get(testCase.key)
+			require.NoError(t, err)
 			assert.Equal(t, testCase.value, value)
 			kvDatabase.Delete(testCase.key)
-			value = kvDatabase.Get(testCase.key)
+			value, err = kvDatabase.Get(testCase.key)
+			require.NoError(t, err)
 			assert.Empty(t, value)
 		})
 	}
 }
+
+func TestEngineHash(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+
+	added, err := kvDatabase.HSet("user/1", map[string]string{"name": "bob", "email": "bob_mail"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, added)
+
+	added, err = kvDatabase.HSet("user/1", map[string]string{"name": "alice", "age": "30"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, added)
+
+	value, err := kvDatabase.HGet("user/1", "name")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", value)
+
+	fields, err := kvDatabase.HGetAll("user/1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"name": "alice", "email": "bob_mail", "age": "30"}, fields)
+
+	_, err = kvDatabase.Get("user/1")
+	require.ErrorIs(t, err, engine.ErrWrongType)
+
+	kvDatabase.Set("plain", "value")
+	_, err = kvDatabase.HSet("plain", map[string]string{"field": "value"})
+	require.ErrorIs(t, err, engine.ErrWrongType)
+
+	removed, err := kvDatabase.HDel("user/1", []string{"name", "email", "age", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, removed)
+
+	fields, err = kvDatabase.HGetAll("user/1")
+	require.NoError(t, err)
+	assert.Empty(t, fields)
+
+	kvDatabase.Set("user/1", "value")
+	value, err = kvDatabase.Get("user/1")
+	require.NoError(t, err)
+	assert.Equal(t, "value", value)
+}
+
+func TestEngineList(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+
+	length, err := kvDatabase.RPush("queue", []string{"b", "c"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, length)
+
+	length, err = kvDatabase.LPush("queue", []string{"a", "z"})
+	require.NoError(t, err)
+	assert.Equal(t, 4, length)
+
+	values, err := kvDatabase.LRange("queue", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"z", "a", "b", "c"}, values)
+
+	values, err = kvDatabase.LRange("queue", -2, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, values)
+
+	value, ok, err := kvDatabase.LPop("queue")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "z", value)
+
+	value, ok, err = kvDatabase.RPop("queue")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "c", value)
+
+	length, err = kvDatabase.LLen("queue")
+	require.NoError(t, err)
+	assert.Equal(t, 2, length)
+
+	_, err = kvDatabase.Get("queue")
+	require.ErrorIs(t, err, engine.ErrWrongType)
+
+	_, _, _ = kvDatabase.LPop("queue")
+	_, _, _ = kvDatabase.LPop("queue")
+
+	_, ok, err = kvDatabase.LPop("queue")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// interleaved pushes and pops on both ends keep the order
+	expected := []string{}
+
+	for i := range 100 {
+		value := strconv.Itoa(i)
+
+		_, err = kvDatabase.LPush("mixed", []string{value})
+		require.NoError(t, err)
+		_, err = kvDatabase.RPush("mixed", []string{value})
+		require.NoError(t, err)
+
+		expected = append(append([]string{value}, expected...), value)
+
+		if i%3 == 0 {
+			value, _, err = kvDatabase.LPop("mixed")
+			require.NoError(t, err)
+			assert.Equal(t, expected[0], value)
+
+			expected = expected[1:]
+		}
+	}
+
+	values, err = kvDatabase.LRange("mixed", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, expected, values)
+}
+
+func TestEngineQueueVisibility(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+	now := time.Now()
+
+	for _, body := range []string{"a", "b", "c", "d"} {
+		_, err := kvDatabase.QPush("jobs", body)
+		require.NoError(t, err)
+	}
+
+	for _, visibility := range []time.Duration{3 * time.Second, time.Second, 2 * time.Second, 4 * time.Second} {
+		pop, err := kvDatabase.QPop("jobs", visibility, now)
+		require.NoError(t, err)
+		require.True(t, pop.Delivered)
+	}
+
+	acked, err := kvDatabase.QAck("jobs/3", now)
+	require.NoError(t, err)
+	assert.True(t, acked)
+
+	// only b became visible again, c was acknowledged before
+	pop, err := kvDatabase.QPop("jobs", time.Minute, now.Add(2500*time.Millisecond))
+	require.NoError(t, err)
+	assert.Equal(t, engine.QueueMessage{ID: "jobs/2", Body: "b", Deliveries: 2}, pop.Message)
+
+	nacked, _, err := kvDatabase.QNack("jobs/4", now)
+	require.NoError(t, err)
+	assert.True(t, nacked)
+
+	pop, err = kvDatabase.QPop("jobs", time.Minute, now.Add(2500*time.Millisecond))
+	require.NoError(t, err)
+	assert.Equal(t, engine.QueueMessage{ID: "jobs/4", Body: "d", Deliveries: 2}, pop.Message)
+
+	pop, err = kvDatabase.QPop("jobs", time.Minute, now.Add(3*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, engine.QueueMessage{ID: "jobs/1", Body: "a", Deliveries: 2}, pop.Message)
+
+	pop, err = kvDatabase.QPop("jobs", time.Minute, now.Add(3*time.Second))
+	require.NoError(t, err)
+	assert.False(t, pop.Delivered)
+}
+
+func TestEngineWaitFor(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+
+	wait, stop := kvDatabase.WaitFor([]string{"jobs", "other"})
+	defer stop()
+
+	assert.False(t, kvDatabase.Delete("missing"))
+	assert.Empty(t, wait)
+
+	_, err := kvDatabase.RPush("other", []string{"job"})
+	require.NoError(t, err)
+	assert.Len(t, wait, 1)
+
+	assert.True(t, kvDatabase.Delete("other"))
+}
+
+func TestEngineSet(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+
+	added, err := kvDatabase.SAdd("tags/1", []string{"go", "db", "go"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, added)
+
+	_, err = kvDatabase.SAdd("tags/2", []string{"db", "cache"})
+	require.NoError(t, err)
+
+	members, err := kvDatabase.SMembers("tags/1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db", "go"}, members)
+
+	exists, err := kvDatabase.SIsMember("tags/1", "go")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	members, err = kvDatabase.SInter([]string{"tags/1", "tags/2"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db"}, members)
+
+	members, err = kvDatabase.SInter([]string{"tags/1", "missing"})
+	require.NoError(t, err)
+	assert.Empty(t, members)
+
+	members, err = kvDatabase.SUnion([]string{"tags/1", "tags/2"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cache", "db", "go"}, members)
+
+	removed, err := kvDatabase.SRem("tags/1", []string{"go", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	_, err = kvDatabase.Get("tags/2")
+	require.ErrorIs(t, err, engine.ErrWrongType)
+}
+
+func TestEngineZSet(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+
+	added, err := kvDatabase.ZAdd("board", []engine.ZMember{
+		{Member: "alice", Score: 30},
+		{Member: "bob", Score: 10},
+		{Member: "carol", Score: 20},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, added)
+
+	added, err = kvDatabase.ZAdd("board", []engine.ZMember{{Member: "bob", Score: 40}})
+	require.NoError(t, err)
+	assert.Equal(t, 0, added)
+
+	members, err := kvDatabase.ZRange("board", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []engine.ZMember{
+		{Member: "carol", Score: 20},
+		{Member: "alice", Score: 30},
+		{Member: "bob", Score: 40},
+	}, members)
+
+	members, err = kvDatabase.ZRangeByScore("board", 25, 40)
+	require.NoError(t, err)
+	assert.Equal(t, []engine.ZMember{{Member: "alice", Score: 30}, {Member: "bob", Score: 40}}, members)
+
+	rank, ok, err := kvDatabase.ZRank("board", "bob")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 2, rank)
+
+	removed, err := kvDatabase.ZRem("board", []string{"carol", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	_, ok, err = kvDatabase.ZRank("board", "carol")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = kvDatabase.SAdd("board", []string{"member"})
+	require.ErrorIs(t, err, engine.ErrWrongType)
+}
+
+func TestEngineJSON(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+
+	require.ErrorIs(t, kvDatabase.JSONSet("doc", "$.name", `"bob"`), engine.ErrJSONPathMissing)
+	require.ErrorIs(t, kvDatabase.JSONSet("doc", "$", `{"name": `), engine.ErrInvalidJSON)
+	require.NoError(t, kvDatabase.JSONSet("doc", "$", `{"name": "bob", "visits": 1, "tags": ["a", "b", "c"]}`))
+	require.NoError(t, kvDatabase.JSONSet("doc", "$.address", `{"city": "Paris"}`))
+	require.NoError(t, kvDatabase.JSONSet("doc", "$.tags[-1]", `"z"`))
+
+	value, err := kvDatabase.JSONGet("doc", "$.address.city")
+	require.NoError(t, err)
+	assert.Equal(t, `"Paris"`, value)
+
+	value, err = kvDatabase.JSONGet("doc", "tags")
+	require.NoError(t, err)
+	assert.Equal(t, `["a","b","z"]`, value)
+
+	_, err = kvDatabase.JSONGet("doc", "$.missing")
+	require.ErrorIs(t, err, engine.ErrJSONPathMissing)
+
+	_, err = kvDatabase.JSONGet("doc", "$..name")
+	require.ErrorIs(t, err, engine.ErrInvalidJSONPath)
+
+	number, err := kvDatabase.JSONNumIncrBy("doc", "$.visits", 2.5)
+	require.NoError(t, err)
+	assert.InDelta(t, 3.5, number, 0)
+
+	_, err = kvDatabase.JSONNumIncrBy("doc", "$.name", 1)
+	require.ErrorIs(t, err, engine.ErrJSONNotNumber)
+
+	require.NoError(t, kvDatabase.JSONSet("big", "$", `{"n": 1e308}`))
+	_, err = kvDatabase.JSONNumIncrBy("big", "$.n", math.MaxFloat64)
+	require.ErrorIs(t, err, engine.ErrJSONNotFinite)
+	_, err = kvDatabase.JSONNumIncrBy("big", "$.n", math.Inf(1))
+	require.ErrorIs(t, err, engine.ErrJSONNotFinite)
+
+	// the number is kept as it was
+	value, err = kvDatabase.JSONGet("big", "$")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"n": 1e308}`, value)
+
+	removed, err := kvDatabase.JSONDel("doc", "$.tags[0]")
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	removed, err = kvDatabase.JSONDel("doc", "$.address.zip")
+	require.NoError(t, err)
+	assert.Equal(t, 0, removed)
+
+	value, err = kvDatabase.JSONGet("doc", "$")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "bob", "visits": 3.5, "tags": ["b", "z"], "address": {"city": "Paris"}}`, value)
+
+	_, err = kvDatabase.Get("doc")
+	require.ErrorIs(t, err, engine.ErrWrongType)
+
+	removed, err = kvDatabase.JSONDel("doc", "$")
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	value, err = kvDatabase.JSONGet("doc", "$")
+	require.NoError(t, err)
+	assert.Empty(t, value)
+}
+
+func TestEngineStream(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+	now := time.UnixMilli(1000)
+
+	first, err := kvDatabase.XAdd("events", "created", now)
+	require.NoError(t, err)
+	assert.Equal(t, "1000-0", first.String())
+
+	second, err := kvDatabase.XAdd("events", "updated", now.Add(-time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, "1000-1", second.String())
+
+	third, err := kvDatabase.XAdd("events", "deleted", now.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, "2000-0", third.String())
+
+	entries, err := kvDatabase.XRange("events", second, engine.MaxStreamID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []engine.StreamEntry{{ID: second, Value: "updated"}, {ID: third, Value: "deleted"}}, entries)
+
+	reads, err := kvDatabase.XRead(context.Background(), []string{"events"}, []engine.StreamID{second},
+		engine.StreamReadOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []engine.StreamRead{{Key: "events", Entries: []engine.StreamEntry{{ID: third, Value: "deleted"}}}}, reads)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+
+		_, _ = kvDatabase.XAdd("events", "restored", now.Add(2*time.Second))
+	}()
+
+	reads, err = kvDatabase.XRead(context.Background(), []string{"events"}, []engine.StreamID{third},
+		engine.StreamReadOptions{Block: true})
+	require.NoError(t, err)
+	require.Len(t, reads, 1)
+	assert.Equal(t, "restored", reads[0].Entries[0].Value)
+
+	_, err = kvDatabase.Get("events")
+	require.ErrorIs(t, err, engine.ErrWrongType)
+}
+
+func TestEngineStreamConsumerGroup(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+	ctx := context.Background()
+	now := time.UnixMilli(1000)
+
+	require.ErrorIs(t, kvDatabase.XGroupCreate("jobs", "workers", engine.MinStreamID, false, false), engine.ErrNoSuchKey)
+	require.NoError(t, kvDatabase.XGroupCreate("jobs", "workers", engine.MinStreamID, false, true))
+	require.ErrorIs(t, kvDatabase.XGroupCreate("jobs", "workers", engine.MinStreamID, false, false), engine.ErrGroupExists)
+
+	first, _ := kvDatabase.XAdd("jobs", "a", now)
+	second, _ := kvDatabase.XAdd("jobs", "b", now)
+
+	entries, err := kvDatabase.XReadGroup(ctx, "jobs", "workers", "w1", nil, engine.StreamReadOptions{Count: 1}, now)
+	require.NoError(t, err)
+	assert.Equal(t, []engine.StreamEntry{{ID: first, Value: "a"}}, entries)
+
+	entries, err = kvDatabase.XReadGroup(ctx, "jobs", "workers", "w2", nil, engine.StreamReadOptions{}, now)
+	require.NoError(t, err)
+	assert.Equal(t, []engine.StreamEntry{{ID: second, Value: "b"}}, entries)
+
+	entries, err = kvDatabase.XReadGroup(ctx, "jobs", "workers", "w2", nil,
+		engine.StreamReadOptions{Block: true, Timeout: 10 * time.Millisecond}, now)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	later := now.Add(time.Second)
+
+	entries, err = kvDatabase.XReadGroup(ctx, "jobs", "workers", "w1", &engine.MinStreamID,
+		engine.StreamReadOptions{}, later)
+	require.NoError(t, err)
+	assert.Equal(t, []engine.StreamEntry{{ID: first, Value: "a"}}, entries)
+
+	pending, err := kvDatabase.XPending("jobs", "workers")
+	require.NoError(t, err)
+	assert.Equal(t, []engine.PendingEntry{
+		{ID: first, Consumer: "w1", Deliveries: 2, DeliveredAt: later},
+		{ID: second, Consumer: "w2", Deliveries: 1, DeliveredAt: now},
+	}, pending)
+
+	acknowledged, err := kvDatabase.XAck("jobs", "workers", []engine.StreamID{first, first})
+	require.NoError(t, err)
+	assert.Equal(t, 1, acknowledged)
+
+	pending, err = kvDatabase.XPending("jobs", "workers")
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+
+	_, err = kvDatabase.XPending("jobs", "missing")
+	require.ErrorIs(t, err, engine.ErrNoSuchGroup)
+}
+
+func TestEngineHyperLogLog(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+
+	const visitors = 50000
+
+	for i := range visitors {
+		_, err := kvDatabase.PFAdd("visitors/"+strconv.Itoa(i%2), []string{"user" + strconv.Itoa(i)})
+		require.NoError(t, err)
+	}
+
+	changed, err := kvDatabase.PFAdd("visitors/0", []string{"user0"})
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	count, err := kvDatabase.PFCount([]string{"visitors/0"})
+	require.NoError(t, err)
+	assert.InEpsilon(t, visitors/2, count, 0.03)
+
+	require.NoError(t, kvDatabase.PFMerge("visitors", []string{"visitors/0", "visitors/1"}))
+
+	count, err = kvDatabase.PFCount([]string{"visitors"})
+	require.NoError(t, err)
+	assert.InEpsilon(t, visitors, count, 0.03)
+
+	count, err = kvDatabase.PFCount([]string{"missing"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestEngineBloom(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+
+	require.ErrorIs(t, kvDatabase.BFReserve("seen", 1.5, 100), engine.ErrInvalidBloomParams)
+	require.ErrorIs(t, kvDatabase.BFReserve("seen", 1e-9, 2000000000), engine.ErrBloomTooLarge)
+	require.ErrorIs(t, kvDatabase.BFReserve("seen", math.NaN(), 100), engine.ErrInvalidBloomParams)
+	require.NoError(t, kvDatabase.BFReserve("seen", 0.01, 1000))
+	require.ErrorIs(t, kvDatabase.BFReserve("seen", 0.01, 1000), engine.ErrBloomExists)
+
+	for i := range 1000 {
+		_, err := kvDatabase.BFAdd("seen", "item"+strconv.Itoa(i))
+		require.NoError(t, err)
+	}
+
+	added, err := kvDatabase.BFAdd("seen", "item0")
+	require.NoError(t, err)
+	assert.False(t, added)
+
+	falsePositives := 0
+
+	for i := range 1000 {
+		exists, err := kvDatabase.BFExists("seen", "item"+strconv.Itoa(i))
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		exists, err = kvDatabase.BFExists("seen", "other"+strconv.Itoa(i))
+		require.NoError(t, err)
+
+		if exists {
+			falsePositives++
+		}
+	}
+
+	assert.Less(t, falsePositives, 30)
+
+	kvDatabase.Set("plain", "value")
+	_, err = kvDatabase.BFAdd("plain", "item")
+	require.ErrorIs(t, err, engine.ErrWrongType)
+}
+
+func TestEngineTimeSeries(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+
+	require.NoError(t, kvDatabase.TSCreate("cpu", 0))
+	require.ErrorIs(t, kvDatabase.TSCreate("cpu", 0), engine.ErrTSExists)
+
+	want := make([]engine.Sample, 0, 300)
+
+	for i := range int64(300) {
+		sample := engine.Sample{Timestamp: 1000 + i*10, Value: float64(i%7) * 1.5}
+		if i == 150 {
+			sample.Timestamp += 3
+		}
+
+		want = append(want, sample)
+		require.NoError(t, kvDatabase.TSAdd("cpu", sample, 0))
+	}
+
+	require.ErrorIs(t, kvDatabase.TSAdd("cpu", engine.Sample{Timestamp: 1000, Value: 1}, 0), engine.ErrTSSampleTooOld)
+
+	samples, err := kvDatabase.TSRange("cpu", math.MinInt64, math.MaxInt64, engine.Aggregation{})
+	require.NoError(t, err)
+	assert.Equal(t, want, samples)
+
+	samples, err = kvDatabase.TSRange("cpu", 1000, 1039, engine.Aggregation{Type: engine.AggregationSum, Bucket: 20})
+	require.NoError(t, err)
+	assert.Equal(t, []engine.Sample{{Timestamp: 1000, Value: 1.5}, {Timestamp: 1020, Value: 7.5}}, samples)
+
+	samples, err = kvDatabase.TSRange("cpu", 1000, 1039, engine.Aggregation{Type: engine.AggregationAvg, Bucket: 40})
+	require.NoError(t, err)
+	assert.Equal(t, []engine.Sample{{Timestamp: 1000, Value: 2.25}}, samples)
+
+	_, err = kvDatabase.TSRange("cpu", 0, 1, engine.Aggregation{Type: "median", Bucket: 40})
+	require.ErrorIs(t, err, engine.ErrTSInvalidAggregation)
+}
+
+func TestEngineTimeSeriesRetention(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+
+	for i := range int64(1000) {
+		require.NoError(t, kvDatabase.TSAdd("mem", engine.Sample{Timestamp: i, Value: float64(i)}, 100*time.Millisecond))
+	}
+
+	samples, err := kvDatabase.TSRange("mem", 0, math.MaxInt64, engine.Aggregation{})
+	require.NoError(t, err)
+	require.Len(t, samples, 101)
+	assert.Equal(t, int64(899), samples[0].Timestamp)
+
+	kvDatabase.EnforceRetention()
+
+	samples, err = kvDatabase.TSRange("mem", 0, math.MaxInt64, engine.Aggregation{})
+	require.NoError(t, err)
+	assert.Len(t, samples, 101)
+}
+
+func TestEngineBitmap(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+
+	previous, err := kvDatabase.SetBit("active", 7, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 0, previous)
+
+	previous, err = kvDatabase.SetBit("active", 7, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, previous)
+
+	_, err = kvDatabase.SetBit("active", 17, 1)
+	require.NoError(t, err)
+
+	value, err := kvDatabase.Get("active")
+	require.NoError(t, err)
+	assert.Equal(t, "010040", value)
+
+	// bits are set in place, values read before are not affected
+	_, err = kvDatabase.SetBit("active", 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "010040", value)
+
+	value, err = kvDatabase.Get("active")
+	require.NoError(t, err)
+	assert.Equal(t, "810040", value)
+
+	_, err = kvDatabase.SetBit("active", 0, 0)
+	require.NoError(t, err)
+
+	kvDatabase.Set("flags", "a")
+
+	_, err = kvDatabase.SetBit("flags", 6, 1)
+	require.NoError(t, err)
+
+	value, err = kvDatabase.Get("flags")
+	require.NoError(t, err)
+	assert.Equal(t, "63", value)
+
+	bit, err := kvDatabase.GetBit("active", 17)
+	require.NoError(t, err)
+	assert.Equal(t, 1, bit)
+
+	bit, err = kvDatabase.GetBit("active", 1000)
+	require.NoError(t, err)
+	assert.Equal(t, 0, bit)
+
+	count, err := kvDatabase.BitCount("active", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	count, err = kvDatabase.BitCount("active", -1, -1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	position, err := kvDatabase.BitPos("active", 1, 1, -1, false)
+	require.NoError(t, err)
+	assert.Equal(t, 17, position)
+
+	position, err = kvDatabase.BitPos("active", 0, 0, -1, false)
+	require.NoError(t, err)
+	assert.Equal(t, 0, position)
+
+	kvDatabase.Set("full", "\xff")
+
+	position, err = kvDatabase.BitPos("full", 0, 0, -1, false)
+	require.NoError(t, err)
+	assert.Equal(t, 8, position)
+
+	position, err = kvDatabase.BitPos("full", 0, 0, -1, true)
+	require.NoError(t, err)
+	assert.Equal(t, -1, position)
+
+	length, err := kvDatabase.BitOp(engine.BitOpAnd, "both", []string{"active", "full"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, length)
+
+	value, err = kvDatabase.Get("both")
+	require.NoError(t, err)
+	assert.Equal(t, "010000", value)
+
+	_, err = kvDatabase.BitOp(engine.BitOpOr, "either", []string{"active", "full"})
+	require.NoError(t, err)
+
+	value, err = kvDatabase.Get("either")
+	require.NoError(t, err)
+	assert.Equal(t, "ff0040", value)
+
+	_, err = kvDatabase.BitOp(engine.BitOpNot, "inverted", []string{"full"})
+	require.NoError(t, err)
+
+	value, err = kvDatabase.Get("inverted")
+	require.NoError(t, err)
+	assert.Equal(t, "00", value)
+
+	_, err = kvDatabase.BitOp(engine.BitOpNot, "inverted", []string{"full", "active"})
+	require.ErrorIs(t, err, engine.ErrBitOp)
+
+	_, err = kvDatabase.BitOp("NAND", "result", []string{"full", "active"})
+	require.ErrorIs(t, err, engine.ErrBitOp)
+
+	_, err = kvDatabase.SetBit("active", -1, 1)
+	require.ErrorIs(t, err, engine.ErrBitOffset)
+}
+
+func TestEngineNamespaces(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+	billing := kvDatabase.Namespace("billing")
+
+	kvDatabase.Set("key", "default")
+	billing.Set("key", "billing")
+	billing.Set("other", "billing")
+
+	assert.Same(t, billing, kvDatabase.Namespace("billing"))
+	assert.Same(t, kvDatabase, billing.Namespace(engine.DefaultNamespace))
+
+	value, err := kvDatabase.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, "default", value)
+
+	value, err = billing.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, "billing", value)
+
+	assert.Equal(t, 1, kvDatabase.KeyCount())
+	assert.Equal(t, 2, billing.KeyCount())
+	assert.Equal(t, []string{engine.DefaultNamespace, "billing"}, kvDatabase.NamespaceNames())
+
+	assert.Equal(t, 2, billing.Flush())
+	assert.Equal(t, 0, billing.KeyCount())
+	assert.Equal(t, 1, kvDatabase.KeyCount())
+
+	billing.Set("other", "billing")
+
+	assert.Equal(t, []engine.Key{{Namespace: "billing", Name: "other"}, {Namespace: engine.DefaultNamespace, Name: "key"}},
+		kvDatabase.DeleteKeys([]engine.Key{
+			{Namespace: "billing", Name: "other"},
+			{Namespace: "billing", Name: "missing"},
+			{Namespace: "unknown", Name: "key"},
+			{Namespace: engine.DefaultNamespace, Name: "key"},
+		}))
+	assert.Equal(t, 0, billing.KeyCount())
+	assert.Equal(t, 0, kvDatabase.KeyCount())
+}
+
+func TestEngineSlotKeys(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+
+	// foo and {foo}.n are in slot 12182, bar in slot 5061
+	kvDatabase.Set("foo", "0")
+	kvDatabase.Set("bar", "1")
+	kvDatabase.Set("{foo}.1", "1")
+	_, err := kvDatabase.RPush("{foo}.2", []string{"a"})
+	require.NoError(t, err)
+
+	assert.True(t, kvDatabase.Exists("bar"))
+	assert.False(t, kvDatabase.Exists("missing"))
+	assert.ElementsMatch(t, []string{"foo", "{foo}.1", "{foo}.2"}, kvDatabase.SlotKeys(12182, 10))
+	assert.Len(t, kvDatabase.SlotKeys(12182, 2), 2)
+	assert.Equal(t, []string{"bar"}, kvDatabase.SlotKeys(5061, 10))
+
+	// keys emptied or deleted leave the index
+	_, _, err = kvDatabase.LPop("{foo}.2")
+	require.NoError(t, err)
+	assert.True(t, kvDatabase.Delete("foo"))
+	assert.Equal(t, []string{"{foo}.1"}, kvDatabase.SlotKeys(12182, 10))
+
+	kvDatabase.Flush()
+	assert.Empty(t, kvDatabase.SlotKeys(12182, 10))
+	assert.Empty(t, kvDatabase.SlotKeys(5061, 10))
+}
+
+func TestEngineHistory(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+	commit := func(revision int64, keys ...string) {
+		changes := make([]engine.Key, 0, len(keys))
+		for _, key := range keys {
+			changes = append(changes, engine.Key{Namespace: engine.DefaultNamespace, Name: key})
+		}
+
+		kvDatabase.Commit(revision, changes)
+	}
+
+	kvDatabase.Set("key", "a")
+	commit(1, "key")
+	kvDatabase.Set("key", "b")
+	commit(2, "key")
+	kvDatabase.Delete("key")
+	commit(3, "key")
+	commit(4, "missing")
+	_, err := kvDatabase.HSet("key", map[string]string{"name": "bob"})
+	require.NoError(t, err)
+	commit(5, "key")
+
+	for revision, want := range map[int64]string{1: "a", 2: "b", 3: ""} {
+		value, err := kvDatabase.GetAt("key", revision)
+		require.NoError(t, err)
+		assert.Equal(t, want, value)
+	}
+
+	_, err = kvDatabase.GetAt("key", 5)
+	require.ErrorIs(t, err, engine.ErrWrongType)
+
+	_, err = kvDatabase.GetAt("key", 6)
+	require.ErrorIs(t, err, engine.ErrFutureRevision)
+
+	assert.Equal(t, []engine.Version{
+		{Revision: 1, Type: engine.TypeString, Value: "a"},
+		{Revision: 2, Type: engine.TypeString, Value: "b"},
+		{Revision: 3, Type: engine.TypeNone},
+		{Revision: 5, Type: engine.TypeHash},
+	}, kvDatabase.History("key"))
+	assert.Empty(t, kvDatabase.History("missing"))
+
+	require.NoError(t, kvDatabase.Compact(2))
+
+	value, err := kvDatabase.GetAt("key", 2)
+	require.NoError(t, err)
+	assert.Equal(t, "b", value)
+
+	_, err = kvDatabase.GetAt("key", 1)
+	require.ErrorIs(t, err, engine.ErrCompacted)
+	require.ErrorIs(t, kvDatabase.Compact(1), engine.ErrCompacted)
+
+	kvDatabase.RetainHistory(1)
+	kvDatabase.EnforceRetention()
+
+	assert.Equal(t, []engine.Version{{Revision: 5, Type: engine.TypeHash}}, kvDatabase.History("key"))
+
+	// a mutation changing keys of several namespaces is recorded at once
+	billing := kvDatabase.Namespace("billing")
+	billing.Set("key", "c")
+	kvDatabase.Flush()
+	kvDatabase.Commit(6, []engine.Key{{Namespace: engine.DefaultNamespace}, {Namespace: "billing", Name: "key"}})
+
+	assert.Equal(t, []engine.Version{{Revision: 5, Type: engine.TypeHash}, {Revision: 6, Type: engine.TypeNone}},
+		kvDatabase.History("key"))
+	assert.Equal(t, []engine.Version{{Revision: 6, Type: engine.TypeString, Value: "c"}}, billing.History("key"))
+}
+
+func TestEngineQueue(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+	now := time.Now()
+
+	require.NoError(t, kvDatabase.QCreate("jobs", 2, "jobs.dead"))
+
+	first, err := kvDatabase.QPush("jobs", "build app")
+	require.NoError(t, err)
+	assert.Equal(t, "jobs/1", first)
+
+	_, err = kvDatabase.QPush("jobs", "deploy app")
+	require.NoError(t, err)
+
+	pop, err := kvDatabase.QPop("jobs", time.Second, now)
+	require.NoError(t, err)
+	assert.True(t, pop.Delivered)
+	assert.Equal(t, engine.QueueMessage{ID: "jobs/1", Body: "build app", Deliveries: 1}, pop.Message)
+
+	acked, deadLettered, err := kvDatabase.QNack("jobs/1", now)
+	require.NoError(t, err)
+	assert.True(t, acked)
+	assert.False(t, deadLettered)
+
+	pop, err = kvDatabase.QPop("jobs", time.Second, now)
+	require.NoError(t, err)
+	assert.Equal(t, engine.QueueMessage{ID: "jobs/1", Body: "build app", Deliveries: 2}, pop.Message)
+
+	pop, err = kvDatabase.QPop("jobs", time.Second, now)
+	require.NoError(t, err)
+	assert.Equal(t, "jobs/2", pop.Message.ID)
+
+	acked, err = kvDatabase.QAck("jobs/2", now)
+	require.NoError(t, err)
+	assert.True(t, acked)
+
+	acked, err = kvDatabase.QAck("jobs/2", now)
+	require.NoError(t, err)
+	assert.False(t, acked)
+
+	// jobs/1 was delivered twice and its visibility ran out, so it is dead-lettered
+	pop, err = kvDatabase.QPop("jobs", time.Second, now.Add(time.Second))
+	require.NoError(t, err)
+	assert.False(t, pop.Delivered)
+	assert.True(t, pop.DeadLettered)
+
+	pop, err = kvDatabase.QPop("jobs.dead", time.Second, now)
+	require.NoError(t, err)
+	assert.True(t, pop.Delivered)
+	assert.False(t, pop.DeadLettered)
+	assert.Equal(t, engine.QueueMessage{ID: "jobs.dead/1", Body: "build app", Deliveries: 1}, pop.Message)
+
+	_, err = kvDatabase.QAck("jobs", now)
+	require.ErrorIs(t, err, engine.ErrInvalidMessageID)
+}
+
+func TestEngineTokenBucket(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+	now := time.Now()
+
+	result, err := kvDatabase.TokenBucket("api", 2, 1, 2, now)
+	require.NoError(t, err)
+	assert.Equal(t, engine.RateLimitResult{Allowed: true, Remaining: 0}, result)
+
+	result, err = kvDatabase.TokenBucket("api", 2, 1, 1, now.Add(500*time.Millisecond))
+	require.NoError(t, err)
+	assert.Equal(t, engine.RateLimitResult{Remaining: 0.5, RetryAfter: 500 * time.Millisecond}, result)
+
+	result, err = kvDatabase.TokenBucket("api", 2, 1, 1, now.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, engine.RateLimitResult{Allowed: true, Remaining: 0}, result)
+
+	result, err = kvDatabase.TokenBucket("api", 2, 1, 3, now.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, engine.RateLimitResult{Remaining: 0, RetryAfter: -1}, result)
+}
+
+func TestEngineSlidingWindow(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+	now := time.Now()
+
+	for range 4 {
+		result, err := kvDatabase.SlidingWindow("api", 4, time.Second, 1, now)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+	}
+
+	result, err := kvDatabase.SlidingWindow("api", 4, time.Second, 1, now.Add(500*time.Millisecond))
+	require.NoError(t, err)
+	assert.Equal(t, engine.RateLimitResult{Remaining: 0, RetryAfter: 750 * time.Millisecond}, result)
+
+	// half of the previous window is still in the sliding window
+	result, err = kvDatabase.SlidingWindow("api", 4, time.Second, 2, now.Add(1500*time.Millisecond))
+	require.NoError(t, err)
+	assert.Equal(t, engine.RateLimitResult{Allowed: true, Remaining: 0}, result)
+
+	result, err = kvDatabase.SlidingWindow("api", 4, time.Second, 1, now.Add(1500*time.Millisecond))
+	require.NoError(t, err)
+	assert.Equal(t, engine.RateLimitResult{Remaining: 0, RetryAfter: 250 * time.Millisecond}, result)
+}
+
+func TestEngineRateLimitExpiry(t *testing.T) {
+	t.Parallel()
+
+	kvDatabase := engine.New()
+	now := time.Now()
+
+	// refilled and slid out of their windows long ago
+	_, err := kvDatabase.TokenBucket("idle", 2, 1, 2, now.Add(-time.Hour))
+	require.NoError(t, err)
+	_, err = kvDatabase.SlidingWindow("idle.window", 4, time.Second, 1, now.Add(-time.Hour))
+	require.NoError(t, err)
+
+	_, err = kvDatabase.TokenBucket("active", 2, 1, 2, now)
+	require.NoError(t, err)
+	_, err = kvDatabase.SlidingWindow("active.window", 4, time.Minute, 1, now)
+	require.NoError(t, err)
+
+	kvDatabase.EnforceRetention()
+
+	assert.ElementsMatch(t, []string{"active", "active.window"}, kvDatabase.Keys())
+}
+
+func TestEngineDumpRestore(t *testing.T) {
+	t.Parallel()
+
+	source := engine.New()
+	source.Set("string", "value")
+
+	_, err := source.HSet("hash", map[string]string{"name": "bob"})
+	require.NoError(t, err)
+	_, err = source.RPush("list", []string{"a", "b"})
+	require.NoError(t, err)
+	_, err = source.SAdd("set", []string{"x", "y"})
+	require.NoError(t, err)
+	_, err = source.ZAdd("zset", []engine.ZMember{{Member: "m", Score: 1.5}})
+	require.NoError(t, err)
+	require.NoError(t, source.JSONSet("doc", "$", `{"a":1}`))
+	_, err = source.PFAdd("hll", []string{"a", "b", "c"})
+	require.NoError(t, err)
+	require.NoError(t, source.QCreate("jobs", 3, "jobs.dead"))
+	_, err = source.QPush("jobs", "job")
+	require.NoError(t, err)
+
+	target := engine.New()
+
+	keys := source.Keys()
+	assert.Len(t, keys, 8)
+
+	for _, key := range keys {
+		data, ok, err := source.Dump(key)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.NoError(t, target.Restore(key, data))
+	}
+
+	_, ok, err := source.Dump("missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	value, err := target.Get("string")
+	require.NoError(t, err)
+	assert.Equal(t, "value", value)
+
+	hash, err := target.HGetAll("hash")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"name": "bob"}, hash)
+
+	list, err := target.LRange("list", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, list)
+
+	members, err := target.SMembers("set")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"x", "y"}, members)
+
+	zset, err := target.ZRange("zset", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []engine.ZMember{{Member: "m", Score: 1.5}}, zset)
+
+	document, err := target.JSONGet("doc", "$.a")
+	require.NoError(t, err)
+	assert.Equal(t, "1", document)
+
+	count, err := target.PFCount([]string{"hll"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	pop, err := target.QPop("jobs", time.Minute, time.Now())
+	require.NoError(t, err)
+	require.True(t, pop.Delivered)
+	assert.Equal(t, "job", pop.Message.Body)
+
+	require.ErrorIs(t, target.Restore("broken", []byte("garbage")), engine.ErrInvalidDump)
+}
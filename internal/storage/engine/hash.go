@@ -0,0 +1,90 @@
+package engine
+
+import "maps"
+
+type hashValue map[string]string
+
+func (hashValue) Type() ValueType {
+	return TypeHash
+}
+
+// HSet stores fields in the hash at key and returns the number of fields that were added.
+func (e *Engine) HSet(key string, fields map[string]string) (int, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	hash, ok, err := lookup[hashValue](e.storage, key)
+	if err != nil {
+		return 0, err
+	}
+
+	if !ok {
+		hash = make(hashValue, len(fields))
+		e.store(key, hash)
+	}
+
+	added := 0
+
+	for field, fieldValue := range fields {
+		if _, exists := hash[field]; !exists {
+			added++
+		}
+
+		hash[field] = fieldValue
+	}
+
+	return added, nil
+}
+
+func (e *Engine) HGet(key, field string) (string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	hash, _, err := lookup[hashValue](e.storage, key)
+	if err != nil {
+		return "", err
+	}
+
+	return hash[field], nil
+}
+
+// HDel removes fields from the hash at key and returns the number of removed fields.
+// The key is removed together with its last field.
+func (e *Engine) HDel(key string, fields []string) (int, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	hash, ok, err := lookup[hashValue](e.storage, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	removed := 0
+
+	for _, field := range fields {
+		if _, exists := hash[field]; exists {
+			delete(hash, field)
+
+			removed++
+		}
+	}
+
+	if len(hash) == 0 {
+		e.remove(key)
+	}
+
+	return removed, nil
+}
+
+// HGetAll returns a copy of the hash at key.
+func (e *Engine) HGetAll(key string) (map[string]string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	hash, _, err := lookup[hashValue](e.storage, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return maps.Clone(hash), nil
+}
@@ -0,0 +1,208 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrCompacted      = errors.New("required revision has been compacted")
+	ErrFutureRevision = errors.New("required revision is a future revision")
+)
+
+// defaultHistoryRevisions is the number of latest revisions whose versions are retained by default.
+const defaultHistoryRevisions = 1000
+
+// TypeNone is the type of a version recording that the key was deleted.
+const TypeNone ValueType = "none"
+
+// Version is the state of a key after the mutation with Revision. Value is set for strings only.
+type Version struct {
+	Revision int64
+	Type     ValueType
+	Value    string
+}
+
+// history tracks revisions shared by all namespaces of a database.
+type history struct {
+	latest    int64
+	compacted int64 // versions before compacted are discarded
+	window    int64 // number of revisions retained by EnforceRetention, 0 retains everything
+}
+
+// RetainHistory sets the number of latest revisions whose versions are kept
+// when retention is enforced, 0 keeps all of them.
+func (e *Engine) RetainHistory(revisions int64) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.history.window = revisions
+}
+
+// CompactedRevision returns the oldest revision that can still be read.
+func (e *Engine) CompactedRevision() int64 {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return e.history.compacted
+}
+
+// Commit records the states of the keys changed by the mutation with revision as their versions.
+// All versions of the mutation are recorded under one lock, so readers never see it recorded
+// partially. Callers commit before any further write, so the versions are the states the mutation left.
+// A key without a name stands for all keys of the flushed namespace.
+func (e *Engine) Commit(revision int64, keys []Key) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.history.latest = max(e.history.latest, revision)
+
+	for _, key := range keys {
+		namespace, ok := e.namespaces[key.Namespace]
+		if !ok {
+			continue
+		}
+
+		if key.Name == "" {
+			namespace.recordFlush(revision)
+		} else {
+			namespace.recordVersion(key.Name, revision)
+		}
+	}
+}
+
+// recordVersion records the current state of key as its version at revision.
+func (e *Engine) recordVersion(key string, revision int64) {
+	version := Version{Revision: revision, Type: TypeNone}
+
+	if stored, ok := e.storage[key]; ok {
+		version.Type = stored.Type()
+		if version.Type == TypeString {
+			version.Value, _, _ = lookupString(e.storage, key)
+		}
+	} else if !e.existed(key) {
+		return
+	}
+
+	e.versions[key] = append(e.versions[key], version)
+}
+
+// recordFlush records the deletion of all keys of the flushed namespace at revision.
+func (e *Engine) recordFlush(revision int64) {
+	for key := range e.versions {
+		if e.existed(key) {
+			e.versions[key] = append(e.versions[key], Version{Revision: revision, Type: TypeNone})
+		}
+	}
+}
+
+// existed reports whether the latest recorded version of key is not a deletion.
+func (e *Engine) existed(key string) bool {
+	versions := e.versions[key]
+
+	return len(versions) > 0 && versions[len(versions)-1].Type != TypeNone
+}
+
+// GetAt returns the string value of key as it was at revision.
+func (e *Engine) GetAt(key string, revision int64) (string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	err := e.checkRevision(revision)
+	if err != nil {
+		return "", err
+	}
+
+	version, ok := versionAt(e.versions[key], revision)
+	if !ok || version.Type == TypeNone {
+		return "", nil
+	}
+
+	if version.Type != TypeString {
+		return "", ErrWrongType
+	}
+
+	return version.Value, nil
+}
+
+// History returns the retained versions of key from the oldest to the latest.
+func (e *Engine) History(key string) []Version {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return append([]Version(nil), e.versions[key]...)
+}
+
+// Compact discards versions of all namespaces that are not needed to read at revision or later.
+func (e *Engine) Compact(revision int64) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	err := e.checkRevision(revision)
+	if err != nil {
+		return err
+	}
+
+	e.compact(revision)
+
+	return nil
+}
+
+func (e *Engine) compact(revision int64) {
+	for _, namespace := range e.namespaces {
+		for key, versions := range namespace.versions {
+			// the latest version at revision is still needed unless it is a deletion
+			kept := len(versions)
+			for i, version := range versions {
+				if version.Revision > revision {
+					kept = i
+
+					break
+				}
+			}
+
+			if kept > 0 && versions[kept-1].Type != TypeNone {
+				kept--
+			}
+
+			if kept == len(versions) {
+				delete(namespace.versions, key)
+			} else {
+				namespace.versions[key] = versions[kept:]
+			}
+		}
+	}
+
+	e.history.compacted = max(e.history.compacted, revision)
+}
+
+// compactHistory discards versions outside of the retained window.
+func (e *Engine) compactHistory() {
+	if e.history.window <= 0 || e.history.latest-e.history.window <= e.history.compacted {
+		return
+	}
+
+	e.compact(e.history.latest - e.history.window)
+}
+
+func (e *Engine) checkRevision(revision int64) error {
+	if revision < e.history.compacted {
+		return fmt.Errorf("%w: oldest readable revision is %d", ErrCompacted, e.history.compacted)
+	}
+
+	if revision > e.history.latest {
+		return fmt.Errorf("%w: latest revision is %d", ErrFutureRevision, e.history.latest)
+	}
+
+	return nil
+}
+
+func versionAt(versions []Version, revision int64) (Version, bool) {
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].Revision <= revision {
+			return versions[i], true
+		}
+	}
+
+	return Version{}, false
+}
@@ -0,0 +1,367 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidJSON     = errors.New("invalid json value")
+	ErrInvalidJSONPath = errors.New("invalid json path")
+	ErrJSONPathMissing = errors.New("json path does not exist")
+	ErrJSONNotNumber   = errors.New("json value is not a number")
+	ErrJSONNotFinite   = errors.New("json number would not be finite")
+)
+
+// jsonValue holds a decoded document: nil, bool, float64, string, []any or map[string]any.
+type jsonValue struct {
+	document any
+}
+
+func (*jsonValue) Type() ValueType {
+	return TypeJSON
+}
+
+// pathToken is a single step of a json path, either an object field or an array index.
+type pathToken struct {
+	field   string
+	index   int
+	isIndex bool
+}
+
+// position converts a possibly negative index into a valid position in an array of length.
+func (t pathToken) position(length int) (int, bool) {
+	index := t.index
+	if index < 0 {
+		index += length
+	}
+
+	return index, index >= 0 && index < length
+}
+
+// parseJSONPath parses paths like $, $.user.tags[0] or user.name into tokens.
+func parseJSONPath(path string) ([]pathToken, error) {
+	rest := strings.TrimPrefix(path, "$")
+	if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+
+	var tokens []pathToken
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[") + 1
+			if end == 0 {
+				end = len(rest)
+			}
+
+			field := rest[1:end]
+			if field == "" {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidJSONPath, path)
+			}
+
+			tokens = append(tokens, pathToken{field: field})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidJSONPath, path)
+			}
+
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidJSONPath, path)
+			}
+
+			tokens = append(tokens, pathToken{index: index, isIndex: true})
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrInvalidJSONPath, path)
+		}
+	}
+
+	return tokens, nil
+}
+
+// updateFunc receives the current value at a path and returns its replacement.
+// Returning keep=false removes the value from its parent.
+type updateFunc func(current any, exists bool) (replacement any, keep bool, err error)
+
+// updateJSON applies update to the value at tokens below node and returns the updated node.
+// Missing intermediate values are an error, a missing last object field is passed to update.
+func updateJSON(node any, tokens []pathToken, update updateFunc) (any, error) {
+	token, last := tokens[0], len(tokens) == 1
+
+	switch typed := node.(type) {
+	case map[string]any:
+		if token.isIndex {
+			return nil, ErrJSONPathMissing
+		}
+
+		child, exists := typed[token.field]
+
+		if !last {
+			if !exists {
+				return nil, ErrJSONPathMissing
+			}
+
+			child, err := updateJSON(child, tokens[1:], update)
+			if err != nil {
+				return nil, err
+			}
+
+			typed[token.field] = child
+
+			return typed, nil
+		}
+
+		replacement, keep, err := update(child, exists)
+		if err != nil {
+			return nil, err
+		}
+
+		if keep {
+			typed[token.field] = replacement
+		} else {
+			delete(typed, token.field)
+		}
+
+		return typed, nil
+	case []any:
+		index, ok := token.position(len(typed))
+		if !token.isIndex || !ok {
+			return nil, ErrJSONPathMissing
+		}
+
+		if !last {
+			child, err := updateJSON(typed[index], tokens[1:], update)
+			if err != nil {
+				return nil, err
+			}
+
+			typed[index] = child
+
+			return typed, nil
+		}
+
+		replacement, keep, err := update(typed[index], true)
+		if err != nil {
+			return nil, err
+		}
+
+		if !keep {
+			return slices.Delete(typed, index, index+1), nil
+		}
+
+		typed[index] = replacement
+
+		return typed, nil
+	default:
+		return nil, ErrJSONPathMissing
+	}
+}
+
+func lookupJSON(node any, tokens []pathToken) (any, error) {
+	for _, token := range tokens {
+		switch typed := node.(type) {
+		case map[string]any:
+			child, exists := typed[token.field]
+			if token.isIndex || !exists {
+				return nil, ErrJSONPathMissing
+			}
+
+			node = child
+		case []any:
+			index, ok := token.position(len(typed))
+			if !token.isIndex || !ok {
+				return nil, ErrJSONPathMissing
+			}
+
+			node = typed[index]
+		default:
+			return nil, ErrJSONPathMissing
+		}
+	}
+
+	return node, nil
+}
+
+// JSONSet validates raw and stores it at path of the document at key.
+// A new key can only be created with the root path.
+func (e *Engine) JSONSet(key, path, raw string) error {
+	tokens, err := parseJSONPath(path)
+	if err != nil {
+		return err
+	}
+
+	var document any
+
+	if err = json.Unmarshal([]byte(raw), &document); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidJSON, err)
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	stored, ok, err := lookup[*jsonValue](e.storage, key)
+	if err != nil {
+		return err
+	}
+
+	if len(tokens) == 0 {
+		e.store(key, &jsonValue{document: document})
+
+		return nil
+	}
+
+	if !ok {
+		return ErrJSONPathMissing
+	}
+
+	updated, err := updateJSON(stored.document, tokens, func(any, bool) (any, bool, error) {
+		return document, true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	stored.document = updated
+
+	return nil
+}
+
+// JSONGet returns the compact encoding of the value at path, or an empty string for a missing key.
+func (e *Engine) JSONGet(key, path string) (string, error) {
+	tokens, err := parseJSONPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	stored, ok, err := lookup[*jsonValue](e.storage, key)
+	if err != nil || !ok {
+		return "", err
+	}
+
+	node, err := lookupJSON(stored.document, tokens)
+	if err != nil {
+		return "", err
+	}
+
+	encoded, err := json.Marshal(node)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode json value: %w", err)
+	}
+
+	return string(encoded), nil
+}
+
+// JSONDel removes the value at path and returns the number of removed values.
+// Deleting the root path removes the key.
+func (e *Engine) JSONDel(key, path string) (int, error) {
+	tokens, err := parseJSONPath(path)
+	if err != nil {
+		return 0, err
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	stored, ok, err := lookup[*jsonValue](e.storage, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	if len(tokens) == 0 {
+		e.remove(key)
+
+		return 1, nil
+	}
+
+	removed := 0
+
+	updated, err := updateJSON(stored.document, tokens, func(_ any, exists bool) (any, bool, error) {
+		if exists {
+			removed++
+		}
+
+		return nil, false, nil
+	})
+	if errors.Is(err, ErrJSONPathMissing) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	stored.document = updated
+
+	return removed, nil
+}
+
+// JSONNumIncrBy adds delta to the number at path and returns the new value.
+// It fails with ErrJSONNotFinite if the sum overflows or delta is not finite.
+func (e *Engine) JSONNumIncrBy(key, path string, delta float64) (float64, error) {
+	tokens, err := parseJSONPath(path)
+	if err != nil {
+		return 0, err
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	stored, ok, err := lookup[*jsonValue](e.storage, key)
+	if err != nil {
+		return 0, err
+	}
+
+	if !ok {
+		return 0, ErrJSONPathMissing
+	}
+
+	var result float64
+
+	increment := func(current any, exists bool) (any, bool, error) {
+		number, isNumber := current.(float64)
+		if !exists || !isNumber {
+			return nil, false, ErrJSONNotNumber
+		}
+
+		result = number + delta
+		if math.IsInf(result, 0) || math.IsNaN(result) {
+			// json can not represent the result, keep the number as it is
+			return nil, false, ErrJSONNotFinite
+		}
+
+		return result, true, nil
+	}
+
+	if len(tokens) == 0 {
+		replacement, _, err := increment(stored.document, true)
+		if err != nil {
+			return 0, err
+		}
+
+		stored.document = replacement
+
+		return result, nil
+	}
+
+	updated, err := updateJSON(stored.document, tokens, increment)
+	if err != nil {
+		return 0, err
+	}
+
+	stored.document = updated
+
+	return result, nil
+}
@@ -0,0 +1,199 @@
+package engine
+
+import "slices"
+
+// minListWaste is the number of popped slots at the front of a list that are
+// always kept for reuse by later pushes.
+const minListWaste = 16
+
+// listValue keeps the elements in items[head:], so that popping from and pushing to
+// the front reuse the slots before head instead of moving all elements.
+type listValue struct {
+	items []string
+	head  int
+}
+
+func newList(elements []string) *listValue {
+	return &listValue{items: elements}
+}
+
+func (*listValue) Type() ValueType {
+	return TypeList
+}
+
+func (l *listValue) elements() []string {
+	return l.items[l.head:]
+}
+
+func (l *listValue) len() int {
+	return len(l.items) - l.head
+}
+
+// pushFront prepends values one by one, so the last value becomes the first element.
+func (l *listValue) pushFront(values []string) {
+	if l.head < len(values) {
+		// leave as many free slots as there are elements, so prepending is amortized O(1)
+		free := len(values) + l.len()
+		items := make([]string, free+l.len())
+		copy(items[free:], l.elements())
+		l.items, l.head = items, free
+	}
+
+	for _, value := range values {
+		l.head--
+		l.items[l.head] = value
+	}
+}
+
+func (l *listValue) pushBack(values []string) {
+	l.items = append(l.items, values...)
+}
+
+func (l *listValue) popFront() string {
+	value := l.items[l.head]
+	l.items[l.head] = ""
+	l.head++
+
+	// drop the popped slots once they outnumber the elements twice
+	if l.head > minListWaste && l.head > 2*l.len() {
+		l.items, l.head = slices.Clone(l.elements()), 0
+	}
+
+	return value
+}
+
+func (l *listValue) popBack() string {
+	last := len(l.items) - 1
+	value := l.items[last]
+	l.items[last] = ""
+	l.items = l.items[:last]
+
+	return value
+}
+
+// LPush prepends values to the list at key and returns the new list length.
+func (e *Engine) LPush(key string, values []string) (int, error) {
+	return e.push(key, values, true)
+}
+
+// RPush appends values to the list at key and returns the new list length.
+func (e *Engine) RPush(key string, values []string) (int, error) {
+	return e.push(key, values, false)
+}
+
+func (e *Engine) push(key string, values []string, left bool) (int, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	list, ok, err := lookup[*listValue](e.storage, key)
+	if err != nil {
+		return 0, err
+	}
+
+	if !ok {
+		list = newList(nil)
+		e.store(key, list)
+	}
+
+	if left {
+		list.pushFront(values)
+	} else {
+		list.pushBack(values)
+	}
+
+	e.notifyWaiters(key)
+
+	return list.len(), nil
+}
+
+// LPop removes and returns the first element of the list at key.
+// The second result is false if the list does not exist.
+func (e *Engine) LPop(key string) (string, bool, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.pop(key, true)
+}
+
+// RPop removes and returns the last element of the list at key.
+// The second result is false if the list does not exist.
+func (e *Engine) RPop(key string) (string, bool, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.pop(key, false)
+}
+
+func (e *Engine) pop(key string, left bool) (string, bool, error) {
+	list, ok, err := lookup[*listValue](e.storage, key)
+	if err != nil || !ok {
+		return "", false, err
+	}
+
+	var value string
+
+	if left {
+		value = list.popFront()
+	} else {
+		value = list.popBack()
+	}
+
+	if list.len() == 0 {
+		e.remove(key)
+	}
+
+	return value, true, nil
+}
+
+func (e *Engine) LLen(key string) (int, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	list, ok, err := lookup[*listValue](e.storage, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	return list.len(), nil
+}
+
+// LRange returns the elements between start and stop inclusive.
+// Negative indexes count from the end of the list.
+func (e *Engine) LRange(key string, start, stop int) ([]string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	list, ok, err := lookup[*listValue](e.storage, key)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	elements := list.elements()
+
+	start, stop, ok = normalizeRange(start, stop, len(elements))
+	if !ok {
+		return nil, nil
+	}
+
+	return slices.Clone(elements[start : stop+1]), nil
+}
+
+// normalizeRange converts inclusive, possibly negative, indexes into valid slice bounds.
+func normalizeRange(start, stop, length int) (int, int, bool) {
+	if start < 0 {
+		start += length
+	}
+
+	if stop < 0 {
+		stop += length
+	}
+
+	start = max(start, 0)
+	stop = min(stop, length-1)
+
+	if start > stop {
+		return 0, 0, false
+	}
+
+	return start, stop, true
+}
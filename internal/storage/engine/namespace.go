@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"maps"
+	"slices"
+)
+
+const DefaultNamespace = "0"
+
+// Key names a key of a namespace.
+type Key struct {
+	Namespace string
+	Name      string
+}
+
+func (e *Engine) newNamespace(name string) *Engine {
+	namespace := e.emptyNamespace()
+	e.namespaces[name] = namespace
+
+	return namespace
+}
+
+// emptyNamespace returns a namespace of the database that is not registered under a name.
+func (e *Engine) emptyNamespace() *Engine {
+	return &Engine{
+		mutex:      e.mutex,
+		storage:    make(map[string]value),
+		slots:      make(map[int]map[string]struct{}),
+		waiters:    make(map[string][]chan struct{}),
+		versions:   make(map[string][]Version),
+		namespaces: e.namespaces,
+		history:    e.history,
+	}
+}
+
+// Namespace returns the isolated keyspace called name, creating it on first use.
+func (e *Engine) Namespace(name string) *Engine {
+	e.mutex.RLock()
+	namespace, ok := e.namespaces[name]
+	e.mutex.RUnlock()
+
+	if ok {
+		return namespace
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if namespace, ok = e.namespaces[name]; ok {
+		return namespace
+	}
+
+	return e.newNamespace(name)
+}
+
+// LookupNamespace returns the isolated keyspace called name without creating it,
+// an unknown name is an empty keyspace that is not kept, so it must only be read.
+func (e *Engine) LookupNamespace(name string) *Engine {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if namespace, ok := e.namespaces[name]; ok {
+		return namespace
+	}
+
+	return e.emptyNamespace()
+}
+
+// NamespaceNames returns the sorted names of all namespaces used so far.
+func (e *Engine) NamespaceNames() []string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return slices.Sorted(maps.Keys(e.namespaces))
+}
+
+// DeleteKeys removes keys of any namespaces under one lock, so that no reader sees some
+// of them deleted only, and returns the keys that existed.
+func (e *Engine) DeleteKeys(keys []Key) []Key {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	deleted := make([]Key, 0, len(keys))
+
+	for _, key := range keys {
+		namespace, ok := e.namespaces[key.Namespace]
+		if !ok {
+			continue
+		}
+
+		if _, ok = namespace.storage[key.Name]; ok {
+			namespace.remove(key.Name)
+			deleted = append(deleted, key)
+		}
+	}
+
+	return deleted
+}
+
+// Replace swaps the keys of all namespaces for the keys of loaded, a database restored
+// from a snapshot taken at revision. The keys are swapped under one lock, so readers see
+// either the old or the new keys, and the history starts over at revision. The keys of loaded
+// are taken over, it must not be used afterwards.
+func (e *Engine) Replace(loaded *Engine, revision int64) {
+	loaded.mutex.RLock()
+	defer loaded.mutex.RUnlock()
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, namespace := range e.namespaces {
+		namespace.storage = make(map[string]value)
+		namespace.slots = make(map[int]map[string]struct{})
+		namespace.versions = make(map[string][]Version)
+	}
+
+	for name, source := range loaded.namespaces {
+		namespace, ok := e.namespaces[name]
+		if !ok {
+			namespace = e.newNamespace(name)
+		}
+
+		namespace.storage, namespace.slots = source.storage, source.slots
+
+		for key := range namespace.storage {
+			namespace.recordVersion(key, revision)
+			namespace.notifyWaiters(key)
+		}
+	}
+
+	e.history.latest, e.history.compacted = revision, revision
+}
+
+// Flush removes all keys of the namespace and returns their number.
+func (e *Engine) Flush() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	count := len(e.storage)
+	clear(e.storage)
+	clear(e.slots)
+
+	return count
+}
+
+// KeyCount returns the number of keys in the namespace.
+func (e *Engine) KeyCount() int {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return len(e.storage)
+}
@@ -0,0 +1,310 @@
+package engine
+
+import (
+	"cmp"
+	"container/heap"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidMessageID = errors.New("invalid message id")
+
+// QueueMessage is a message delivered from a queue. Its ID is rendered as "queue/seq",
+// so that it can be acknowledged without naming the queue.
+type QueueMessage struct {
+	ID         string
+	Body       string
+	Deliveries int
+}
+
+// ParseMessageID returns the queue and the sequence number of a message id.
+func ParseMessageID(id string) (string, uint64, error) {
+	separator := strings.LastIndex(id, "/")
+	if separator <= 0 {
+		return "", 0, fmt.Errorf("%w: %s", ErrInvalidMessageID, id)
+	}
+
+	seq, err := strconv.ParseUint(id[separator+1:], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("%w: %s", ErrInvalidMessageID, id)
+	}
+
+	return id[:separator], seq, nil
+}
+
+// QueuePop is the result of popping a queue. Message is set only if a message was
+// delivered, DeadLettered reports whether expired messages were moved to the dead letter queue.
+type QueuePop struct {
+	Message      QueueMessage
+	Delivered    bool
+	DeadLettered bool
+}
+
+func messageID(key string, seq uint64) string {
+	return key + "/" + strconv.FormatUint(seq, 10)
+}
+
+type queueMessage struct {
+	seq        uint64
+	body       string
+	deliveries int
+	visibleAt  time.Time
+	index      int // position in the visibility heap while delivered
+}
+
+// visibilityHeap orders delivered messages by the time they become visible again.
+type visibilityHeap []*queueMessage
+
+func (h visibilityHeap) Len() int {
+	return len(h)
+}
+
+func (h visibilityHeap) Less(i, j int) bool {
+	return h[i].visibleAt.Before(h[j].visibleAt)
+}
+
+func (h visibilityHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+
+func (h *visibilityHeap) Push(x any) {
+	message, _ := x.(*queueMessage)
+	message.index = len(*h)
+	*h = append(*h, message)
+}
+
+func (h *visibilityHeap) Pop() any {
+	old := *h
+	message := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+
+	return message
+}
+
+// queueValue keeps messages ready for delivery ordered by their sequence numbers
+// and delivered messages until they are acknowledged or become visible again.
+type queueValue struct {
+	lastSeq  uint64
+	ready    []*queueMessage
+	inflight map[uint64]*queueMessage
+	visible  visibilityHeap // the delivered messages ordered by the time they become visible
+
+	// messages delivered maxDeliveries times are moved to the deadLetter queue,
+	// zero maxDeliveries redelivers them forever
+	maxDeliveries int
+	deadLetter    string
+}
+
+func (*queueValue) Type() ValueType {
+	return TypeQueue
+}
+
+func newQueue() *queueValue {
+	return &queueValue{inflight: make(map[uint64]*queueMessage)}
+}
+
+func (q *queueValue) push(body string) uint64 {
+	q.lastSeq++
+	q.ready = append(q.ready, &queueMessage{seq: q.lastSeq, body: body})
+
+	return q.lastSeq
+}
+
+// deliver hides message from consumers until visibleAt.
+func (q *queueValue) deliver(message *queueMessage, visibleAt time.Time) {
+	message.visibleAt = visibleAt
+	q.inflight[message.seq] = message
+	heap.Push(&q.visible, message)
+}
+
+// settle removes a delivered message from the delivered ones.
+func (q *queueValue) settle(message *queueMessage) {
+	delete(q.inflight, message.seq)
+	heap.Remove(&q.visible, message.index)
+}
+
+// expired removes and returns the delivered messages whose visibility ran out at now.
+func (q *queueValue) expired(now time.Time) []*queueMessage {
+	var messages []*queueMessage
+
+	for len(q.visible) > 0 && !now.Before(q.visible[0].visibleAt) {
+		message, _ := heap.Pop(&q.visible).(*queueMessage)
+		delete(q.inflight, message.seq)
+		messages = append(messages, message)
+	}
+
+	return messages
+}
+
+func (q *queueValue) makeReady(message *queueMessage) {
+	index, _ := slices.BinarySearchFunc(q.ready, message.seq, func(m *queueMessage, seq uint64) int {
+		return cmp.Compare(m.seq, seq)
+	})
+
+	q.ready = slices.Insert(q.ready, index, message)
+}
+
+// QCreate creates the queue at key or changes its dead letter settings.
+func (e *Engine) QCreate(key string, maxDeliveries int, deadLetter string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	queue, err := e.queue(key)
+	if err != nil {
+		return err
+	}
+
+	queue.maxDeliveries, queue.deadLetter = maxDeliveries, deadLetter
+
+	return nil
+}
+
+// QDeadLetter returns the dead letter queue of the queue at key, if it has one.
+func (e *Engine) QDeadLetter(key string) (string, bool, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	queue, ok, err := lookup[*queueValue](e.storage, key)
+	if err != nil || !ok || queue.maxDeliveries == 0 {
+		return "", false, err
+	}
+
+	return queue.deadLetter, true, nil
+}
+
+// QPush appends a message to the queue at key and returns its id.
+func (e *Engine) QPush(key, body string) (string, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	queue, err := e.queue(key)
+	if err != nil {
+		return "", err
+	}
+
+	return messageID(key, queue.push(body)), nil
+}
+
+// QPop delivers the oldest ready message of the queue at key and hides it from
+// other consumers for visibility. Unacknowledged messages whose visibility ran out
+// become ready again unless they were delivered too many times and are dead-lettered.
+func (e *Engine) QPop(key string, visibility time.Duration, now time.Time) (QueuePop, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var pop QueuePop
+
+	queue, ok, err := lookup[*queueValue](e.storage, key)
+	if err != nil || !ok {
+		return pop, err
+	}
+
+	for _, message := range queue.expired(now) {
+		if e.redeliver(queue, message) {
+			pop.DeadLettered = true
+		}
+	}
+
+	if len(queue.ready) == 0 {
+		return pop, nil
+	}
+
+	message := queue.ready[0]
+	queue.ready = queue.ready[1:]
+
+	message.deliveries++
+	queue.deliver(message, now.Add(visibility))
+
+	pop.Message = QueueMessage{ID: messageID(key, message.seq), Body: message.body, Deliveries: message.deliveries}
+	pop.Delivered = true
+
+	return pop, nil
+}
+
+// QAck removes a delivered message whose visibility has not run out yet.
+func (e *Engine) QAck(id string, now time.Time) (bool, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	queue, message, err := e.delivered(id, now)
+	if err != nil || message == nil {
+		return false, err
+	}
+
+	queue.settle(message)
+
+	return true, nil
+}
+
+// QNack makes a delivered message whose visibility has not run out yet ready again.
+// The second result reports whether the message was moved to the dead letter queue instead.
+func (e *Engine) QNack(id string, now time.Time) (bool, bool, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	queue, message, err := e.delivered(id, now)
+	if err != nil || message == nil {
+		return false, false, err
+	}
+
+	queue.settle(message)
+
+	return true, e.redeliver(queue, message), nil
+}
+
+// redeliver makes message ready again or moves it to the dead letter queue and reports
+// whether it was dead-lettered. A dead letter key holding another type keeps the message in its queue.
+func (e *Engine) redeliver(queue *queueValue, message *queueMessage) bool {
+	if queue.maxDeliveries > 0 && message.deliveries >= queue.maxDeliveries {
+		deadLetter, err := e.queue(queue.deadLetter)
+		if err == nil {
+			deadLetter.push(message.body)
+
+			return true
+		}
+	}
+
+	queue.makeReady(message)
+
+	return false
+}
+
+func (e *Engine) delivered(id string, now time.Time) (*queueValue, *queueMessage, error) {
+	key, seq, err := ParseMessageID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	queue, ok, err := lookup[*queueValue](e.storage, key)
+	if err != nil || !ok {
+		return nil, nil, err
+	}
+
+	message, ok := queue.inflight[seq]
+	if !ok || !now.Before(message.visibleAt) {
+		return queue, nil, nil
+	}
+
+	return queue, message, nil
+}
+
+// queue returns the queue at key, creating it if it does not exist.
+func (e *Engine) queue(key string) (*queueValue, error) {
+	queue, ok, err := lookup[*queueValue](e.storage, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		queue = newQueue()
+		e.store(key, queue)
+	}
+
+	return queue, nil
+}
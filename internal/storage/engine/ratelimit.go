@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"math"
+	"time"
+)
+
+// RateLimitResult tells whether a request is allowed and the capacity left after it.
+// A denied request may be retried after RetryAfter, a negative RetryAfter means
+// that it is never allowed, e.g. because it costs more than the capacity.
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  float64
+	RetryAfter time.Duration
+}
+
+// rateLimiter is a rate limiter that returns to the state of a new one once idle long
+// enough, it can be deleted then without changing the outcome of further requests.
+type rateLimiter interface {
+	idle(now time.Time) bool
+}
+
+type tokenBucketValue struct {
+	tokens   float64
+	refilled time.Time
+	fullAt   time.Time // zero if the bucket is never refilled
+}
+
+func (*tokenBucketValue) Type() ValueType {
+	return TypeTokenBucket
+}
+
+func (b *tokenBucketValue) idle(now time.Time) bool {
+	return !b.fullAt.IsZero() && !now.Before(b.fullAt)
+}
+
+// refillUntil records when the bucket is full again, never if it is not refilled.
+func (b *tokenBucketValue) refillUntil(now time.Time, capacity, perSecond float64) {
+	switch deficit := capacity - b.tokens; {
+	case deficit <= 0:
+		b.fullAt = now
+	case perSecond > 0:
+		b.fullAt = now.Add(seconds(deficit / perSecond))
+	default:
+		b.fullAt = time.Time{}
+	}
+}
+
+// slidingWindowValue approximates the number of requests in the window ending now
+// by weighting the count of the previous fixed window by its part still in the window.
+type slidingWindowValue struct {
+	start    time.Time
+	previous float64
+	current  float64
+	emptyAt  time.Time // no request counts in the sliding window anymore
+}
+
+func (*slidingWindowValue) Type() ValueType {
+	return TypeSlidingWindow
+}
+
+func (w *slidingWindowValue) idle(now time.Time) bool {
+	return !now.Before(w.emptyAt)
+}
+
+// TokenBucket takes cost tokens from the bucket at key holding up to capacity tokens
+// and refilled with perSecond tokens a second. A new bucket starts full, a full bucket
+// is dropped by EnforceRetention.
+func (e *Engine) TokenBucket(key string, capacity, perSecond, cost float64, now time.Time) (RateLimitResult, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	bucket, ok, err := lookup[*tokenBucketValue](e.storage, key)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	if !ok {
+		bucket = &tokenBucketValue{tokens: capacity, refilled: now, fullAt: now}
+		e.store(key, bucket)
+	}
+
+	if elapsed := now.Sub(bucket.refilled); elapsed > 0 {
+		bucket.tokens += elapsed.Seconds() * perSecond
+		bucket.refilled = now
+	}
+
+	bucket.tokens = min(bucket.tokens, capacity)
+
+	if bucket.tokens >= cost {
+		bucket.tokens -= cost
+		bucket.refillUntil(now, capacity, perSecond)
+
+		return RateLimitResult{Allowed: true, Remaining: bucket.tokens}, nil
+	}
+
+	result := RateLimitResult{Remaining: bucket.tokens, RetryAfter: -1}
+	if cost <= capacity && perSecond > 0 {
+		result.RetryAfter = seconds((cost - bucket.tokens) / perSecond)
+	}
+
+	return result, nil
+}
+
+// SlidingWindow allows requests at key while the total cost of requests in the sliding
+// window does not exceed limit. A window without requests is dropped by EnforceRetention.
+func (e *Engine) SlidingWindow(key string, limit float64, window time.Duration, cost float64, now time.Time) (RateLimitResult, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	counter, ok, err := lookup[*slidingWindowValue](e.storage, key)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	if !ok {
+		counter = &slidingWindowValue{start: now}
+		e.store(key, counter)
+	}
+
+	if passed := now.Sub(counter.start); passed >= window {
+		windows := passed / window
+
+		counter.previous = 0
+		if windows == 1 {
+			counter.previous = counter.current
+		}
+
+		counter.current = 0
+		counter.start = counter.start.Add(windows * window)
+	}
+
+	elapsed := now.Sub(counter.start)
+	used := counter.previous*(1-elapsed.Seconds()/window.Seconds()) + counter.current
+
+	if used+cost <= limit {
+		counter.current += cost
+		// the current window is the previous one during the next window
+		counter.emptyAt = counter.start.Add(2 * window) //nolint: mnd // current and next window
+
+		return RateLimitResult{Allowed: true, Remaining: limit - used - cost}, nil
+	}
+
+	result := RateLimitResult{Remaining: max(limit-used, 0), RetryAfter: -1}
+	if cost <= limit {
+		result.RetryAfter = retryWindow(counter, limit, window, cost, elapsed)
+	}
+
+	return result, nil
+}
+
+// retryWindow returns how long the sliding window has to move to fit cost.
+func retryWindow(counter *slidingWindowValue, limit float64, window time.Duration, cost float64, elapsed time.Duration) time.Duration {
+	// the weight of the previous window has to drop enough while in the current window
+	if counter.current+cost <= limit {
+		return seconds(window.Seconds()*(1-(limit-counter.current-cost)/counter.previous)) - elapsed
+	}
+
+	// otherwise the current window becomes the previous one
+	remaining := window - elapsed
+
+	return remaining + max(seconds(window.Seconds()*(1-(limit-cost)/counter.current)), 0)
+}
+
+func seconds(value float64) time.Duration {
+	return time.Duration(math.Ceil(value * float64(time.Second)))
+}
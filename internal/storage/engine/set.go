@@ -0,0 +1,160 @@
+package engine
+
+import (
+	"maps"
+	"slices"
+)
+
+type setValue map[string]struct{}
+
+func (setValue) Type() ValueType {
+	return TypeSet
+}
+
+// SAdd adds members to the set at key and returns the number of added members.
+func (e *Engine) SAdd(key string, members []string) (int, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	set, ok, err := lookup[setValue](e.storage, key)
+	if err != nil {
+		return 0, err
+	}
+
+	if !ok {
+		set = make(setValue, len(members))
+		e.store(key, set)
+	}
+
+	added := 0
+
+	for _, member := range members {
+		if _, exists := set[member]; !exists {
+			set[member] = struct{}{}
+			added++
+		}
+	}
+
+	return added, nil
+}
+
+// SRem removes members from the set at key and returns the number of removed members.
+func (e *Engine) SRem(key string, members []string) (int, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	set, ok, err := lookup[setValue](e.storage, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	removed := 0
+
+	for _, member := range members {
+		if _, exists := set[member]; exists {
+			delete(set, member)
+
+			removed++
+		}
+	}
+
+	if len(set) == 0 {
+		e.remove(key)
+	}
+
+	return removed, nil
+}
+
+// SMembers returns the members of the set at key in sorted order.
+func (e *Engine) SMembers(key string) ([]string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	set, _, err := lookup[setValue](e.storage, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.Sorted(maps.Keys(set)), nil
+}
+
+func (e *Engine) SIsMember(key, member string) (bool, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	set, _, err := lookup[setValue](e.storage, key)
+	if err != nil {
+		return false, err
+	}
+
+	_, exists := set[member]
+
+	return exists, nil
+}
+
+// SInter returns the sorted intersection of the sets at keys.
+func (e *Engine) SInter(keys []string) ([]string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	sets, err := e.sets(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var members []string
+
+	for member := range sets[0] {
+		inAll := true
+
+		for _, set := range sets[1:] {
+			if _, exists := set[member]; !exists {
+				inAll = false
+
+				break
+			}
+		}
+
+		if inAll {
+			members = append(members, member)
+		}
+	}
+
+	slices.Sort(members)
+
+	return members, nil
+}
+
+// SUnion returns the sorted union of the sets at keys.
+func (e *Engine) SUnion(keys []string) ([]string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	sets, err := e.sets(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	union := make(setValue)
+	for _, set := range sets {
+		maps.Copy(union, set)
+	}
+
+	return slices.Sorted(maps.Keys(union)), nil
+}
+
+// sets looks up the sets at keys, missing keys are treated as empty sets.
+func (e *Engine) sets(keys []string) ([]setValue, error) {
+	sets := make([]setValue, 0, len(keys))
+
+	for _, key := range keys {
+		set, _, err := lookup[setValue](e.storage, key)
+		if err != nil {
+			return nil, err
+		}
+
+		sets = append(sets, set)
+	}
+
+	return sets, nil
+}
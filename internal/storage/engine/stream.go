@@ -0,0 +1,425 @@
+package engine
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidStreamID = errors.New("invalid stream id")
+	ErrNoSuchKey       = errors.New("no such key")
+	ErrNoSuchGroup     = errors.New("no such consumer group")
+	ErrGroupExists     = errors.New("consumer group already exists")
+)
+
+var (
+	MinStreamID = StreamID{Ms: 0, Seq: 0}
+	MaxStreamID = StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+)
+
+// StreamID identifies a stream entry by its creation time and a sequence number
+// within the same millisecond, it is rendered as "ms-seq".
+type StreamID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+// ParseStreamID parses "ms-seq" or "ms", a missing sequence is replaced by defaultSeq.
+func ParseStreamID(text string, defaultSeq uint64) (StreamID, error) {
+	msText, seqText, hasSeq := strings.Cut(text, "-")
+
+	ms, err := strconv.ParseUint(msText, 10, 64)
+	if err != nil {
+		return StreamID{}, fmt.Errorf("%w: %s", ErrInvalidStreamID, text)
+	}
+
+	if !hasSeq {
+		return StreamID{Ms: ms, Seq: defaultSeq}, nil
+	}
+
+	seq, err := strconv.ParseUint(seqText, 10, 64)
+	if err != nil {
+		return StreamID{}, fmt.Errorf("%w: %s", ErrInvalidStreamID, text)
+	}
+
+	return StreamID{Ms: ms, Seq: seq}, nil
+}
+
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+func (id StreamID) Compare(other StreamID) int {
+	if c := cmp.Compare(id.Ms, other.Ms); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(id.Seq, other.Seq)
+}
+
+type StreamEntry struct {
+	ID    StreamID
+	Value string
+}
+
+// StreamRead holds entries read from a single stream.
+type StreamRead struct {
+	Key     string
+	Entries []StreamEntry
+}
+
+// StreamReadOptions limits the number of returned entries, a non-positive Count means all.
+// With Block set a read waits up to Timeout for new entries, a zero Timeout waits forever.
+type StreamReadOptions struct {
+	Count   int
+	Block   bool
+	Timeout time.Duration
+}
+
+type PendingEntry struct {
+	ID          StreamID
+	Consumer    string
+	Deliveries  int
+	DeliveredAt time.Time
+}
+
+type consumerGroup struct {
+	lastDelivered StreamID
+	pending       map[StreamID]*PendingEntry
+}
+
+type streamValue struct {
+	entries []StreamEntry
+	lastID  StreamID
+	groups  map[string]*consumerGroup
+}
+
+func (*streamValue) Type() ValueType {
+	return TypeStream
+}
+
+func newStream() *streamValue {
+	return &streamValue{groups: make(map[string]*consumerGroup)}
+}
+
+// after returns up to count entries with ids greater than id, a non-positive count means all.
+func (s *streamValue) after(id StreamID, count int) []StreamEntry {
+	start, found := slices.BinarySearchFunc(s.entries, id, func(entry StreamEntry, id StreamID) int {
+		return entry.ID.Compare(id)
+	})
+	if found {
+		start++
+	}
+
+	entries := s.entries[start:]
+	if count > 0 && len(entries) > count {
+		entries = entries[:count]
+	}
+
+	return slices.Clone(entries)
+}
+
+func (s *streamValue) entry(id StreamID) (StreamEntry, bool) {
+	index, found := slices.BinarySearchFunc(s.entries, id, func(entry StreamEntry, id StreamID) int {
+		return entry.ID.Compare(id)
+	})
+	if !found {
+		return StreamEntry{}, false
+	}
+
+	return s.entries[index], true
+}
+
+// XAdd appends value to the stream at key and returns the id of the new entry.
+// Ids are derived from now and always grow, even if the clock goes backwards.
+func (e *Engine) XAdd(key, value string, now time.Time) (StreamID, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	stream, ok, err := lookup[*streamValue](e.storage, key)
+	if err != nil {
+		return StreamID{}, err
+	}
+
+	if !ok {
+		stream = newStream()
+		e.store(key, stream)
+	}
+
+	id := StreamID{Ms: uint64(now.UnixMilli()), Seq: 0} //nolint: gosec // unix time is positive
+	if id.Compare(stream.lastID) <= 0 {
+		id = StreamID{Ms: stream.lastID.Ms, Seq: stream.lastID.Seq + 1}
+	}
+
+	stream.entries = append(stream.entries, StreamEntry{ID: id, Value: value})
+	stream.lastID = id
+	e.notifyWaiters(key)
+
+	return id, nil
+}
+
+// XRange returns up to count entries with ids between start and end inclusive,
+// a non-positive count means all entries.
+func (e *Engine) XRange(key string, start, end StreamID, count int) ([]StreamEntry, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	stream, ok, err := lookup[*streamValue](e.storage, key)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	var entries []StreamEntry
+
+	for _, entry := range stream.entries {
+		if entry.ID.Compare(start) < 0 {
+			continue
+		}
+
+		if entry.ID.Compare(end) > 0 || (count > 0 && len(entries) == count) {
+			break
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// XLastID returns the id of the last entry added to the stream at key.
+func (e *Engine) XLastID(key string) (StreamID, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	stream, _, err := lookup[*streamValue](e.storage, key)
+	if err != nil || stream == nil {
+		return StreamID{}, err
+	}
+
+	return stream.lastID, nil
+}
+
+// XRead returns entries added after ids[i] to the stream at keys[i].
+func (e *Engine) XRead(ctx context.Context, keys []string, ids []StreamID, opts StreamReadOptions) ([]StreamRead, error) {
+	var reads []StreamRead
+
+	try := func() (bool, error) {
+		for i, key := range keys {
+			stream, _, err := lookup[*streamValue](e.storage, key)
+			if err != nil {
+				return false, err
+			}
+
+			if stream == nil {
+				continue
+			}
+
+			if entries := stream.after(ids[i], opts.Count); len(entries) > 0 {
+				reads = append(reads, StreamRead{Key: key, Entries: entries})
+			}
+		}
+
+		return len(reads) > 0, nil
+	}
+
+	if opts.Block {
+		return reads, e.await(ctx, keys, opts.Timeout, try)
+	}
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	_, err := try()
+
+	return reads, err
+}
+
+// XGroupCreate creates a consumer group that delivers entries after id.
+// With mkStream a missing stream is created empty.
+func (e *Engine) XGroupCreate(key, group string, id StreamID, latest, mkStream bool) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	stream, ok, err := lookup[*streamValue](e.storage, key)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		if !mkStream {
+			return fmt.Errorf("%w: %s", ErrNoSuchKey, key)
+		}
+
+		stream = newStream()
+		e.store(key, stream)
+	}
+
+	if _, exists := stream.groups[group]; exists {
+		return fmt.Errorf("%w: %s", ErrGroupExists, group)
+	}
+
+	if latest {
+		id = stream.lastID
+	}
+
+	stream.groups[group] = &consumerGroup{
+		lastDelivered: id,
+		pending:       make(map[StreamID]*PendingEntry),
+	}
+
+	return nil
+}
+
+// XReadGroup reads entries for consumer of group. Without an id (nil) it delivers new
+// entries, recording them as pending until acknowledged, and may block like XRead.
+// With an id it returns entries already pending for consumer after that id.
+func (e *Engine) XReadGroup(
+	ctx context.Context,
+	key, group, consumer string,
+	id *StreamID,
+	opts StreamReadOptions,
+	now time.Time,
+) ([]StreamEntry, error) {
+	var entries []StreamEntry
+
+	try := func() (bool, error) {
+		stream, consumerGroup, err := e.group(key, group)
+		if err != nil {
+			return false, err
+		}
+
+		if id != nil {
+			entries = consumerGroup.pendingOf(stream, consumer, *id, opts.Count, now)
+
+			return true, nil
+		}
+
+		entries = stream.after(consumerGroup.lastDelivered, opts.Count)
+		for _, entry := range entries {
+			consumerGroup.pending[entry.ID] = &PendingEntry{
+				ID:          entry.ID,
+				Consumer:    consumer,
+				Deliveries:  1,
+				DeliveredAt: now,
+			}
+			consumerGroup.lastDelivered = entry.ID
+		}
+
+		return len(entries) > 0, nil
+	}
+
+	if opts.Block && id == nil {
+		return entries, e.await(ctx, []string{key}, opts.Timeout, try)
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	_, err := try()
+
+	return entries, err
+}
+
+// pendingOf redelivers the entries pending for consumer after id.
+func (g *consumerGroup) pendingOf(
+	stream *streamValue, consumer string, id StreamID, count int, now time.Time,
+) []StreamEntry {
+	var entries []StreamEntry
+
+	for _, pending := range g.sortedPending() {
+		if pending.Consumer != consumer || pending.ID.Compare(id) <= 0 {
+			continue
+		}
+
+		if count > 0 && len(entries) == count {
+			break
+		}
+
+		if entry, ok := stream.entry(pending.ID); ok {
+			pending.Deliveries++
+			pending.DeliveredAt = now
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+func (g *consumerGroup) sortedPending() []*PendingEntry {
+	pending := make([]*PendingEntry, 0, len(g.pending))
+	for _, entry := range g.pending {
+		pending = append(pending, entry)
+	}
+
+	slices.SortFunc(pending, func(a, b *PendingEntry) int {
+		return a.ID.Compare(b.ID)
+	})
+
+	return pending
+}
+
+// XAck acknowledges pending entries of group and returns the number of acknowledged entries.
+func (e *Engine) XAck(key, group string, ids []StreamID) (int, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	_, consumerGroup, err := e.group(key, group)
+	if err != nil {
+		return 0, err
+	}
+
+	acknowledged := 0
+
+	for _, id := range ids {
+		if _, ok := consumerGroup.pending[id]; ok {
+			delete(consumerGroup.pending, id)
+
+			acknowledged++
+		}
+	}
+
+	return acknowledged, nil
+}
+
+// XPending returns entries of group delivered but not yet acknowledged, ordered by id.
+func (e *Engine) XPending(key, group string) ([]PendingEntry, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	_, consumerGroup, err := e.group(key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]PendingEntry, 0, len(consumerGroup.pending))
+	for _, entry := range consumerGroup.sortedPending() {
+		pending = append(pending, *entry)
+	}
+
+	return pending, nil
+}
+
+func (e *Engine) group(key, group string) (*streamValue, *consumerGroup, error) {
+	stream, ok, err := lookup[*streamValue](e.storage, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNoSuchKey, key)
+	}
+
+	consumerGroup, ok := stream.groups[group]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNoSuchGroup, group)
+	}
+
+	return stream, consumerGroup, nil
+}
@@ -0,0 +1,253 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	ErrTSSampleTooOld       = errors.New("sample timestamp must be greater than the last one of the series")
+	ErrTSExists             = errors.New("time series already exists")
+	ErrTSInvalidAggregation = errors.New("invalid aggregation")
+)
+
+type AggregationType string
+
+const (
+	AggregationNone AggregationType = ""
+	AggregationAvg  AggregationType = "avg"
+	AggregationMin  AggregationType = "min"
+	AggregationMax  AggregationType = "max"
+	AggregationSum  AggregationType = "sum"
+)
+
+type Sample struct {
+	Timestamp int64
+	Value     float64
+}
+
+// Aggregation downsamples a range into buckets of Bucket milliseconds,
+// each bucket is reported at its start timestamp.
+type Aggregation struct {
+	Type   AggregationType
+	Bucket int64
+}
+
+// timeSeriesValue keeps samples in compressed chunks ordered by time. Samples older
+// than retention relative to the latest one are hidden from reads and dropped in the
+// background a chunk at a time. A zero retention keeps samples forever.
+type timeSeriesValue struct {
+	retention time.Duration
+	chunks    []*tsChunk
+}
+
+func (*timeSeriesValue) Type() ValueType {
+	return TypeTimeSeries
+}
+
+func (ts *timeSeriesValue) add(sample Sample) error {
+	if len(ts.chunks) == 0 || ts.chunks[len(ts.chunks)-1].full() {
+		if len(ts.chunks) > 0 && sample.Timestamp <= ts.chunks[len(ts.chunks)-1].last {
+			return ErrTSSampleTooOld
+		}
+
+		ts.chunks = append(ts.chunks, &tsChunk{})
+	}
+
+	chunk := ts.chunks[len(ts.chunks)-1]
+	if chunk.count > 0 && sample.Timestamp <= chunk.last {
+		return ErrTSSampleTooOld
+	}
+
+	chunk.append(sample)
+
+	return nil
+}
+
+// cutoff returns the oldest timestamp still within retention.
+func (ts *timeSeriesValue) cutoff() int64 {
+	if ts.retention <= 0 || len(ts.chunks) == 0 {
+		return math.MinInt64
+	}
+
+	return ts.chunks[len(ts.chunks)-1].last - ts.retention.Milliseconds()
+}
+
+// trim drops chunks with all samples outside retention.
+func (ts *timeSeriesValue) trim() {
+	cutoff := ts.cutoff()
+
+	dropped := 0
+	for dropped < len(ts.chunks)-1 && ts.chunks[dropped].last < cutoff {
+		dropped++
+	}
+
+	ts.chunks = ts.chunks[dropped:]
+}
+
+func (ts *timeSeriesValue) samples(from, to int64) []Sample {
+	from = max(from, ts.cutoff())
+
+	var samples []Sample
+
+	for _, chunk := range ts.chunks {
+		if chunk.last < from || chunk.first > to {
+			continue
+		}
+
+		for _, sample := range chunk.samples() {
+			if sample.Timestamp >= from && sample.Timestamp <= to {
+				samples = append(samples, sample)
+			}
+		}
+	}
+
+	return samples
+}
+
+// TSCreate creates an empty time series at key.
+func (e *Engine) TSCreate(key string, retention time.Duration) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if _, exists := e.storage[key]; exists {
+		return fmt.Errorf("%w: %s", ErrTSExists, key)
+	}
+
+	e.store(key, &timeSeriesValue{retention: retention})
+
+	return nil
+}
+
+// TSAdd appends sample to the time series at key, creating it with retention if needed.
+// Samples must be added in increasing timestamp order.
+func (e *Engine) TSAdd(key string, sample Sample, retention time.Duration) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	series, ok, err := lookup[*timeSeriesValue](e.storage, key)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		series = &timeSeriesValue{retention: retention}
+		e.store(key, series)
+	}
+
+	return series.add(sample)
+}
+
+// TSRange returns samples between from and to inclusive, optionally downsampled by aggregation.
+func (e *Engine) TSRange(key string, from, to int64, aggregation Aggregation) ([]Sample, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	series, ok, err := lookup[*timeSeriesValue](e.storage, key)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	samples := series.samples(from, to)
+	if aggregation.Type == AggregationNone {
+		return samples, nil
+	}
+
+	return aggregate(samples, aggregation)
+}
+
+// EnforceRetention drops samples that fell out of retention from time series of all namespaces,
+// deletes idle rate limiters and compacts key versions outside of the retained history window.
+func (e *Engine) EnforceRetention() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	now := time.Now()
+
+	for _, namespace := range e.namespaces {
+		for key, stored := range namespace.storage {
+			switch typed := stored.(type) {
+			case *timeSeriesValue:
+				typed.trim()
+			case rateLimiter:
+				if typed.idle(now) {
+					namespace.remove(key)
+				}
+			}
+		}
+	}
+
+	e.compactHistory()
+}
+
+// RunRetention enforces retention every interval until ctx is done.
+func (e *Engine) RunRetention(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.EnforceRetention()
+		}
+	}
+}
+
+func aggregate(samples []Sample, aggregation Aggregation) ([]Sample, error) {
+	switch aggregation.Type {
+	case AggregationAvg, AggregationMin, AggregationMax, AggregationSum:
+	case AggregationNone:
+		fallthrough
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrTSInvalidAggregation, aggregation.Type)
+	}
+
+	if aggregation.Bucket <= 0 {
+		return nil, fmt.Errorf("%w: bucket must be positive", ErrTSInvalidAggregation)
+	}
+
+	var (
+		buckets []Sample
+		counts  []int
+	)
+
+	for _, sample := range samples {
+		start := sample.Timestamp - sample.Timestamp%aggregation.Bucket
+		if start > sample.Timestamp {
+			start -= aggregation.Bucket
+		}
+
+		if len(buckets) == 0 || buckets[len(buckets)-1].Timestamp != start {
+			buckets = append(buckets, Sample{Timestamp: start, Value: sample.Value})
+			counts = append(counts, 1)
+
+			continue
+		}
+
+		bucket := &buckets[len(buckets)-1]
+		counts[len(counts)-1]++
+
+		switch aggregation.Type {
+		case AggregationAvg, AggregationSum:
+			bucket.Value += sample.Value
+		case AggregationMin:
+			bucket.Value = min(bucket.Value, sample.Value)
+		case AggregationMax:
+			bucket.Value = max(bucket.Value, sample.Value)
+		case AggregationNone:
+		}
+	}
+
+	if aggregation.Type == AggregationAvg {
+		for i := range buckets {
+			buckets[i].Value /= float64(counts[i])
+		}
+	}
+
+	return buckets, nil
+}
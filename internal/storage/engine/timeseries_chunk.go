@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"math"
+	"math/bits"
+)
+
+const tsChunkSamples = 128
+
+// tsChunk stores samples compressed the way Gorilla does: timestamps as delta of
+// deltas and values as xor with the previous value, both with variable bit lengths.
+// Regular scrape intervals and slowly changing values take a couple of bits per sample.
+type tsChunk struct {
+	stream    bitWriter
+	count     int
+	first     int64
+	last      int64
+	lastValue float64
+	lastDelta int64
+	leading   int
+	trailing  int
+}
+
+// dodBuckets lists the bit lengths of delta of delta encodings with their prefixes.
+var dodBuckets = []struct {
+	prefix     uint64
+	prefixBits int
+	valueBits  int
+}{
+	{prefix: 0b10, prefixBits: 2, valueBits: 7},
+	{prefix: 0b110, prefixBits: 3, valueBits: 9},
+	{prefix: 0b1110, prefixBits: 4, valueBits: 12},
+	{prefix: 0b1111, prefixBits: 4, valueBits: 64},
+}
+
+func (c *tsChunk) full() bool {
+	return c.count >= tsChunkSamples
+}
+
+func (c *tsChunk) append(sample Sample) {
+	if c.count == 0 {
+		c.stream.writeBits(uint64(sample.Timestamp), 64)       //nolint: gosec,mnd // raw bits
+		c.stream.writeBits(math.Float64bits(sample.Value), 64) //nolint: mnd // raw bits
+		c.first, c.last, c.lastValue, c.leading = sample.Timestamp, sample.Timestamp, sample.Value, -1
+		c.count++
+
+		return
+	}
+
+	delta := sample.Timestamp - c.last
+	c.writeDeltaOfDelta(delta - c.lastDelta)
+	c.writeValue(sample.Value)
+
+	c.last, c.lastDelta, c.lastValue = sample.Timestamp, delta, sample.Value
+	c.count++
+}
+
+func (c *tsChunk) writeDeltaOfDelta(dod int64) {
+	if dod == 0 {
+		c.stream.writeBit(false)
+
+		return
+	}
+
+	for _, bucket := range dodBuckets {
+		limit := int64(1) << (bucket.valueBits - 1)
+		if bucket.valueBits == 64 || (dod >= -limit && dod < limit) {
+			c.stream.writeBits(bucket.prefix, bucket.prefixBits)
+			c.stream.writeBits(uint64(dod), bucket.valueBits) //nolint: gosec // two's complement bits
+
+			return
+		}
+	}
+}
+
+func (c *tsChunk) writeValue(value float64) {
+	xor := math.Float64bits(value) ^ math.Float64bits(c.lastValue)
+	if xor == 0 {
+		c.stream.writeBit(false)
+
+		return
+	}
+
+	c.stream.writeBit(true)
+
+	leading := min(bits.LeadingZeros64(xor), 31) //nolint: mnd // leading zeros are stored in 5 bits
+	trailing := bits.TrailingZeros64(xor)
+
+	if c.leading >= 0 && leading >= c.leading && trailing >= c.trailing {
+		c.stream.writeBit(false)
+		c.stream.writeBits(xor>>c.trailing, 64-c.leading-c.trailing)
+
+		return
+	}
+
+	significant := 64 - leading - trailing
+
+	c.stream.writeBit(true)
+	c.stream.writeBits(uint64(leading), 5)       //nolint: gosec,mnd // at most 31
+	c.stream.writeBits(uint64(significant-1), 6) //nolint: gosec,mnd // between 1 and 64
+	c.stream.writeBits(xor>>trailing, significant)
+
+	c.leading, c.trailing = leading, trailing
+}
+
+func (c *tsChunk) samples() []Sample {
+	if c.count == 0 {
+		return nil
+	}
+
+	reader := bitReader{data: c.stream.data}
+	samples := make([]Sample, 0, c.count)
+
+	timestamp := int64(reader.readBits(64)) //nolint: gosec,mnd // raw bits
+	valueBits := reader.readBits(64)        //nolint: mnd // raw bits
+	samples = append(samples, Sample{Timestamp: timestamp, Value: math.Float64frombits(valueBits)})
+
+	var delta int64
+
+	leading, trailing := 0, 0
+
+	for len(samples) < c.count {
+		delta += readDeltaOfDelta(&reader)
+		timestamp += delta
+
+		if reader.readBit() {
+			if reader.readBit() {
+				leading = int(reader.readBits(5))                     //nolint: mnd // see writeValue
+				trailing = 64 - leading - int(reader.readBits(6)) - 1 //nolint: mnd // see writeValue
+			}
+
+			valueBits ^= reader.readBits(64-leading-trailing) << trailing
+		}
+
+		samples = append(samples, Sample{Timestamp: timestamp, Value: math.Float64frombits(valueBits)})
+	}
+
+	return samples
+}
+
+func readDeltaOfDelta(reader *bitReader) int64 {
+	if !reader.readBit() {
+		return 0
+	}
+
+	for i, bucket := range dodBuckets {
+		if i == len(dodBuckets)-1 || !reader.readBit() {
+			raw := reader.readBits(bucket.valueBits)
+
+			// sign extend the stored two's complement bits
+			return int64(raw<<(64-bucket.valueBits)) >> (64 - bucket.valueBits) //nolint: gosec // see above
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,75 @@
+package engine
+
+import "encoding/hex"
+
+type ValueType string
+
+const (
+	TypeString ValueType = "string"
+	TypeHash   ValueType = "hash"
+	TypeList   ValueType = "list"
+	TypeSet    ValueType = "set"
+	TypeZSet   ValueType = "zset"
+	TypeJSON   ValueType = "json"
+	TypeStream ValueType = "stream"
+
+	TypeHyperLogLog ValueType = "hyperloglog"
+	TypeBloom       ValueType = "bloom"
+	TypeTimeSeries  ValueType = "timeseries"
+
+	TypeQueue ValueType = "queue"
+
+	TypeTokenBucket   ValueType = "tokenbucket"
+	TypeSlidingWindow ValueType = "slidingwindow"
+)
+
+type value interface {
+	Type() ValueType
+}
+
+type stringValue string
+
+func (stringValue) Type() ValueType {
+	return TypeString
+}
+
+// bytesValue is a string value stored as mutable bytes, so that bitmap commands change
+// single bits without copying the whole string. Commands reading strings accept both forms.
+type bytesValue []byte
+
+func (bytesValue) Type() ValueType {
+	return TypeString
+}
+
+// lookupString returns the string value stored under key in either form. Bitmaps, strings
+// changed by bit commands, are hex encoded, as their bytes may break the line protocol.
+func lookupString(storage map[string]value, key string) (string, bool, error) {
+	switch stored := storage[key].(type) {
+	case nil:
+		return "", false, nil
+	case stringValue:
+		return string(stored), true, nil
+	case bytesValue:
+		return hex.EncodeToString(stored), true, nil
+	default:
+		return "", false, ErrWrongType
+	}
+}
+
+// lookup returns the value stored under key if it has the requested type.
+// A missing key is not an error, a key holding another type is ErrWrongType.
+func lookup[T value](storage map[string]value, key string) (T, bool, error) {
+	var zero T
+
+	stored, ok := storage[key]
+	if !ok {
+		return zero, false, nil
+	}
+
+	typed, ok := stored.(T)
+	if !ok {
+		return zero, false, ErrWrongType
+	}
+
+	return typed, true, nil
+}
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// await calls try with the mutex held until it reports done, waiting for a change of
+// one of keys between attempts. A zero timeout waits forever, an expired timeout is
+// not an error. Cancelling ctx aborts the wait with its error.
+func (e *Engine) await(ctx context.Context, keys []string, timeout time.Duration, try func() (bool, error)) error {
+	var expired <-chan time.Time
+
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		expired = timer.C
+	}
+
+	for {
+		e.mutex.Lock()
+
+		done, err := try()
+		if err != nil || done {
+			e.mutex.Unlock()
+
+			return err
+		}
+
+		wait := e.addWaiter(keys)
+
+		e.mutex.Unlock()
+
+		woken := false
+
+		select {
+		case <-wait:
+			woken = true
+		case <-expired:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+
+		e.mutex.Lock()
+		e.removeWaiter(keys, wait)
+		e.mutex.Unlock()
+
+		if !woken {
+			return err
+		}
+	}
+}
+
+// WaitFor returns a channel receiving once one of keys changes and a function that
+// stops waiting. Callers register before checking the keys, so no change is missed.
+func (e *Engine) WaitFor(keys []string) (<-chan struct{}, func()) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	wait := e.addWaiter(keys)
+
+	return wait, func() {
+		e.mutex.Lock()
+		defer e.mutex.Unlock()
+
+		e.removeWaiter(keys, wait)
+	}
+}
+
+// addWaiter registers a channel notified of changes of keys, it must be called with the mutex held.
+func (e *Engine) addWaiter(keys []string) chan struct{} {
+	wait := make(chan struct{}, 1)
+	for _, key := range keys {
+		e.waiters[key] = append(e.waiters[key], wait)
+	}
+
+	return wait
+}
+
+// notifyWaiters wakes clients blocked on key, it must be called with the mutex held.
+func (e *Engine) notifyWaiters(key string) {
+	for _, wait := range e.waiters[key] {
+		select {
+		case wait <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *Engine) removeWaiter(keys []string, wait chan struct{}) {
+	for _, key := range keys {
+		waiters := slices.DeleteFunc(e.waiters[key], func(w chan struct{}) bool {
+			return w == wait
+		})
+
+		if len(waiters) == 0 {
+			delete(e.waiters, key)
+		} else {
+			e.waiters[key] = waiters
+		}
+	}
+}
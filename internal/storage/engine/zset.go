@@ -0,0 +1,179 @@
+package engine
+
+import (
+	"cmp"
+	"slices"
+)
+
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// zsetValue keeps members ordered by score, then by member name.
+type zsetValue struct {
+	scores map[string]float64
+	sorted []ZMember
+}
+
+func (*zsetValue) Type() ValueType {
+	return TypeZSet
+}
+
+func compareZMembers(a, b ZMember) int {
+	if c := cmp.Compare(a.Score, b.Score); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(a.Member, b.Member)
+}
+
+func (z *zsetValue) add(member string, score float64) bool {
+	oldScore, exists := z.scores[member]
+	if exists {
+		if oldScore == score {
+			return false
+		}
+
+		z.remove(member)
+	}
+
+	entry := ZMember{Member: member, Score: score}
+	index, _ := slices.BinarySearchFunc(z.sorted, entry, compareZMembers)
+	z.sorted = slices.Insert(z.sorted, index, entry)
+	z.scores[member] = score
+
+	return !exists
+}
+
+func (z *zsetValue) remove(member string) bool {
+	score, exists := z.scores[member]
+	if !exists {
+		return false
+	}
+
+	index, _ := slices.BinarySearchFunc(z.sorted, ZMember{Member: member, Score: score}, compareZMembers)
+	z.sorted = slices.Delete(z.sorted, index, index+1)
+	delete(z.scores, member)
+
+	return true
+}
+
+// ZAdd adds members with scores to the sorted set at key, updating scores of
+// existing members. It returns the number of added members.
+func (e *Engine) ZAdd(key string, members []ZMember) (int, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	zset, ok, err := lookup[*zsetValue](e.storage, key)
+	if err != nil {
+		return 0, err
+	}
+
+	if !ok {
+		zset = &zsetValue{scores: make(map[string]float64, len(members))}
+		e.store(key, zset)
+	}
+
+	added := 0
+
+	for _, member := range members {
+		if zset.add(member.Member, member.Score) {
+			added++
+		}
+	}
+
+	return added, nil
+}
+
+// ZRem removes members from the sorted set at key and returns the number of removed members.
+func (e *Engine) ZRem(key string, members []string) (int, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	zset, ok, err := lookup[*zsetValue](e.storage, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	removed := 0
+
+	for _, member := range members {
+		if zset.remove(member) {
+			removed++
+		}
+	}
+
+	if len(zset.scores) == 0 {
+		e.remove(key)
+	}
+
+	return removed, nil
+}
+
+// ZRange returns members ranked between start and stop inclusive.
+// Negative ranks count from the highest score.
+func (e *Engine) ZRange(key string, start, stop int) ([]ZMember, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	zset, ok, err := lookup[*zsetValue](e.storage, key)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	start, stop, ok = normalizeRange(start, stop, len(zset.sorted))
+	if !ok {
+		return nil, nil
+	}
+
+	return slices.Clone(zset.sorted[start : stop+1]), nil
+}
+
+// ZRangeByScore returns members with scores between minScore and maxScore inclusive.
+func (e *Engine) ZRangeByScore(key string, minScore, maxScore float64) ([]ZMember, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	zset, ok, err := lookup[*zsetValue](e.storage, key)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	start, _ := slices.BinarySearchFunc(zset.sorted, minScore, func(m ZMember, score float64) int {
+		return cmp.Compare(m.Score, score)
+	})
+
+	var members []ZMember
+
+	for _, member := range zset.sorted[start:] {
+		if member.Score > maxScore {
+			break
+		}
+
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+// ZRank returns the rank of member in the sorted set at key.
+// The second result is false if the member does not exist.
+func (e *Engine) ZRank(key, member string) (int, bool, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	zset, ok, err := lookup[*zsetValue](e.storage, key)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+
+	score, exists := zset.scores[member]
+	if !exists {
+		return 0, false, nil
+	}
+
+	rank, _ := slices.BinarySearchFunc(zset.sorted, ZMember{Member: member, Score: score}, compareZMembers)
+
+	return rank, true, nil
+}
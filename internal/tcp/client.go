@@ -2,15 +2,34 @@ package tcp
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
 
+	"github.com/pingvincible/kvdatabase/internal/compute/parser"
 	"github.com/pingvincible/kvdatabase/internal/kvio"
+	"github.com/pingvincible/kvdatabase/internal/slot"
+)
+
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+const (
+	// maxRedirects is the number of redirects Do follows for a command.
+	maxRedirects = 5
+
+	movedPrefix = "error: MOVED "
+	askPrefix   = "error: ASK "
 )
 
 type Client struct {
+	addr       string
 	conn       net.Conn
 	ReadWriter *kvio.ReadWriter
+
+	slots *slot.Map          // learned on redirects, nil sends commands to addr
+	nodes map[string]*Client // connections to other nodes of a partitioned cluster
 }
 
 func NewClient(addr string) (*Client, error) {
@@ -25,12 +44,173 @@ func NewClient(addr string) (*Client, error) {
 	}
 
 	return &Client{
+		addr:       addr,
 		conn:       conn,
 		ReadWriter: kvio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)),
 	}, nil
 }
 
+// Do sends command to the node serving its keys and returns the response without the line
+// break. Once a node redirects a command with MOVED, the client learns the slot map of the
+// cluster and routes later commands by their keys, later redirects refresh the stale map. Commands redirected with ASK during
+// a slot migration are sent to the target preceded by ASKING.
+func (c *Client) Do(command string) (string, error) {
+	node := c.route(command)
+
+	for range maxRedirects {
+		response, err := node.request(command)
+		if err != nil {
+			return "", err
+		}
+
+		if _, address, ok := parseRedirect(response, askPrefix); ok {
+			response, err = c.ask(address, command)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		keySlot, address, ok := parseRedirect(response, movedPrefix)
+		if !ok {
+			return response, nil
+		}
+
+		node, err = c.learn(keySlot, address)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrTooManyRedirects, command)
+}
+
+// route returns the connection to the node serving the keys of command.
+func (c *Client) route(text string) *Client {
+	if c.slots == nil {
+		return c
+	}
+
+	command, err := parser.Parse(text)
+	if err != nil {
+		return c
+	}
+
+	keys := command.Keys()
+	if len(keys) == 0 {
+		return c
+	}
+
+	node, err := c.node(c.slots.Owner(slot.Of(keys[0])))
+	if err != nil {
+		return c
+	}
+
+	return node
+}
+
+// ask sends command preceded by ASKING to the node at address importing its slot.
+func (c *Client) ask(address, command string) (string, error) {
+	node, err := c.node(address)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = node.request(string(parser.CommandAsking))
+	if err != nil {
+		return "", err
+	}
+
+	return node.request(command)
+}
+
+// learn fetches the slot map from the node at address a command of keySlot was redirected to
+// and returns the connection to it. A redirect means the learned map is stale, e.g. slots moved
+// since, so the whole map is replaced rather than the single slot.
+func (c *Client) learn(keySlot int, address string) (*Client, error) {
+	node, err := c.node(address)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := node.request(string(parser.CommandSlots))
+	if err != nil {
+		return nil, err
+	}
+
+	slots, err := slot.ParseMap(strings.Fields(response))
+	if err != nil {
+		return nil, fmt.Errorf("failed to learn slots: %w", err)
+	}
+
+	slots.Assign(slot.Range{From: keySlot, To: keySlot, Address: address})
+	c.slots = slots
+
+	return node, nil
+}
+
+// node returns the connection to the node at address, connecting on first use.
+func (c *Client) node(address string) (*Client, error) {
+	if address == "" || address == c.addr || address == c.conn.RemoteAddr().String() {
+		return c, nil
+	}
+
+	if node, ok := c.nodes[address]; ok {
+		return node, nil
+	}
+
+	node, err := NewClient(address)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.nodes == nil {
+		c.nodes = make(map[string]*Client)
+	}
+
+	c.nodes[address] = node
+
+	return node, nil
+}
+
+func (c *Client) request(command string) (string, error) {
+	err := c.ReadWriter.WriteLine(command)
+	if err != nil {
+		return "", fmt.Errorf("failed to send command: %w", err)
+	}
+
+	response, err := c.ReadWriter.ReadLine()
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return strings.TrimSuffix(response, "\n"), nil
+}
+
+// parseRedirect parses a MOVED slot address or ASK slot address redirect.
+func parseRedirect(response, prefix string) (int, string, bool) {
+	rest, ok := strings.CutPrefix(response, prefix)
+	if !ok {
+		return 0, "", false
+	}
+
+	text, address, ok := strings.Cut(rest, " ")
+	if !ok {
+		return 0, "", false
+	}
+
+	keySlot, err := strconv.Atoi(text)
+	if err != nil || keySlot < 0 || keySlot >= slot.Count {
+		return 0, "", false
+	}
+
+	return keySlot, address, true
+}
+
 func (c *Client) Close() error {
+	for _, node := range c.nodes {
+		_ = node.Close()
+	}
+
 	err := c.conn.Close()
 	if err != nil {
 		return fmt.Errorf("failed to close tcp connection: %w", err)
@@ -0,0 +1,78 @@
+package tcp
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pingvincible/kvdatabase/internal/compute"
+	"github.com/pingvincible/kvdatabase/internal/kvio"
+)
+
+// pushWriteTimeout bounds writing a pushed message, so a client that stopped
+// reading cannot hold the connection writer forever.
+const pushWriteTimeout = 5 * time.Second
+
+// PushPrefix starts every line pushed to a client without a preceding request, no response
+// starts with it, so clients can tell pushed messages apart from responses.
+const PushPrefix = "!"
+
+// connWriter serializes responses and pushed messages written to a connection.
+type connWriter struct {
+	mutex      sync.Mutex
+	conn       net.Conn
+	readWriter *kvio.ReadWriter
+}
+
+// WriteLine writes text to the connection, a positive timeout sets a write deadline.
+func (w *connWriter) WriteLine(text string, timeout time.Duration) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if timeout > 0 {
+		err := w.conn.SetWriteDeadline(time.Now().Add(timeout))
+		if err != nil {
+			return fmt.Errorf("failed to set write deadline: %w", err)
+		}
+
+		defer func() { _ = w.conn.SetWriteDeadline(time.Time{}) }()
+	}
+
+	err := w.readWriter.WriteLine(text)
+	if err != nil {
+		return fmt.Errorf("failed to write line: %w", err)
+	}
+
+	return nil
+}
+
+// pushMessages writes messages pushed to session without a preceding request, prefixed
+// with PushPrefix, until done is closed. A client that does not keep up with its messages is disconnected.
+func (s *Server) pushMessages(writer *connWriter, session *compute.Session, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-session.Dropped():
+			s.logger.Info("disconnecting slow client, too many pending messages")
+
+			_ = writer.conn.Close()
+
+			return
+		case message := <-session.Pushes():
+			err := writer.WriteLine(PushPrefix+message, pushWriteTimeout)
+			if err != nil {
+				s.logger.Error(
+					"failed to push message to client",
+					slog.String("error", err.Error()),
+				)
+
+				_ = writer.conn.Close()
+
+				return
+			}
+		}
+	}
+}
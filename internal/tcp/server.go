@@ -2,6 +2,7 @@ package tcp
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -17,16 +18,28 @@ import (
 
 var ErrServerIsNotListening = errors.New("server is not listening")
 
+// ConnHandler takes over connections sending a request it accepts instead of a command,
+// e.g. of replicas or cluster peers.
+type ConnHandler interface {
+	Accepts(request string) bool
+	Serve(ctx context.Context, conn net.Conn, readWriter *kvio.ReadWriter, request string) error
+}
+
 type Server struct {
 	cfg              config.NetworkConfig
 	listen           net.Listener
 	computer         *compute.Computer
+	handlers         []ConnHandler
 	ClientsHandled   int
 	ClientsDiscarded int
 	logger           *slog.Logger
 
 	wgClients sync.WaitGroup
 	clients   atomic.Int32
+
+	// ctx is cancelled on Stop to release clients blocked in commands like BLPOP
+	ctx    context.Context //nolint: containedctx // server lifetime
+	cancel context.CancelFunc
 }
 
 func NewServer(
@@ -44,7 +57,11 @@ func NewServer(
 		return nil, fmt.Errorf("failed to start listening on addr: %s: %w", cfg.Address, err)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	server := &Server{
+		ctx:       ctx,
+		cancel:    cancel,
 		cfg:       cfg,
 		logger:    logger,
 		computer:  computer,
@@ -63,6 +80,12 @@ func (s *Server) Addr() (string, error) {
 	return s.listen.Addr().String(), nil
 }
 
+// Handle makes the server pass connections sending requests handler accepts to handler.
+// It must be called before Run.
+func (s *Server) Handle(handler ConnHandler) {
+	s.handlers = append(s.handlers, handler)
+}
+
 func (s *Server) Run() {
 	for {
 		s.logger.Info(
@@ -153,25 +176,45 @@ func (s *Server) handleClient(conn net.Conn) {
 		}
 	}()
 
+	// closing the connection on shutdown unblocks the read below
+	stopClosing := context.AfterFunc(s.ctx, func() { _ = conn.Close() })
+	defer stopClosing()
+
 	readerWriter := kvio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	writer := &connWriter{conn: conn, readWriter: readerWriter}
 
-	for {
-		netData, err := readerWriter.ReadLine()
-		if err != nil {
-			s.logger.Error(
-				"failed to read data from tcp client",
-				slog.String("error", err.Error()),
-			)
+	session := compute.NewSession()
+	defer s.computer.CloseSession(session)
 
-			break
-		}
+	// pushed messages are written once the session subscribes or watches
+	pushing := false
+	pushDone := make(chan struct{})
+	defer close(pushDone)
+
+	// the connection context is cancelled once the client disconnects, aborting its blocking commands
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+
+	requests, resume, done := make(chan string), make(chan struct{}), make(chan struct{})
+	defer close(done)
+
+	go s.readRequests(readerWriter, cancel, requests, resume, done)
 
+	for netData := range requests {
 		s.logger.Info(
 			"received data from client",
 			slog.String("data", netData),
 		)
 
-		response, err := s.computer.Process(netData)
+		if handler := s.handler(netData); handler != nil {
+			s.serveHandler(handler, conn, readerWriter, netData)
+
+			break
+		}
+
+		resume <- struct{}{}
+
+		response, err := s.computer.Process(ctx, session, netData)
 		if err != nil {
 			s.logger.Error(
 				"failed to process client query",
@@ -181,7 +224,7 @@ func (s *Server) handleClient(conn net.Conn) {
 			response = fmt.Sprintf("error: %s", err)
 		}
 
-		err = readerWriter.WriteLine(response)
+		err = writer.WriteLine(response, 0)
 		if err != nil {
 			s.logger.Error(
 				"failed to send data to client",
@@ -190,6 +233,69 @@ func (s *Server) handleClient(conn net.Conn) {
 
 			break
 		}
+
+		if !pushing && session.Subscribed() {
+			pushing = true
+
+			go s.pushMessages(writer, session, pushDone)
+		}
+	}
+}
+
+// readRequests reads the lines of a client into requests while its previous command
+// is processed, so that a disconnecting client cancels its context even while blocked.
+// After each line it waits for resume, since connections handed over to a handler
+// must not be read here anymore. It stops once done is closed.
+func (s *Server) readRequests(
+	readWriter *kvio.ReadWriter, cancel context.CancelFunc, requests chan<- string, resume, done <-chan struct{},
+) {
+	defer close(requests)
+	defer cancel()
+
+	for {
+		request, err := readWriter.ReadLine()
+		if err != nil {
+			s.logger.Error(
+				"failed to read data from tcp client",
+				slog.String("error", err.Error()),
+			)
+
+			return
+		}
+
+		select {
+		case requests <- request:
+		case <-done:
+			return
+		}
+
+		select {
+		case <-resume:
+		case <-done:
+			return
+		}
+	}
+}
+
+func (s *Server) handler(request string) ConnHandler {
+	for _, handler := range s.handlers {
+		if handler.Accepts(request) {
+			return handler
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) serveHandler(handler ConnHandler, conn net.Conn, readWriter *kvio.ReadWriter, request string) {
+	s.logger.Info("connection handed over", slog.String("address", conn.RemoteAddr().String()))
+
+	err := handler.Serve(s.ctx, conn, readWriter, request)
+	if err != nil {
+		s.logger.Error(
+			"handed over connection stopped",
+			slog.String("error", err.Error()),
+		)
 	}
 }
 
@@ -198,6 +304,8 @@ func (s *Server) GetClients() int32 {
 }
 
 func (s *Server) Stop() error {
+	s.cancel()
+
 	err := s.listen.Close()
 	if err != nil {
 		return fmt.Errorf("failed to stop server: %w", err)
@@ -1,16 +1,26 @@
 package tcp_test
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/pingvincible/kvdatabase/internal/compute"
 	"github.com/pingvincible/kvdatabase/internal/config"
+	"github.com/pingvincible/kvdatabase/internal/kvio"
 	"github.com/pingvincible/kvdatabase/internal/logger"
+	"github.com/pingvincible/kvdatabase/internal/migration"
+	"github.com/pingvincible/kvdatabase/internal/raft"
+	"github.com/pingvincible/kvdatabase/internal/replication"
+	"github.com/pingvincible/kvdatabase/internal/slot"
 	"github.com/pingvincible/kvdatabase/internal/storage/engine"
 	"github.com/pingvincible/kvdatabase/internal/tcp"
 	"github.com/stretchr/testify/assert"
@@ -190,3 +200,791 @@ func TestTcpServerStopWhileWaitingForAccept(t *testing.T) {
 
 	wgServer.Wait()
 }
+
+func TestTcpServerStopReleasesBlockedClients(t *testing.T) {
+	t.Parallel()
+
+	eng := engine.New()
+	computer := compute.NewComputer(eng)
+
+	server, err := tcp.NewServer(config.NetworkConfig{
+		Address:        "",
+		MaxConnections: 10,
+		MaxMessageSize: "1KB",
+		IdleTimeout:    2 * time.Minute,
+	}, computer, logger.NewDiscardLogger())
+	require.NoError(t, err)
+
+	addr, err := server.Addr()
+	require.NoError(t, err)
+
+	wgServer := sync.WaitGroup{}
+	wgServer.Add(1)
+
+	go func() {
+		defer wgServer.Done()
+		server.Run()
+	}()
+
+	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
+	require.NoError(t, err)
+
+	client, err := executeClient(tcpAddr, "RPUSH jobs job\n")
+	require.NoError(t, err)
+
+	defer func() { _ = client.Close() }()
+
+	_, err = client.Write([]byte("BLPOP jobs 0\nBLPOP jobs 0\n"))
+	require.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	stopped := make(chan error)
+
+	go func() {
+		stopped <- server.Stop()
+	}()
+
+	select {
+	case err = <-stopped:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not release blocked client")
+	}
+
+	wgServer.Wait()
+}
+
+func TestTcpServerDisconnectReleasesBlockedClient(t *testing.T) {
+	t.Parallel()
+
+	computer := compute.NewComputer(engine.New())
+	server, addr := startServer(t, computer)
+
+	go server.Run()
+
+	defer func() { _ = server.Stop() }()
+
+	blocked, err := tcp.NewClient(addr)
+	require.NoError(t, err)
+	require.NoError(t, blocked.ReadWriter.WriteLine("BLPOP jobs 0"))
+
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, blocked.Close())
+
+	require.Eventually(t, func() bool { return server.GetClients() == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	client, err := tcp.NewClient(addr)
+	require.NoError(t, err)
+
+	defer func() { _ = client.Close() }()
+
+	response, err := client.Do("RPUSH jobs job")
+	require.NoError(t, err)
+	assert.Equal(t, "rev 1 1", response)
+
+	// the element is not popped for the disconnected client
+	response, err = client.Do("LPOP jobs")
+	require.NoError(t, err)
+	assert.Equal(t, "rev 2 job", response)
+}
+
+func TestTcpServerBitmap(t *testing.T) {
+	t.Parallel()
+
+	computer := compute.NewComputer(engine.New())
+	server, addr := startServer(t, computer)
+
+	go server.Run()
+
+	defer func() { _ = server.Stop() }()
+
+	client, err := tcp.NewClient(addr)
+	require.NoError(t, err)
+
+	defer func() { _ = client.Close() }()
+
+	do := func(text string) string {
+		response, err := client.Do(text)
+		require.NoError(t, err)
+
+		return response
+	}
+
+	// a space is not trimmed from the reply
+	assert.Equal(t, "rev 1 0", do("SETBIT space 2 1"))
+	assert.Equal(t, "20", do("GET space"))
+
+	// a newline does not split the reply, the replies that follow stay in order
+	for _, offset := range []int{0, 1, 2, 3, 4, 5, 6, 7, 12, 14, 16, 17, 18, 19, 20, 21, 22, 23} {
+		do("SETBIT newline " + strconv.Itoa(offset) + " 1")
+	}
+
+	assert.Equal(t, "ff0aff", do("GET newline"))
+	assert.Equal(t, "1", do("GETBIT newline 12"))
+	assert.Equal(t, "18", do("BITCOUNT newline"))
+}
+
+func TestTcpServerPubSub(t *testing.T) {
+	t.Parallel()
+
+	eng := engine.New()
+	computer := compute.NewComputer(eng)
+
+	server, err := tcp.NewServer(config.NetworkConfig{
+		Address:        "",
+		MaxConnections: 10,
+		MaxMessageSize: "1KB",
+		IdleTimeout:    2 * time.Minute,
+	}, computer, logger.NewDiscardLogger())
+	require.NoError(t, err)
+
+	addr, err := server.Addr()
+	require.NoError(t, err)
+
+	go server.Run()
+
+	defer func() { _ = server.Stop() }()
+
+	subscriber, err := tcp.NewClient(addr)
+	require.NoError(t, err)
+
+	defer func() { _ = subscriber.Close() }()
+
+	publisher, err := tcp.NewClient(addr)
+	require.NoError(t, err)
+
+	defer func() { _ = publisher.Close() }()
+
+	request := func(client *tcp.Client, text string) string {
+		require.NoError(t, client.ReadWriter.WriteLine(text))
+
+		response, err := client.ReadWriter.ReadLine()
+		require.NoError(t, err)
+
+		return response
+	}
+
+	assert.Equal(t, "1\n", request(subscriber, "SUBSCRIBE news"))
+	assert.Equal(t, "2\n", request(subscriber, "PSUBSCRIBE sp*"))
+	assert.Contains(t, request(subscriber, "GET key"), "error:")
+
+	assert.Equal(t, "1\n", request(publisher, "PUBLISH news hello world"))
+	assert.Equal(t, "1\n", request(publisher, "PUBLISH sport goal"))
+
+	message, err := subscriber.ReadWriter.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "!message news hello world\n", message)
+
+	message, err = subscriber.ReadWriter.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "!pmessage sp* sport goal\n", message)
+}
+
+func startServer(t *testing.T, computer *compute.Computer) (*tcp.Server, string) {
+	t.Helper()
+
+	server, err := tcp.NewServer(config.NetworkConfig{
+		Address:        "",
+		MaxConnections: 10,
+		MaxMessageSize: "1KB",
+		IdleTimeout:    2 * time.Minute,
+	}, computer, logger.NewDiscardLogger())
+	require.NoError(t, err)
+
+	addr, err := server.Addr()
+	require.NoError(t, err)
+
+	return server, addr
+}
+
+func TestTcpServerReplication(t *testing.T) {
+	t.Parallel()
+
+	const interval = 50 * time.Millisecond
+
+	primaryComputer := compute.NewComputer(engine.New())
+	primaryServer, primaryAddr := startServer(t, primaryComputer)
+
+	primary := replication.NewPrimary(primaryComputer, interval, logger.NewDiscardLogger())
+	primaryComputer.SetReplication(primary, false)
+	primaryServer.Handle(primary)
+
+	go primaryServer.Run()
+
+	defer func() { _ = primaryServer.Stop() }()
+
+	replicaComputer := compute.NewComputer(engine.New())
+	replicaServer, replicaAddr := startServer(t, replicaComputer)
+
+	go replicaServer.Run()
+
+	defer func() { _ = replicaServer.Stop() }()
+
+	primaryClient, err := tcp.NewClient(primaryAddr)
+	require.NoError(t, err)
+
+	defer func() { _ = primaryClient.Close() }()
+
+	replicaClient, err := tcp.NewClient(replicaAddr)
+	require.NoError(t, err)
+
+	defer func() { _ = replicaClient.Close() }()
+
+	request := func(client *tcp.Client, text string) string {
+		require.NoError(t, client.ReadWriter.WriteLine(text))
+
+		response, err := client.ReadWriter.ReadLine()
+		require.NoError(t, err)
+
+		return response
+	}
+
+	assert.Equal(t, "rev 1\n", request(primaryClient, "SET name bob"))
+	assert.Equal(t, "rev 2 2\n", request(primaryClient, "RPUSH jobs a b"))
+
+	replica := replication.NewReplica(replicaComputer, primaryAddr, interval, logger.NewDiscardLogger())
+	replicaComputer.SetReplication(replica, true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go replica.Run(ctx)
+
+	require.Eventually(t, func() bool {
+		return request(replicaClient, "GET name") == "bob\n"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "a b\n", request(replicaClient, "LRANGE jobs 0 -1"))
+	assert.Contains(t, request(replicaClient, "SET name alice"), "READONLY")
+
+	assert.Equal(t, "rev 3\n", request(primaryClient, "SET name alice"))
+	assert.Equal(t, "rev 4\n", request(primaryClient, "DEL jobs"))
+
+	require.Eventually(t, func() bool {
+		return request(replicaClient, "GET name") == "alice\n"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "\n", request(replicaClient, "LRANGE jobs 0 -1"))
+	assert.Equal(t, "alice\n", request(replicaClient, "GET name AT 3"))
+	assert.Contains(t, request(replicaClient, "INFO"), "role replica")
+	assert.Contains(t, request(replicaClient, "INFO"), "revision 4")
+
+	require.Eventually(t, func() bool {
+		return strings.Contains(request(primaryClient, "INFO"), "acked 4")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "alice\n", request(replicaClient, "GET name MAXLAG 5s MINREV 4"))
+	assert.Equal(t, "alice\n", request(replicaClient, "GET name AT 3 MINREV 3"))
+	assert.Equal(t, "error: failed to execute command: STALE "+primaryAddr+" revision 4 of 5\n",
+		request(replicaClient, "GET name MINREV 5"))
+	assert.Equal(t, "alice\n", request(primaryClient, "GET name MAXLAG 1ms MINREV 4"))
+
+	// an idle replica that applied every change does not lag between pings
+	require.Eventually(t, func() bool {
+		return request(replicaClient, "GET name MAXLAG 0s MINREV 0") == "alice\n"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	time.Sleep(interval / 2)
+	assert.Equal(t, "alice\n", request(replicaClient, "GET name MAXLAG 0s"))
+
+	cancel()
+
+	require.Eventually(t, func() bool {
+		return strings.Contains(request(replicaClient, "GET name MAXLAG 5s"), "STALE "+primaryAddr+" disconnected")
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestTcpServerReplicationQuorum(t *testing.T) {
+	t.Parallel()
+
+	const interval = 50 * time.Millisecond
+
+	primaryComputer := compute.NewComputer(engine.New())
+	primaryServer, primaryAddr := startServer(t, primaryComputer)
+
+	primary := replication.NewPrimary(primaryComputer, interval, logger.NewDiscardLogger())
+	primaryComputer.SetReplication(primary, false)
+	primaryComputer.SetQuorum(1, 100*time.Millisecond)
+	primaryServer.Handle(primary)
+
+	go primaryServer.Run()
+
+	defer func() { _ = primaryServer.Stop() }()
+
+	client, err := tcp.NewClient(primaryAddr)
+	require.NoError(t, err)
+
+	defer func() { _ = client.Close() }()
+
+	request := func(text string) string {
+		require.NoError(t, client.ReadWriter.WriteLine(text))
+
+		response, err := client.ReadWriter.ReadLine()
+		require.NoError(t, err)
+
+		return response
+	}
+
+	assert.Contains(t, request("SET name bob"), "NOQUORUM")
+
+	replicaComputer := compute.NewComputer(engine.New())
+	replica := replication.NewReplica(replicaComputer, primaryAddr, interval, logger.NewDiscardLogger())
+	replicaComputer.SetReplication(replica, true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go replica.Run(ctx)
+
+	require.Eventually(t, func() bool {
+		return strings.Contains(request("INFO"), "replicas 1")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "rev 2\n", request("SET name alice"))
+	assert.Equal(t, "1\n", request("WAIT 1 0"))
+	assert.Contains(t, request("WAIT 2 0.05"), "NOQUORUM")
+
+	value, err := replicaComputer.Process(context.Background(), compute.NewSession(), "GET name")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", value)
+}
+
+func TestTcpServerFailover(t *testing.T) {
+	t.Parallel()
+
+	const interval = 50 * time.Millisecond
+
+	addrs := make([]string, 3)
+	clients := make([]*tcp.Client, 3)
+
+	for i := range addrs {
+		computer := compute.NewComputer(engine.New())
+		computer.AllowAdmin(true)
+
+		var server *tcp.Server
+
+		server, addrs[i] = startServer(t, computer)
+
+		manager := replication.NewManager(computer, addrs[i], interval, logger.NewDiscardLogger())
+		computer.SetReplication(manager, false)
+		server.Handle(manager)
+
+		go server.Run()
+
+		defer func() { _ = server.Stop() }()
+
+		client, err := tcp.NewClient(addrs[i])
+		require.NoError(t, err)
+
+		defer func() { _ = client.Close() }()
+
+		clients[i] = client
+	}
+
+	request := func(i int, text string) string {
+		require.NoError(t, clients[i].ReadWriter.WriteLine(text))
+
+		response, err := clients[i].ReadWriter.ReadLine()
+		require.NoError(t, err)
+
+		return response
+	}
+
+	eventually := func(i int, text, want string) {
+		require.Eventually(t, func() bool {
+			return strings.Contains(request(i, text), want)
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	// 0 is the primary of replicas 1 and 2
+	assert.Equal(t, "\n", request(1, "REPLICAOF "+addrs[0]))
+	assert.Equal(t, "\n", request(2, "REPLICAOF "+addrs[0]))
+	assert.Contains(t, request(1, "REPLICAOF "+addrs[1]), replication.ErrReplicaOfSelf.Error())
+
+	_, port, err := net.SplitHostPort(addrs[1])
+	require.NoError(t, err)
+	assert.Contains(t, request(1, "REPLICAOF localhost:"+port), replication.ErrReplicaOfSelf.Error())
+
+	eventually(0, "INFO", "replicas 2")
+	assert.Equal(t, "rev 1\n", request(0, "SET name bob"))
+	eventually(1, "GET name", "bob")
+	eventually(2, "GET name", "bob")
+
+	// 1 is promoted and re-points 2, which continues from its revision
+	assert.Equal(t, "\n", request(1, "PROMOTE "+addrs[2]))
+	assert.Contains(t, request(1, "INFO"), "role primary")
+	assert.Equal(t, "rev 2\n", request(1, "SET name alice"))
+	eventually(2, "GET name", "alice")
+	assert.Contains(t, request(2, "INFO"), "last_sync partial")
+	assert.Contains(t, request(2, "SET name eve"), "READONLY")
+
+	// the former primary rejoins as a replica of 1 without a full copy
+	assert.Equal(t, "\n", request(0, "REPLICAOF "+addrs[1]))
+	eventually(0, "GET name", "alice")
+	assert.Contains(t, request(0, "INFO"), "last_sync partial")
+	assert.Contains(t, request(0, "SET name eve"), "READONLY")
+
+	// 0 is promoted back, writes of 1 it did not replicate require a full copy
+	assert.Equal(t, "\n", request(0, "REPLICAOF NO ONE"))
+	assert.Equal(t, "rev 3\n", request(0, "SET name carol"))
+	assert.Equal(t, "rev 3\n", request(1, "SET name dave"))
+	assert.Equal(t, "\n", request(1, "REPLICAOF "+addrs[0]))
+	eventually(1, "GET name", "carol")
+	assert.Contains(t, request(1, "INFO"), "last_sync full")
+}
+
+func TestTcpServerSlots(t *testing.T) {
+	t.Parallel()
+
+	addrs := make([]string, 2)
+	computers := make([]*compute.Computer, 2)
+
+	for i := range addrs {
+		computers[i] = compute.NewComputer(engine.New())
+
+		var server *tcp.Server
+
+		server, addrs[i] = startServer(t, computers[i])
+
+		go server.Run()
+
+		defer func() { _ = server.Stop() }()
+	}
+
+	slots, err := slot.ParseMap([]string{"0-8191=" + addrs[0], "8192-16383=" + addrs[1]})
+	require.NoError(t, err)
+
+	for i, computer := range computers {
+		computer.SetSlots(addrs[i], slots)
+	}
+
+	// bar is in slot 5061 served by the first node, foo in slot 12182 served by the second
+	client, err := tcp.NewClient(addrs[0])
+	require.NoError(t, err)
+
+	defer func() { _ = client.Close() }()
+
+	require.NoError(t, client.ReadWriter.WriteLine("SET foo 1"))
+
+	response, err := client.ReadWriter.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "error: MOVED 12182 "+addrs[1]+"\n", response)
+
+	do := func(text string) string {
+		response, err := client.Do(text)
+		require.NoError(t, err)
+
+		return response
+	}
+
+	assert.Equal(t, "rev 1", do("SET foo 1"))
+	assert.Equal(t, "rev 1", do("SET bar 2"))
+	assert.Equal(t, "rev 2", do("SET foo 3"))
+	assert.Equal(t, "3", do("GET foo"))
+	assert.Equal(t, "2", do("GET bar"))
+	assert.Equal(t, "rev 3 1", do("SADD {foo}.tags a"))
+	assert.Equal(t, "a", do("SUNION {foo}.tags {foo}.other"))
+	assert.Equal(t, "error: "+compute.ErrCrossSlot.Error(), do("SUNION foo bar"))
+	// baz is in slot 4813 of the same node as bar, the slots may still move apart
+	assert.Equal(t, "error: "+compute.ErrCrossSlot.Error(), do("SUNION bar baz"))
+	assert.Equal(t, "0-8191="+addrs[0]+" 8192-16383="+addrs[1], do("SLOTS"))
+
+	value, err := computers[1].Process(context.Background(), compute.NewSession(), "GET foo")
+	require.NoError(t, err)
+	assert.Equal(t, "3", value)
+
+	_, err = computers[0].Process(context.Background(), compute.NewSession(), "XGROUP CREATE {foo}.s g $ MKSTREAM")
+	require.ErrorIs(t, err, compute.ErrMoved)
+	assert.Contains(t, err.Error(), "12182")
+}
+
+// requestCounter counts the requests of a server starting with prefix, it takes over no connection.
+type requestCounter struct {
+	prefix string
+	count  atomic.Int32
+}
+
+func (r *requestCounter) Accepts(request string) bool {
+	if strings.HasPrefix(request, r.prefix) {
+		r.count.Add(1)
+	}
+
+	return false
+}
+
+func (r *requestCounter) Serve(context.Context, net.Conn, *kvio.ReadWriter, string) error {
+	return nil
+}
+
+func TestTcpServerSlotsMoved(t *testing.T) {
+	t.Parallel()
+
+	addrs := make([]string, 2)
+	computers := make([]*compute.Computer, 2)
+	counter := &requestCounter{prefix: "GET foo"}
+
+	for i := range addrs {
+		computers[i] = compute.NewComputer(engine.New())
+
+		var server *tcp.Server
+
+		server, addrs[i] = startServer(t, computers[i])
+		if i == 1 {
+			server.Handle(counter)
+		}
+
+		go server.Run()
+
+		defer func() { _ = server.Stop() }()
+	}
+
+	assign := func(ranges ...string) {
+		slots, err := slot.ParseMap(ranges)
+		require.NoError(t, err)
+
+		for i, computer := range computers {
+			computer.SetSlots(addrs[i], slots)
+		}
+	}
+
+	// bar is in slot 5061, foo in slot 12182
+	assign("0-8191="+addrs[0], "8192-16383="+addrs[1])
+
+	client, err := tcp.NewClient(addrs[0])
+	require.NoError(t, err)
+
+	defer func() { _ = client.Close() }()
+
+	do := func(text string) string {
+		response, err := client.Do(text)
+		require.NoError(t, err)
+
+		return response
+	}
+
+	assert.Equal(t, "rev 1", do("SET foo 1"))
+	assert.Equal(t, "rev 1", do("SET bar 2"))
+
+	// the halves swap nodes after the client learned the slots, the first redirect refreshes them
+	assign("0-8191="+addrs[1], "8192-16383="+addrs[0])
+
+	assert.Equal(t, "rev 2", do("SET bar 3"))
+	assert.Equal(t, "", do("GET foo"))
+	assert.Equal(t, int32(0), counter.count.Load())
+}
+
+func TestTcpServerSlotMigration(t *testing.T) {
+	t.Parallel()
+
+	addrs := make([]string, 2)
+	computers := make([]*compute.Computer, 2)
+
+	for i := range addrs {
+		computers[i] = compute.NewComputer(engine.New())
+		computers[i].AllowAdmin(true)
+
+		var server *tcp.Server
+
+		server, addrs[i] = startServer(t, computers[i])
+		server.Handle(migration.NewImporter(computers[i], logger.NewDiscardLogger()))
+
+		go server.Run()
+
+		defer func() { _ = server.Stop() }()
+	}
+
+	for i, computer := range computers {
+		slots, err := slot.ParseMap([]string{"0-16383=" + addrs[0]})
+		require.NoError(t, err)
+
+		computer.SetSlots(addrs[i], slots)
+		computer.SetMigrator(migration.NewMigrator(computer, addrs[i], 2, logger.NewDiscardLogger()))
+	}
+
+	client, err := tcp.NewClient(addrs[0])
+	require.NoError(t, err)
+
+	defer func() { _ = client.Close() }()
+
+	do := func(text string) string {
+		response, err := client.Do(text)
+		require.NoError(t, err)
+
+		return response
+	}
+
+	raw := func(addr, text string) string {
+		value, err := computers[slices.Index(addrs, addr)].Process(context.Background(), compute.NewSession(), text)
+		if err != nil {
+			return "error: " + err.Error()
+		}
+
+		return value
+	}
+
+	// foo and {foo}.n are in slot 12182, bar in slot 5061
+	assert.Equal(t, "rev 1", do("SET foo 0"))
+
+	for i := 1; i <= 4; i++ {
+		assert.Equal(t, "rev "+strconv.Itoa(i+1), do("SET {foo}."+strconv.Itoa(i)+" "+strconv.Itoa(i)))
+	}
+
+	assert.Equal(t, "rev 6", do("SET bar 1"))
+
+	// move a single key, the rest of the slot is still served by the source
+	require.NoError(t, computers[0].BeginMigration(12182, addrs[1]))
+	require.NoError(t, computers[1].BeginImport(12182, addrs[0]))
+
+	moved, err := computers[0].MigrateKeys(12182, 1, computers[1].Import)
+	require.NoError(t, err)
+	assert.Equal(t, 1, moved)
+
+	// any key of the slot may be moved first
+	movedKey, keptKey := "foo", "{foo}.1"
+
+	for i := 1; i <= 4 && raw(addrs[0], "GET "+movedKey) != "error: ASK 12182 "+addrs[1]; i++ {
+		movedKey, keptKey = "{foo}."+strconv.Itoa(i), "foo"
+	}
+
+	assert.Equal(t, "error: ASK 12182 "+addrs[1], raw(addrs[0], "GET "+movedKey))
+	assert.Equal(t, "error: MOVED 12182 "+addrs[0], raw(addrs[1], "GET "+movedKey))
+	assert.Equal(t, "error: "+compute.ErrTryAgain.Error(), raw(addrs[0], "SUNION "+keptKey+" "+movedKey))
+	assert.Contains(t, raw(addrs[0], "BLPOP {foo}.jobs 0"), compute.ErrTryAgain.Error())
+	assert.NotEmpty(t, do("GET "+keptKey))
+	assert.NotEmpty(t, do("GET "+movedKey))
+	assert.Equal(t, "rev 2", do("SET {foo}.new 5"))
+
+	// the migration resumes and hands the slot over
+	assert.Equal(t, "4", do("SLOTS.MIGRATE 12182 "+addrs[1]))
+	assert.Equal(t, "error: MOVED 12182 "+addrs[1], raw(addrs[0], "GET {foo}.2"))
+	assert.Equal(t, "2", raw(addrs[1], "GET {foo}.2"))
+	assert.Equal(t, "2", do("GET {foo}.2"))
+	assert.Equal(t, "5", do("GET {foo}.new"))
+	assert.Equal(t, "1", do("GET bar"))
+
+	// rebalancing moves the upper half of the slots to the second node
+	require.NoError(t, migration.Rebalance(context.Background(), addrs, logger.NewDiscardLogger()))
+
+	for _, addr := range addrs {
+		assert.Equal(t, "0-8191="+addrs[0]+" 8192-16383="+addrs[1], raw(addr, "SLOTS"))
+	}
+
+	assert.Equal(t, "0", do("GET foo"))
+	assert.Equal(t, "1", raw(addrs[0], "GET bar"))
+}
+
+func TestTcpServerImportRejected(t *testing.T) {
+	t.Parallel()
+
+	computer := compute.NewComputer(engine.New())
+
+	server, addr := startServer(t, computer)
+	server.Handle(migration.NewImporter(computer, logger.NewDiscardLogger()))
+
+	go server.Run()
+
+	defer func() { _ = server.Stop() }()
+
+	importSlot := func() string {
+		client, err := tcp.NewClient(addr)
+		require.NoError(t, err)
+
+		defer func() { _ = client.Close() }()
+
+		response, err := client.Do("IMPORT 12182 " + addr)
+		require.NoError(t, err)
+
+		return response
+	}
+
+	assert.Equal(t, "error: "+compute.ErrAdminDisabled.Error(), importSlot())
+
+	computer.AllowAdmin(true)
+	assert.Equal(t, "error: "+compute.ErrNoSlots.Error(), importSlot())
+
+	slots, err := slot.ParseMap([]string{"0-16383=" + addr})
+	require.NoError(t, err)
+
+	computer.SetSlots(addr, slots)
+	computer.SetReadOnly(true)
+	assert.Equal(t, "error: "+compute.ErrReadOnly.Error(), importSlot())
+
+	computer.SetReadOnly(false)
+	assert.Equal(t, "ok", importSlot())
+}
+
+func TestTcpServerCluster(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	servers := make([]*tcp.Server, 3)
+	addrs := make([]string, 3)
+	computers := make([]*compute.Computer, 3)
+
+	for i := range servers {
+		computers[i] = compute.NewComputer(engine.New())
+		servers[i], addrs[i] = startServer(t, computers[i])
+	}
+
+	nodes := make(map[string]*raft.Node)
+
+	for i, server := range servers {
+		transport := raft.NewTCPTransport()
+		defer transport.Close()
+
+		node, err := raft.NewNode(raft.Config{
+			ID:                addrs[i],
+			Members:           addrs,
+			ElectionTimeout:   200 * time.Millisecond,
+			HeartbeatInterval: 20 * time.Millisecond,
+		}, computers[i].StateMachine(), transport, logger.NewDiscardLogger())
+		require.NoError(t, err)
+
+		computers[i].SetConsensus(node)
+		server.Handle(node)
+		nodes[addrs[i]] = node
+
+		go server.Run()
+
+		defer func() { _ = server.Stop() }()
+
+		go node.Run(ctx)
+	}
+
+	var leader string
+
+	require.Eventually(t, func() bool {
+		leader = nodes[addrs[0]].Leader()
+
+		return leader != "" && nodes[addrs[1]].Leader() == leader && nodes[addrs[2]].Leader() == leader
+	}, 5*time.Second, 10*time.Millisecond)
+
+	request := func(addr, text string) string {
+		client, err := tcp.NewClient(addr)
+		require.NoError(t, err)
+
+		defer func() { _ = client.Close() }()
+
+		require.NoError(t, client.ReadWriter.WriteLine(text))
+
+		response, err := client.ReadWriter.ReadLine()
+		require.NoError(t, err)
+
+		return response
+	}
+
+	assert.Equal(t, "rev 1\n", request(leader, "SET name bob"))
+
+	for _, addr := range addrs {
+		require.Eventually(t, func() bool {
+			return request(addr, "GET name") == "bob\n"
+		}, 5*time.Second, 10*time.Millisecond)
+
+		if addr != leader {
+			assert.Equal(t, "error: failed to replicate command: NOTLEADER "+leader+"\n", request(addr, "SET name alice"))
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package watch
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pingvincible/kvdatabase/internal/pubsub"
+)
+
+// ErrCompacted is returned when watching from a revision older than the retained history.
+var ErrCompacted = errors.New("required revision has been compacted")
+
+// OpFlush is reported once for all keys of a flushed namespace, it matches every prefix.
+const OpFlush = "flush"
+
+// Event describes a change of a key made by the mutation with Revision.
+type Event struct {
+	Revision  int64
+	Namespace string
+	Key       string
+	Op        string
+}
+
+// String renders the event as pushed to watchers: watch <revision> <op> <key>.
+func (e Event) String() string {
+	return strings.TrimSpace("watch " + strconv.FormatInt(e.Revision, 10) + " " + e.Op + " " + e.Key)
+}
+
+func (e Event) matches(namespace, prefix string) bool {
+	return e.Namespace == namespace && (e.Op == OpFlush || strings.HasPrefix(e.Key, prefix))
+}
+
+// History tells the oldest revision that can still be read, the hub retains events
+// of that revision and later ones only.
+type History interface {
+	CompactedRevision() int64
+}
+
+type watch struct {
+	namespace string
+	prefix    string
+}
+
+// Hub delivers key change events to subscribers watching key prefixes
+// and retains the latest events so that watchers can resume from a revision.
+type Hub struct {
+	mutex   sync.RWMutex
+	watches map[*pubsub.Subscriber][]watch
+
+	events  []Event
+	history History // compacts events along with the key versions
+}
+
+// NewHub returns a hub retaining the events of the revisions history has not compacted yet.
+func NewHub(history History) *Hub {
+	return &Hub{
+		watches: make(map[*pubsub.Subscriber][]watch),
+		history: history,
+	}
+}
+
+// Watch subscribes subscriber to changes of keys starting with prefix in namespace
+// and returns the number of its watches.
+func (h *Hub) Watch(subscriber *pubsub.Subscriber, namespace, prefix string) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.add(subscriber, watch{namespace: namespace, prefix: prefix})
+}
+
+// WatchFrom replays retained events of keys starting with prefix in namespace since revision
+// to subscriber and then subscribes it to further changes like Watch. It fails with ErrCompacted
+// if events since revision are no longer retained.
+func (h *Hub) WatchFrom(subscriber *pubsub.Subscriber, namespace, prefix string, revision int64) (int, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	compacted := h.compact()
+	if revision < compacted {
+		return 0, fmt.Errorf("%w: oldest retained revision is %d", ErrCompacted, compacted)
+	}
+
+	for _, event := range h.events {
+		if event.Revision < revision || !event.matches(namespace, prefix) {
+			continue
+		}
+
+		if !subscriber.Send(event.String()) {
+			// the subscriber is disconnected, there is no point in watching
+			return len(h.watches[subscriber]), nil
+		}
+	}
+
+	return h.add(subscriber, watch{namespace: namespace, prefix: prefix}), nil
+}
+
+func (h *Hub) add(subscriber *pubsub.Subscriber, w watch) int {
+	if !slices.Contains(h.watches[subscriber], w) {
+		h.watches[subscriber] = append(h.watches[subscriber], w)
+	}
+
+	return len(h.watches[subscriber])
+}
+
+// Unwatch removes the watch of prefix in namespace, or all watches of subscriber
+// if prefix is empty, and returns the number of remaining watches.
+func (h *Hub) Unwatch(subscriber *pubsub.Subscriber, namespace, prefix string) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	watches := slices.DeleteFunc(h.watches[subscriber], func(w watch) bool {
+		return prefix == "" || (w.namespace == namespace && w.prefix == prefix)
+	})
+
+	if len(watches) == 0 {
+		delete(h.watches, subscriber)
+	} else {
+		h.watches[subscriber] = watches
+	}
+
+	return len(watches)
+}
+
+// Remove drops all watches of subscriber.
+func (h *Hub) Remove(subscriber *pubsub.Subscriber) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.watches, subscriber)
+}
+
+// Publish retains event and delivers it to every subscriber watching a matching prefix once.
+// Subscribers too slow to receive it are dropped.
+func (h *Hub) Publish(event Event) {
+	h.mutex.Lock()
+
+	h.events = append(h.events, event)
+	h.compact()
+
+	var dropped []*pubsub.Subscriber
+
+	for subscriber, watches := range h.watches {
+		matched := slices.ContainsFunc(watches, func(w watch) bool {
+			return event.matches(w.namespace, w.prefix)
+		})
+
+		if matched && !subscriber.Send(event.String()) {
+			dropped = append(dropped, subscriber)
+		}
+	}
+
+	for _, subscriber := range dropped {
+		delete(h.watches, subscriber)
+	}
+
+	h.mutex.Unlock()
+}
+
+// Reset discards all retained events, e.g. after loading a snapshot of another history.
+// Watches are kept and receive the events of later changes.
+func (h *Hub) Reset() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.events = nil
+}
+
+// compact discards events of revisions compacted by the history and returns the oldest
+// retained revision. It must be called with the mutex held.
+func (h *Hub) compact() int64 {
+	compacted := h.history.CompactedRevision()
+
+	excess, _ := slices.BinarySearchFunc(h.events, compacted, func(event Event, revision int64) int {
+		return cmp.Compare(event.Revision, revision)
+	})
+
+	// append copies the retained events to a new array once the capacity is exhausted
+	h.events = h.events[excess:]
+
+	return compacted
+}
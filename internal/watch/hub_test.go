@@ -0,0 +1,92 @@
+package watch_test
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pingvincible/kvdatabase/internal/pubsub"
+	"github.com/pingvincible/kvdatabase/internal/watch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compaction is a history whose oldest readable revision is set by tests.
+type compaction struct {
+	revision atomic.Int64
+}
+
+func (c *compaction) CompactedRevision() int64 {
+	return c.revision.Load()
+}
+
+func TestHubPublish(t *testing.T) {
+	t.Parallel()
+
+	hub := watch.NewHub(&compaction{})
+	users, all := pubsub.NewSubscriber(10), pubsub.NewSubscriber(10)
+
+	assert.Equal(t, 1, hub.Watch(users, "0", "user/"))
+	assert.Equal(t, 2, hub.Watch(users, "0", "user/1"))
+	assert.Equal(t, 1, hub.Watch(all, "0", ""))
+
+	hub.Publish(watch.Event{Revision: 1, Namespace: "0", Key: "user/1", Op: "set"})
+	hub.Publish(watch.Event{Revision: 2, Namespace: "0", Key: "order/1", Op: "del"})
+	hub.Publish(watch.Event{Revision: 3, Namespace: "billing", Key: "user/2", Op: "set"})
+	hub.Publish(watch.Event{Revision: 4, Namespace: "0", Op: watch.OpFlush})
+
+	assert.Equal(t, "watch 1 set user/1", <-users.Messages())
+	assert.Equal(t, "watch 4 flush", <-users.Messages())
+	assert.Equal(t, "watch 1 set user/1", <-all.Messages())
+	assert.Equal(t, "watch 2 del order/1", <-all.Messages())
+	assert.Equal(t, "watch 4 flush", <-all.Messages())
+
+	assert.Equal(t, 1, hub.Unwatch(users, "0", "user/1"))
+	assert.Equal(t, 0, hub.Unwatch(users, "0", ""))
+
+	hub.Publish(watch.Event{Revision: 5, Namespace: "0", Key: "user/1", Op: "set"})
+	assert.Empty(t, users.Messages())
+}
+
+func TestHubWatchFrom(t *testing.T) {
+	t.Parallel()
+
+	history := &compaction{}
+	hub := watch.NewHub(history)
+	subscriber := pubsub.NewSubscriber(10)
+
+	for revision := int64(1); revision <= 3; revision++ {
+		hub.Publish(watch.Event{Revision: revision, Namespace: "0", Key: "user/" + strconv.FormatInt(revision, 10), Op: "set"})
+	}
+
+	// events are retained as long as the history can read their revisions
+	history.revision.Store(2)
+
+	_, err := hub.WatchFrom(subscriber, "0", "user/", 1)
+	require.ErrorIs(t, err, watch.ErrCompacted)
+
+	watches, err := hub.WatchFrom(subscriber, "0", "user/", 2)
+	require.NoError(t, err)
+	assert.Equal(t, 1, watches)
+
+	hub.Publish(watch.Event{Revision: 4, Namespace: "0", Key: "user/4", Op: "del"})
+
+	assert.Equal(t, "watch 2 set user/2", <-subscriber.Messages())
+	assert.Equal(t, "watch 3 set user/3", <-subscriber.Messages())
+	assert.Equal(t, "watch 4 del user/4", <-subscriber.Messages())
+}
+
+func TestHubReset(t *testing.T) {
+	t.Parallel()
+
+	hub := watch.NewHub(&compaction{})
+	subscriber := pubsub.NewSubscriber(10)
+
+	hub.Publish(watch.Event{Revision: 1, Namespace: "0", Key: "user/1", Op: "set"})
+	hub.Reset()
+
+	watches, err := hub.WatchFrom(subscriber, "0", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, watches)
+	assert.Empty(t, subscriber.Messages())
+}